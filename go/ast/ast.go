@@ -1,12 +1,23 @@
 package ast
 
+import (
+	"strconv"
+	"strings"
+)
+
+// Node is an element of the syntax tree. String renders it as canonical
+// Tiger source, with every operation parenthesized.
 type Node interface {
 	TokenLiteral() string
+	String() string
 }
 
 type Statement interface {
 	Node
 	statementNode()
+	// StartLine is the line the statement begins on, or 0 for
+	// statements not read from source
+	StartLine() int
 }
 
 type Expression interface {
@@ -26,143 +37,545 @@ func (p *Program) TokenLiteral() string {
 	return ""
 }
 
+func (p *Program) String() string {
+	lines := make([]string, len(p.Statements))
+	for i, stmt := range p.Statements {
+		lines[i] = stmt.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
 type Identifier struct {
 	TokenLiteralValue string
 	Value             string
+	Line              int
+	Column            int
+	Type              *TypeAnnotation // declared type of a let, const or parameter
+
+	// Set by the resolver for variables declared inside a function:
+	// Depth counts the function scopes between this use and the
+	// declaring one, Index is the variable's slot there. Other names are
+	// looked up by name.
+	Local bool
+	Depth int
+	Index int
 }
 
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.TokenLiteralValue }
+func (i *Identifier) String() string       { return i.Value }
+
+// declaration renders ident with its type annotation, if any
+func declaration(ident *Identifier) string {
+	if ident.Type == nil {
+		return ident.Value
+	}
+	return ident.Value + ": " + ident.Type.Name
+}
+
+// TypeAnnotation is a type written after a colon, as in let x: int = 1
+// or func f(): string. Annotations are only checked by tiger check.
+type TypeAnnotation struct {
+	Name   string
+	Line   int
+	Column int
+}
+
+func (ta *TypeAnnotation) TokenLiteral() string { return ta.Name }
+func (ta *TypeAnnotation) String() string       { return ta.Name }
 
 type StringLiteral struct {
 	Value string
+
+	Line   int // position of the opening quote
+	Column int
 }
 
 func (s *StringLiteral) expressionNode()      {}
 func (s *StringLiteral) TokenLiteral() string { return s.Value }
+func (s *StringLiteral) String() string       { return quote(s.Value) }
 
 type IntegerLiteral struct {
 	Value int64
+
+	Line   int
+	Column int
 }
 
 func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return "" }
+func (il *IntegerLiteral) String() string       { return strconv.FormatInt(il.Value, 10) }
 
 type FloatLiteral struct {
 	Value float64
+
+	Line   int
+	Column int
 }
 
 func (fl *FloatLiteral) expressionNode()      {}
 func (fl *FloatLiteral) TokenLiteral() string { return "" }
+func (fl *FloatLiteral) String() string {
+	// Tiger floats are written with a decimal point
+	s := strconv.FormatFloat(fl.Value, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
 
 type Boolean struct {
 	Value bool
+
+	Line   int
+	Column int
 }
 
 func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return "" }
+func (b *Boolean) String() string       { return strconv.FormatBool(b.Value) }
+
+type Null struct {
+	Line   int
+	Column int
+}
+
+func (n *Null) expressionNode()      {}
+func (n *Null) TokenLiteral() string { return "null" }
+func (n *Null) String() string       { return "null" }
+
+type PrefixExpression struct {
+	Operator string
+	Right    Expression
+
+	Line   int // position of the operator
+	Column int
+}
+
+func (pe *PrefixExpression) expressionNode()      {}
+func (pe *PrefixExpression) TokenLiteral() string { return pe.Operator }
+func (pe *PrefixExpression) String() string {
+	return "(" + pe.Operator + pe.Right.String() + ")"
+}
 
 type InfixExpression struct {
 	Left     Expression
 	Operator string
 	Right    Expression
+
+	Line   int // position of the operator
+	Column int
 }
 
 func (ie *InfixExpression) expressionNode()      {}
 func (ie *InfixExpression) TokenLiteral() string { return "" }
+func (ie *InfixExpression) String() string {
+	return "(" + ie.Left.String() + " " + ie.Operator + " " + ie.Right.String() + ")"
+}
 
 type LetStatement struct {
 	Name  *Identifier
 	Value Expression
+
+	Line int // line of the first token
 }
 
 func (ls *LetStatement) statementNode()       {}
+func (ls *LetStatement) StartLine() int       { return ls.Line }
 func (ls *LetStatement) TokenLiteral() string { return "let" }
+func (ls *LetStatement) String() string {
+	// Function definitions are rendered as written: func name() { ... }
+	if fn, ok := ls.Value.(*FunctionLiteral); ok && fn.Name == ls.Name.Value {
+		return fn.String()
+	}
+	return "let " + declaration(ls.Name) + " = " + ls.Value.String() + ";"
+}
 
 type ConstStatement struct {
 	Name  *Identifier
 	Value Expression
+
+	Line int // line of the first token
 }
 
 func (cs *ConstStatement) statementNode()       {}
+func (cs *ConstStatement) StartLine() int       { return cs.Line }
 func (cs *ConstStatement) TokenLiteral() string { return "const" }
+func (cs *ConstStatement) String() string {
+	return "const " + declaration(cs.Name) + " = " + cs.Value.String() + ";"
+}
 
 type PrintStatement struct {
 	Value Expression
+
+	Line int // line of the first token
 }
 
 func (ps *PrintStatement) statementNode()       {}
+func (ps *PrintStatement) StartLine() int       { return ps.Line }
 func (ps *PrintStatement) TokenLiteral() string { return "print" }
+func (ps *PrintStatement) String() string       { return "print " + ps.Value.String() + ";" }
 
 type IfStatement struct {
 	Condition   Expression
 	Consequence *BlockStatement
 	Alternative *BlockStatement
+
+	Line int // line of the first token
 }
 
 func (is *IfStatement) statementNode()       {}
+func (is *IfStatement) StartLine() int       { return is.Line }
 func (is *IfStatement) TokenLiteral() string { return "if" }
+func (is *IfStatement) String() string {
+	s := "if " + is.Condition.String() + " " + is.Consequence.String()
+	if is.Alternative != nil {
+		s += " else " + is.Alternative.String()
+	}
+	return s
+}
 
 type WhileStatement struct {
 	Condition Expression
 	Body      *BlockStatement
+
+	Line int // line of the first token
 }
 
 func (ws *WhileStatement) statementNode()       {}
+func (ws *WhileStatement) StartLine() int       { return ws.Line }
 func (ws *WhileStatement) TokenLiteral() string { return "while" }
+func (ws *WhileStatement) String() string {
+	return "while " + ws.Condition.String() + " " + ws.Body.String()
+}
 
 type ForStatement struct {
 	Init      Statement   // initialization: let i = 0
 	Condition Expression  // condition: i < 10
 	Update    Statement   // update: i = i + 1
 	Body      *BlockStatement
+
+	Line int // line of the first token
 }
 
 func (fs *ForStatement) statementNode()       {}
+func (fs *ForStatement) StartLine() int       { return fs.Line }
 func (fs *ForStatement) TokenLiteral() string { return "for" }
+func (fs *ForStatement) String() string {
+	s := "for ("
+	if fs.Init != nil {
+		s += fs.Init.String()
+	} else {
+		s += ";"
+	}
+	if fs.Condition != nil {
+		s += " " + fs.Condition.String()
+	}
+	s += ";"
+	if update, ok := fs.Update.(*ExpressionStatement); ok {
+		s += " " + bare(update.Expression)
+	}
+	return s + ") " + fs.Body.String()
+}
 
 type BlockStatement struct {
 	Statements []Statement
+
+	Line      int // line of the opening brace
+	Column    int // column of the opening brace
+	EndLine   int // line of the closing brace
+	EndColumn int // column of the closing brace
 }
 
 func (bs *BlockStatement) statementNode()       {}
+func (bs *BlockStatement) StartLine() int       { return bs.Line }
 func (bs *BlockStatement) TokenLiteral() string { return "{" }
+func (bs *BlockStatement) String() string {
+	if len(bs.Statements) == 0 {
+		return "{}"
+	}
+	parts := make([]string, len(bs.Statements))
+	for i, stmt := range bs.Statements {
+		parts[i] = stmt.String()
+	}
+	return "{ " + strings.Join(parts, " ") + " }"
+}
 
 type FunctionLiteral struct {
 	Name       string
 	Parameters []*Identifier
 	Body       *BlockStatement
+	ReturnType *TypeAnnotation
+	Line       int // line of the func keyword
+	Column     int // column of the func keyword
+
+	// Locals names the function's variable slots, set by the resolver.
+	// Methods keep this in slot 0.
+	Locals []string
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
 func (fl *FunctionLiteral) TokenLiteral() string { return "func" }
+func (fl *FunctionLiteral) String() string {
+	params := make([]string, len(fl.Parameters))
+	for i, param := range fl.Parameters {
+		params[i] = declaration(param)
+	}
+	s := "func"
+	if fl.Name != "" {
+		s += " " + fl.Name
+	}
+	s += "(" + strings.Join(params, ", ") + ")"
+	if fl.ReturnType != nil {
+		s += ": " + fl.ReturnType.Name
+	}
+	return s + " " + fl.Body.String()
+}
 
 type CallExpression struct {
 	Function  Expression
 	Arguments []Expression
+	Line      int // line of the opening parenthesis
+	EndLine   int // line of the closing parenthesis
+	EndColumn int // column of the closing parenthesis
 }
 
 func (ce *CallExpression) expressionNode()      {}
 func (ce *CallExpression) TokenLiteral() string { return "call" }
+func (ce *CallExpression) String() string {
+	return ce.Function.String() + "(" + join(ce.Arguments) + ")"
+}
+
+type ArrayLiteral struct {
+	Elements []Expression
+
+	Line      int // position of the opening bracket
+	Column    int
+	EndLine   int // position of the closing bracket
+	EndColumn int
+}
+
+func (al *ArrayLiteral) expressionNode()      {}
+func (al *ArrayLiteral) TokenLiteral() string { return "[" }
+func (al *ArrayLiteral) String() string       { return "[" + join(al.Elements) + "]" }
+
+// MapLiteral keeps its keys and values in source order
+type MapLiteral struct {
+	Keys   []Expression
+	Values []Expression
+
+	Line      int // position of the opening brace
+	Column    int
+	EndLine   int // position of the closing brace
+	EndColumn int
+}
+
+func (ml *MapLiteral) expressionNode()      {}
+func (ml *MapLiteral) TokenLiteral() string { return "{" }
+func (ml *MapLiteral) String() string {
+	pairs := make([]string, len(ml.Keys))
+	for i, key := range ml.Keys {
+		pairs[i] = key.String() + ": " + ml.Values[i].String()
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+type IndexExpression struct {
+	Left  Expression
+	Index Expression
+}
+
+func (ie *IndexExpression) expressionNode()      {}
+func (ie *IndexExpression) TokenLiteral() string { return "[" }
+func (ie *IndexExpression) String() string {
+	return ie.Left.String() + "[" + ie.Index.String() + "]"
+}
+
+type MemberExpression struct {
+	Object   Expression
+	Property *Identifier
+}
+
+func (me *MemberExpression) expressionNode()      {}
+func (me *MemberExpression) TokenLiteral() string { return "." }
+func (me *MemberExpression) String() string {
+	return me.Object.String() + "." + me.Property.String()
+}
+
+// AssignExpression covers `x = v`, `a[i] = v` and `obj.field = v`
+type AssignExpression struct {
+	Target Expression
+	Value  Expression
+}
+
+func (ae *AssignExpression) expressionNode()      {}
+func (ae *AssignExpression) TokenLiteral() string { return "=" }
+func (ae *AssignExpression) String() string       { return "(" + bare(ae) + ")" }
 
 type ExpressionStatement struct {
 	Expression Expression
+
+	Line int // line of the first token
 }
 
 func (es *ExpressionStatement) statementNode()       {}
+func (es *ExpressionStatement) StartLine() int       { return es.Line }
 func (es *ExpressionStatement) TokenLiteral() string { return "" }
+func (es *ExpressionStatement) String() string {
+	s := bare(es.Expression)
+	// A leading brace would start a block instead of a map
+	if strings.HasPrefix(s, "{") {
+		s = "(" + s + ")"
+	}
+	return s + ";"
+}
 
 type ReturnStatement struct {
 	Value Expression
+
+	Line   int // position of the return keyword
+	Column int
 }
 
 func (rs *ReturnStatement) statementNode()       {}
+func (rs *ReturnStatement) StartLine() int       { return rs.Line }
 func (rs *ReturnStatement) TokenLiteral() string { return "return" }
+func (rs *ReturnStatement) String() string {
+	if rs.Value == nil {
+		return "return;"
+	}
+	return "return " + rs.Value.String() + ";"
+}
 
 type ClassStatement struct {
 	Name    *Identifier
 	Methods []*FunctionLiteral
+
+	Line    int // line of the first token
+	EndLine int // line of the closing brace
 }
 
 func (cs *ClassStatement) statementNode()       {}
+func (cs *ClassStatement) StartLine() int       { return cs.Line }
 func (cs *ClassStatement) TokenLiteral() string { return "class" }
+func (cs *ClassStatement) String() string {
+	if len(cs.Methods) == 0 {
+		return "class " + cs.Name.String() + " {}"
+	}
+	methods := make([]string, len(cs.Methods))
+	for i, method := range cs.Methods {
+		methods[i] = method.String()
+	}
+	return "class " + cs.Name.String() + " { " + strings.Join(methods, " ") + " }"
+}
+
+// TryStatement runs Block and, if it raises an error, Handler with the
+// error bound to Param (which may be nil)
+type TryStatement struct {
+	Block   *BlockStatement
+	Param   *Identifier
+	Handler *BlockStatement
+
+	Line int // line of the first token
+}
+
+func (ts *TryStatement) statementNode()       {}
+func (ts *TryStatement) StartLine() int       { return ts.Line }
+func (ts *TryStatement) TokenLiteral() string { return "try" }
+func (ts *TryStatement) String() string {
+	s := "try " + ts.Block.String() + " catch "
+	if ts.Param != nil {
+		s += "(" + ts.Param.String() + ") "
+	}
+	return s + ts.Handler.String()
+}
+
+type ThrowStatement struct {
+	Value Expression
+
+	Line int // line of the first token
+}
+
+func (ts *ThrowStatement) statementNode()       {}
+func (ts *ThrowStatement) StartLine() int       { return ts.Line }
+func (ts *ThrowStatement) TokenLiteral() string { return "throw" }
+func (ts *ThrowStatement) String() string       { return "throw " + ts.Value.String() + ";" }
+
+// bare renders expr without the parentheses of an assignment, for
+// statement positions where no operator can surround it
+func bare(expr Expression) string {
+	if ae, ok := expr.(*AssignExpression); ok {
+		return ae.Target.String() + " = " + ae.Value.String()
+	}
+	return expr.String()
+}
+
+func join(exprs []Expression) string {
+	parts := make([]string, len(exprs))
+	for i, expr := range exprs {
+		parts[i] = expr.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// quote writes s as a string literal, using only the escapes the lexer
+// understands
+func quote(s string) string {
+	var out strings.Builder
+	out.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\':
+			out.WriteByte('\\')
+			out.WriteByte(c)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '\r':
+			out.WriteString(`\r`)
+		default:
+			out.WriteByte(c)
+		}
+	}
+	out.WriteByte('"')
+	return out.String()
+}
+
+// Start returns the position of the first token of expr, or 0, 0 for
+// an expression built by a tool rather than parsed
+func Start(expr Expression) (line, column int) {
+	switch expr := expr.(type) {
+	case *Identifier:
+		return expr.Line, expr.Column
+	case *StringLiteral:
+		return expr.Line, expr.Column
+	case *IntegerLiteral:
+		return expr.Line, expr.Column
+	case *FloatLiteral:
+		return expr.Line, expr.Column
+	case *Boolean:
+		return expr.Line, expr.Column
+	case *Null:
+		return expr.Line, expr.Column
+	case *PrefixExpression:
+		return expr.Line, expr.Column
+	case *ArrayLiteral:
+		return expr.Line, expr.Column
+	case *MapLiteral:
+		return expr.Line, expr.Column
+	case *FunctionLiteral:
+		return expr.Line, expr.Column
+	case *InfixExpression:
+		return Start(expr.Left)
+	case *CallExpression:
+		return Start(expr.Function)
+	case *IndexExpression:
+		return Start(expr.Left)
+	case *MemberExpression:
+		return Start(expr.Object)
+	case *AssignExpression:
+		return Start(expr.Target)
+	}
+	return 0, 0
+}
@@ -0,0 +1,124 @@
+package ast_test
+
+import (
+	"testing"
+	"tiger/go/ast"
+	"tiger/go/lexer"
+	"tiger/go/parser"
+)
+
+func parse(t *testing.T, src string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parsing %q: %v", src, p.Errors())
+	}
+	return program
+}
+
+// TestString checks the canonical source of every kind of node, and
+// that parsing it back gives the same tree
+func TestString(t *testing.T) {
+	tests := []struct {
+		node string
+		src  string
+		want string
+	}{
+		{"Identifier", "x", "x;"},
+		{"IntegerLiteral", "42", "42;"},
+		{"FloatLiteral", "2.5", "2.5;"},
+		{"whole FloatLiteral", "1.0", "1.0;"},
+		{"StringLiteral", `"a\"b\\c\n\t\r"`, `"a\"b\\c\n\t\r";`},
+		{"Boolean", "false", "false;"},
+		{"Null", "null", "null;"},
+		{"PrefixExpression", "-(-x)", "(-(-x));"},
+		{"not", "!ok", "(!ok);"},
+		{"InfixExpression", "1 + 2 * 3", "(1 + (2 * 3));"},
+		{"grouping", "(1 + 2) * 3 == 9", "(((1 + 2) * 3) == 9);"},
+		{"LetStatement", "let x = 1", "let x = 1;"},
+		{"LetStatement with a type", "let x: int = 1", "let x: int = 1;"},
+		{"ConstStatement", `const name: string = "tiger"`, `const name: string = "tiger";`},
+		{"PrintStatement", "print x", "print x;"},
+		{"IfStatement", "if (a) { print 1 }", "if a { print 1; }"},
+		{"IfStatement with else", "if (a < b) { print 1 } else { print 2 }", "if (a < b) { print 1; } else { print 2; }"},
+		{"WhileStatement", "while (i < 10) { i = i + 1 }", "while (i < 10) { i = (i + 1); }"},
+		{"ForStatement", "for (let i = 0; i < 10; i = i + 1) { print i }", "for (let i = 0; (i < 10); i = (i + 1)) { print i; }"},
+		{"empty ForStatement", "for (;;) {}", "for (;;) {}"},
+		{"ForStatement without init", "for (; i < 3;) {}", "for (; (i < 3);) {}"},
+		{"BlockStatement", "{ let a = 1\nprint a }", "{ let a = 1; print a; }"},
+		{"FunctionLiteral", "func add(a, b) { return a + b }", "func add(a, b) { return (a + b); }"},
+		{"FunctionLiteral with types", "func add(a: int, b): int { return a + b }", "func add(a: int, b): int { return (a + b); }"},
+		{"anonymous FunctionLiteral", "let f = func(x) { return x * 2 }", "let f = func(x) { return (x * 2); };"},
+		{"anonymous FunctionLiteral with types", "let f = func(x: float): float { return x }", "let f = func(x: float): float { return x; };"},
+		{"called FunctionLiteral", "(func() { return })()", "func() { return; }();"},
+		{"FunctionLiteral as an argument", "map(xs, func(x) { return x })", "map(xs, func(x) { return x; });"},
+		{"CallExpression", "f(1, g(2), [3])", "f(1, g(2), [3]);"},
+		{"ArrayLiteral", "[1, [2, 3], []]", "[1, [2, 3], []];"},
+		{"MapLiteral", `let m = {"a": 1, "b": {}}`, `let m = {"a": 1, "b": {}};`},
+		{"MapLiteral statement", `({"a": 1})`, `({"a": 1});`},
+		{"IndexExpression", "a[0][i + 1]", "a[0][(i + 1)];"},
+		{"MemberExpression", "obj.field.method(1)", "obj.field.method(1);"},
+		{"AssignExpression", "x = y = 3", "x = (y = 3);"},
+		{"AssignExpression to an index", "a[0] = 1", "a[0] = 1;"},
+		{"AssignExpression to a member", "this.x = x", "this.x = x;"},
+		{"AssignExpression in an argument", "f(x = 1)", "f((x = 1));"},
+		{"ReturnStatement", "func f() { return }", "func f() { return; }"},
+		{"ClassStatement", "class A {}", "class A {}"},
+		{
+			"ClassStatement with methods",
+			"class P {\n  func init(x) { this.x = x }\n  func get(): int { return this.x }\n}",
+			"class P { func init(x) { this.x = x; } func get(): int { return this.x; } }",
+		},
+		{"TryStatement", "try { f() } catch (e) { print e.message }", "try { f(); } catch (e) { print e.message; }"},
+		{"TryStatement without a name", "try { f() } catch { print 1 }", "try { f(); } catch { print 1; }"},
+		{"ThrowStatement", `throw {"code": 1}`, `throw {"code": 1};`},
+		{"Program", "let a = 1\nprint a", "let a = 1;\nprint a;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.node, func(t *testing.T) {
+			got := parse(t, tt.src).String()
+			if got != tt.want {
+				t.Fatalf("String() = %q, want %q", got, tt.want)
+			}
+			if again := parse(t, got).String(); again != got {
+				t.Errorf("parsing %q gives %q", got, again)
+			}
+		})
+	}
+}
+
+// TestStringBuilt renders trees made by tools, which have no positions
+// and may hold values no literal is written as
+func TestStringBuilt(t *testing.T) {
+	tests := []struct {
+		node ast.Node
+		want string
+	}{
+		{&ast.FloatLiteral{Value: 3}, "3.0"},
+		{&ast.FloatLiteral{Value: 1e21}, "1000000000000000000000.0"},
+		{&ast.StringLiteral{Value: "tab\there"}, `"tab\there"`},
+		{&ast.TypeAnnotation{Name: "int"}, "int"},
+		{&ast.ReturnStatement{}, "return;"},
+		{&ast.ExpressionStatement{Expression: &ast.MapLiteral{}}, "({});"},
+		{
+			&ast.LetStatement{
+				Name:  &ast.Identifier{Value: "f"},
+				Value: &ast.FunctionLiteral{Name: "f", Body: &ast.BlockStatement{}},
+			},
+			"func f() {}",
+		},
+		{
+			&ast.LetStatement{
+				Name:  &ast.Identifier{Value: "g"},
+				Value: &ast.FunctionLiteral{Name: "f", Body: &ast.BlockStatement{}},
+			},
+			"let g = func f() {};",
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.node.String(); got != tt.want {
+			t.Errorf("%T: got %q, want %q", tt.node, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package ast
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// skipped are fields describing where a node came from or how it was
+// resolved rather than what it is
+var skipped = map[string]bool{
+	"TokenLiteralValue": true,
+	"Line":              true,
+	"EndLine":           true,
+	"Column":            true,
+	"EndColumn":         true,
+	"Local":             true,
+	"Depth":             true,
+	"Index":             true,
+	"Locals":            true,
+}
+
+// Fprint writes node to w as an indented tree, one node or field per
+// line
+func Fprint(w io.Writer, node Node) error {
+	p := &printer{w: w}
+	p.value("", reflect.ValueOf(node), 0)
+	return p.err
+}
+
+type printer struct {
+	w   io.Writer
+	err error
+}
+
+func (p *printer) printf(depth int, format string, a ...interface{}) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.w, strings.Repeat("  ", depth)+format+"\n", a...)
+	}
+}
+
+// value prints v, labelled with its field name when it has one
+func (p *printer) value(label string, v reflect.Value, depth int) {
+	if label != "" {
+		label += ": "
+	}
+	for v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if !v.IsValid() || (v.Kind() == reflect.Ptr && v.IsNil()) {
+		p.printf(depth, "%snil", label)
+		return
+	}
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		name := v.Type().Name()
+		switch node := v.Addr().Interface().(type) {
+		case *Identifier:
+			p.printf(depth, "%s%s %s", label, name, declaration(node))
+			return
+		case *TypeAnnotation:
+			p.printf(depth, "%s%s %s", label, name, node.Name)
+			return
+		case *StringLiteral:
+			p.printf(depth, "%s%s %q", label, name, node.Value)
+			return
+		case *IntegerLiteral, *FloatLiteral, *Boolean:
+			p.printf(depth, "%s%s %v", label, name, v.Field(0).Interface())
+			return
+		}
+		p.printf(depth, "%s%s", label, name)
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() || skipped[field.Name] {
+				continue
+			}
+			p.value(field.Name, v.Field(i), depth+1)
+		}
+	case reflect.Slice:
+		if v.Len() == 0 {
+			p.printf(depth, "%s[]", label)
+			return
+		}
+		p.printf(depth, "%s[", label)
+		for i := 0; i < v.Len(); i++ {
+			p.value("", v.Index(i), depth+1)
+		}
+		p.printf(depth, "]")
+	case reflect.String:
+		p.printf(depth, "%s%q", label, v.String())
+	default:
+		p.printf(depth, "%s%v", label, v.Interface())
+	}
+}
@@ -0,0 +1,206 @@
+package compiler
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// Instructions is a run of encoded bytecode: an opcode byte followed by
+// its big-endian operands
+type Instructions []byte
+
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota // index of the constant to push
+	OpNull
+	OpTrue
+	OpFalse
+	OpPop // discards the top of the stack
+
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpEqual
+	OpNotEqual
+	OpLess
+	OpLessEqual
+	OpGreater
+	OpGreaterEqual
+	OpMinus
+	OpNot
+
+	OpJump          // absolute target
+	OpJumpNotTruthy // absolute target; pops the condition
+	OpLoop          // absolute target of a backward jump closing a loop
+
+	OpGetGlobal
+	OpSetGlobal // assigns the top of the stack, leaving it there
+	OpDefineGlobal
+	OpDefineGlobalConst
+	OpGetLocal
+	OpSetLocal
+	OpDefineLocal
+	OpDefineLocalConst
+	OpGetFree // scope depth, then slot index
+	OpSetFree
+
+	OpArray // element count
+	OpMap   // key-value pair count
+	OpIndex
+	OpSetIndex  // pops value, container and index; pushes the value
+	OpMember    // constant index of the member name
+	OpSetMember // constant index of the member name
+
+	OpCall // argument count, then line of the call
+	OpReturn
+	OpReturnNull
+	OpClosure // constant index of the compiled function
+	OpClass   // constant index of the name, then method count
+
+	OpPrint
+	OpThrow
+	OpTry // absolute address of the catch handler
+	OpEndTry
+)
+
+// Definition names an opcode and the width in bytes of each operand
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpNull:     {"OpNull", []int{}},
+	OpTrue:     {"OpTrue", []int{}},
+	OpFalse:    {"OpFalse", []int{}},
+	OpPop:      {"OpPop", []int{}},
+
+	OpAdd:          {"OpAdd", []int{}},
+	OpSub:          {"OpSub", []int{}},
+	OpMul:          {"OpMul", []int{}},
+	OpDiv:          {"OpDiv", []int{}},
+	OpEqual:        {"OpEqual", []int{}},
+	OpNotEqual:     {"OpNotEqual", []int{}},
+	OpLess:         {"OpLess", []int{}},
+	OpLessEqual:    {"OpLessEqual", []int{}},
+	OpGreater:      {"OpGreater", []int{}},
+	OpGreaterEqual: {"OpGreaterEqual", []int{}},
+	OpMinus:        {"OpMinus", []int{}},
+	OpNot:          {"OpNot", []int{}},
+
+	OpJump:          {"OpJump", []int{2}},
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+	OpLoop:          {"OpLoop", []int{2}},
+
+	OpGetGlobal:         {"OpGetGlobal", []int{2}},
+	OpSetGlobal:         {"OpSetGlobal", []int{2}},
+	OpDefineGlobal:      {"OpDefineGlobal", []int{2}},
+	OpDefineGlobalConst: {"OpDefineGlobalConst", []int{2}},
+	OpGetLocal:          {"OpGetLocal", []int{2}},
+	OpSetLocal:          {"OpSetLocal", []int{2}},
+	OpDefineLocal:       {"OpDefineLocal", []int{2}},
+	OpDefineLocalConst:  {"OpDefineLocalConst", []int{2}},
+	OpGetFree:           {"OpGetFree", []int{1, 2}},
+	OpSetFree:           {"OpSetFree", []int{1, 2}},
+
+	OpArray:     {"OpArray", []int{2}},
+	OpMap:       {"OpMap", []int{2}},
+	OpIndex:     {"OpIndex", []int{}},
+	OpSetIndex:  {"OpSetIndex", []int{}},
+	OpMember:    {"OpMember", []int{2}},
+	OpSetMember: {"OpSetMember", []int{2}},
+
+	OpCall:       {"OpCall", []int{1, 2}},
+	OpReturn:     {"OpReturn", []int{}},
+	OpReturnNull: {"OpReturnNull", []int{}},
+	OpClosure:    {"OpClosure", []int{2}},
+	OpClass:      {"OpClass", []int{2, 1}},
+
+	OpPrint:  {"OpPrint", []int{}},
+	OpThrow:  {"OpThrow", []int{}},
+	OpTry:    {"OpTry", []int{2}},
+	OpEndTry: {"OpEndTry", []int{}},
+}
+
+// Lookup returns the definition of op
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return def, nil
+}
+
+// Make encodes one instruction
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	length := 1
+	for _, w := range def.OperandWidths {
+		length += w
+	}
+	instruction := make([]byte, length)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		switch def.OperandWidths[i] {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += def.OperandWidths[i]
+	}
+	return instruction
+}
+
+// ReadOperands decodes the operands following an opcode, returning them
+// with the number of bytes read
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ins[offset])
+		}
+		offset += width
+	}
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+// String disassembles the instructions, one per line
+func (ins Instructions) String() string {
+	var out strings.Builder
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s", i, def.Name)
+		for _, operand := range operands {
+			fmt.Fprintf(&out, " %d", operand)
+		}
+		out.WriteByte('\n')
+		i += 1 + read
+	}
+	return out.String()
+}
@@ -0,0 +1,579 @@
+// Package compiler lowers a parsed Tiger program to bytecode with a
+// constant pool, executed by the vm package.
+package compiler
+
+import (
+	"fmt"
+	"math"
+	"tiger/go/ast"
+	"tiger/go/object"
+)
+
+// CompiledFunction is the bytecode of a function body. Methods keep the
+// instance in local slot 0, before the parameters.
+type CompiledFunction struct {
+	Name         string
+	Instructions Instructions
+	NumLocals    int
+	NumParams    int
+	Method       bool
+
+	// Locals names each local slot
+	Locals []string
+	// Callees marks the offsets of instructions loading the name of a
+	// called function, which report "undefined function" when unset
+	Callees map[int]bool
+}
+
+func (f *CompiledFunction) Type() object.ObjectType { return object.FUNCTION_OBJ }
+func (f *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("[compiled func %s]", f.Name)
+}
+
+// Bytecode is a compiled program. Globals names each global slot.
+type Bytecode struct {
+	Main      *CompiledFunction
+	Constants []object.Object
+	Globals   []string
+}
+
+// scope collects the instructions of the function being compiled
+type scope struct {
+	instructions Instructions
+	callees      map[int]bool
+}
+
+type Compiler struct {
+	constants []object.Object
+	strings   map[string]int // constant index of each string
+	symbols   *SymbolTable
+	scopes    []*scope
+}
+
+func New() *Compiler {
+	return &Compiler{
+		strings: make(map[string]int),
+		symbols: NewSymbolTable(),
+		scopes:  []*scope{{callees: make(map[int]bool)}},
+	}
+}
+
+// Compile lowers program to bytecode
+func (c *Compiler) Compile(program *ast.Program) (*Bytecode, error) {
+	for _, stmt := range program.Statements {
+		if err := c.compileStatement(stmt); err != nil {
+			return nil, err
+		}
+	}
+	main := c.scopes[0]
+	if len(main.instructions) > math.MaxUint16 {
+		return nil, fmt.Errorf("program is too large")
+	}
+	return &Bytecode{
+		Main: &CompiledFunction{
+			Name:         "<main>",
+			Instructions: main.instructions,
+			Callees:      main.callees,
+		},
+		Constants: c.constants,
+		Globals:   c.symbols.Names(),
+	}, nil
+}
+
+func (c *Compiler) compileStatement(stmt ast.Statement) error {
+	switch stmt := stmt.(type) {
+	case *ast.ExpressionStatement:
+		if err := c.compileExpression(stmt.Expression); err != nil {
+			return err
+		}
+		c.emit(OpPop)
+
+	case *ast.LetStatement:
+		return c.compileDefinition(stmt.Name.Value, stmt.Value, false)
+
+	case *ast.ConstStatement:
+		return c.compileDefinition(stmt.Name.Value, stmt.Value, true)
+
+	case *ast.PrintStatement:
+		if err := c.compileExpression(stmt.Value); err != nil {
+			return err
+		}
+		c.emit(OpPrint)
+
+	case *ast.IfStatement:
+		if err := c.compileExpression(stmt.Condition); err != nil {
+			return err
+		}
+		jumpNotTruthy := c.emit(OpJumpNotTruthy, 0)
+		if err := c.compileStatement(stmt.Consequence); err != nil {
+			return err
+		}
+		if stmt.Alternative == nil {
+			return c.patchJump(jumpNotTruthy)
+		}
+		jump := c.emit(OpJump, 0)
+		if err := c.patchJump(jumpNotTruthy); err != nil {
+			return err
+		}
+		if err := c.compileStatement(stmt.Alternative); err != nil {
+			return err
+		}
+		return c.patchJump(jump)
+
+	case *ast.WhileStatement:
+		start := len(c.current().instructions)
+		if err := c.compileExpression(stmt.Condition); err != nil {
+			return err
+		}
+		exit := c.emit(OpJumpNotTruthy, 0)
+		if err := c.compileStatement(stmt.Body); err != nil {
+			return err
+		}
+		c.emit(OpLoop, start)
+		return c.patchJump(exit)
+
+	case *ast.ForStatement:
+		if stmt.Init != nil {
+			if err := c.compileStatement(stmt.Init); err != nil {
+				return err
+			}
+		}
+		start := len(c.current().instructions)
+		exit := -1
+		if stmt.Condition != nil {
+			if err := c.compileExpression(stmt.Condition); err != nil {
+				return err
+			}
+			exit = c.emit(OpJumpNotTruthy, 0)
+		}
+		if err := c.compileStatement(stmt.Body); err != nil {
+			return err
+		}
+		if stmt.Update != nil {
+			if err := c.compileStatement(stmt.Update); err != nil {
+				return err
+			}
+		}
+		c.emit(OpLoop, start)
+		if exit >= 0 {
+			return c.patchJump(exit)
+		}
+
+	case *ast.BlockStatement:
+		for _, s := range stmt.Statements {
+			if err := c.compileStatement(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.ReturnStatement:
+		if stmt.Value == nil {
+			c.emit(OpReturnNull)
+			return nil
+		}
+		if err := c.compileExpression(stmt.Value); err != nil {
+			return err
+		}
+		c.emit(OpReturn)
+
+	case *ast.ClassStatement:
+		for _, method := range stmt.Methods {
+			if err := c.compileFunction(method, true); err != nil {
+				return err
+			}
+		}
+		if len(stmt.Methods) > math.MaxUint8 {
+			return fmt.Errorf("class %s has too many methods", stmt.Name.Value)
+		}
+		name, err := c.addString(stmt.Name.Value)
+		if err != nil {
+			return err
+		}
+		c.emit(OpClass, name, len(stmt.Methods))
+		c.emitDefine(c.symbols.Define(stmt.Name.Value), false)
+
+	case *ast.TryStatement:
+		try := c.emit(OpTry, 0)
+		if err := c.compileStatement(stmt.Block); err != nil {
+			return err
+		}
+		c.emit(OpEndTry)
+		jump := c.emit(OpJump, 0)
+		if err := c.patchJump(try); err != nil {
+			return err
+		}
+		// The handler starts with the caught value on the stack
+		if stmt.Param != nil {
+			c.emitDefine(c.symbols.Define(stmt.Param.Value), false)
+		} else {
+			c.emit(OpPop)
+		}
+		if err := c.compileStatement(stmt.Handler); err != nil {
+			return err
+		}
+		return c.patchJump(jump)
+
+	case *ast.ThrowStatement:
+		if err := c.compileExpression(stmt.Value); err != nil {
+			return err
+		}
+		c.emit(OpThrow)
+
+	default:
+		return fmt.Errorf("cannot compile %T", stmt)
+	}
+	return nil
+}
+
+// compileDefinition binds name to value. A function may call itself, so
+// its name is defined before its body is compiled; other values are
+// compiled first, so `let x = x + 1` can read an outer x.
+func (c *Compiler) compileDefinition(name string, value ast.Expression, constant bool) error {
+	if _, ok := value.(*ast.FunctionLiteral); ok {
+		symbol := c.symbols.Define(name)
+		if err := c.compileExpression(value); err != nil {
+			return err
+		}
+		c.emitDefine(symbol, constant)
+		return nil
+	}
+	if err := c.compileExpression(value); err != nil {
+		return err
+	}
+	c.emitDefine(c.symbols.Define(name), constant)
+	return nil
+}
+
+func (c *Compiler) compileExpression(expr ast.Expression) error {
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		c.loadSymbol(c.symbols.Resolve(expr.Value))
+
+	case *ast.IntegerLiteral:
+		return c.emitConstant(&object.Integer{Value: expr.Value})
+
+	case *ast.FloatLiteral:
+		return c.emitConstant(&object.Float{Value: expr.Value})
+
+	case *ast.StringLiteral:
+		index, err := c.addString(expr.Value)
+		if err != nil {
+			return err
+		}
+		c.emit(OpConstant, index)
+
+	case *ast.Boolean:
+		if expr.Value {
+			c.emit(OpTrue)
+		} else {
+			c.emit(OpFalse)
+		}
+
+	case *ast.Null:
+		c.emit(OpNull)
+
+	case *ast.PrefixExpression:
+		if err := c.compileExpression(expr.Right); err != nil {
+			return err
+		}
+		switch expr.Operator {
+		case "-":
+			c.emit(OpMinus)
+		case "!":
+			c.emit(OpNot)
+		default:
+			return fmt.Errorf("unknown operator %s", expr.Operator)
+		}
+
+	case *ast.InfixExpression:
+		op, ok := infixOps[expr.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", expr.Operator)
+		}
+		if err := c.compileExpression(expr.Left); err != nil {
+			return err
+		}
+		if err := c.compileExpression(expr.Right); err != nil {
+			return err
+		}
+		c.emit(op)
+
+	case *ast.ArrayLiteral:
+		for _, el := range expr.Elements {
+			if err := c.compileExpression(el); err != nil {
+				return err
+			}
+		}
+		return c.emitCount(OpArray, len(expr.Elements))
+
+	case *ast.MapLiteral:
+		for i, key := range expr.Keys {
+			if err := c.compileExpression(key); err != nil {
+				return err
+			}
+			if err := c.compileExpression(expr.Values[i]); err != nil {
+				return err
+			}
+		}
+		return c.emitCount(OpMap, len(expr.Keys))
+
+	case *ast.IndexExpression:
+		if err := c.compileExpression(expr.Left); err != nil {
+			return err
+		}
+		if err := c.compileExpression(expr.Index); err != nil {
+			return err
+		}
+		c.emit(OpIndex)
+
+	case *ast.MemberExpression:
+		if err := c.compileExpression(expr.Object); err != nil {
+			return err
+		}
+		name, err := c.addString(expr.Property.Value)
+		if err != nil {
+			return err
+		}
+		c.emit(OpMember, name)
+
+	case *ast.AssignExpression:
+		return c.compileAssign(expr)
+
+	case *ast.CallExpression:
+		if ident, ok := expr.Function.(*ast.Identifier); ok {
+			c.current().callees[len(c.current().instructions)] = true
+			c.loadSymbol(c.symbols.Resolve(ident.Value))
+		} else if err := c.compileExpression(expr.Function); err != nil {
+			return err
+		}
+		for _, arg := range expr.Arguments {
+			if err := c.compileExpression(arg); err != nil {
+				return err
+			}
+		}
+		if len(expr.Arguments) > math.MaxUint8 {
+			return fmt.Errorf("too many arguments in call on line %d", expr.Line)
+		}
+		c.emit(OpCall, len(expr.Arguments), expr.Line)
+
+	case *ast.FunctionLiteral:
+		return c.compileFunction(expr, false)
+
+	default:
+		return fmt.Errorf("cannot compile %T", expr)
+	}
+	return nil
+}
+
+var infixOps = map[string]Opcode{
+	"+":  OpAdd,
+	"-":  OpSub,
+	"*":  OpMul,
+	"/":  OpDiv,
+	"==": OpEqual,
+	"!=": OpNotEqual,
+	"<":  OpLess,
+	"<=": OpLessEqual,
+	">":  OpGreater,
+	">=": OpGreaterEqual,
+}
+
+// compileAssign evaluates the value before the target, as the
+// tree-walker does, and leaves the value on the stack
+func (c *Compiler) compileAssign(expr *ast.AssignExpression) error {
+	if err := c.compileExpression(expr.Value); err != nil {
+		return err
+	}
+	switch target := expr.Target.(type) {
+	case *ast.Identifier:
+		symbol := c.symbols.Resolve(target.Value)
+		switch symbol.Scope {
+		case GlobalScope:
+			c.emit(OpSetGlobal, symbol.Index)
+		case LocalScope:
+			c.emit(OpSetLocal, symbol.Index)
+		case FreeScope:
+			c.emit(OpSetFree, symbol.Depth, symbol.Index)
+		}
+	case *ast.IndexExpression:
+		if err := c.compileExpression(target.Left); err != nil {
+			return err
+		}
+		if err := c.compileExpression(target.Index); err != nil {
+			return err
+		}
+		c.emit(OpSetIndex)
+	case *ast.MemberExpression:
+		if err := c.compileExpression(target.Object); err != nil {
+			return err
+		}
+		name, err := c.addString(target.Property.Value)
+		if err != nil {
+			return err
+		}
+		c.emit(OpSetMember, name)
+	default:
+		return fmt.Errorf("cannot assign to %T", target)
+	}
+	return nil
+}
+
+// compileFunction compiles fn into the constant pool and emits the
+// closure creating it
+func (c *Compiler) compileFunction(fn *ast.FunctionLiteral, method bool) error {
+	c.symbols = NewEnclosedSymbolTable(c.symbols)
+	c.scopes = append(c.scopes, &scope{callees: make(map[int]bool)})
+	if method {
+		c.symbols.Define("this")
+	}
+	for _, param := range fn.Parameters {
+		c.symbols.Define(param.Value)
+	}
+	// Nested functions and classes may refer to each other before
+	// their definitions run
+	hoist(fn.Body, c.symbols)
+
+	err := c.compileStatement(fn.Body)
+	c.emit(OpReturnNull)
+
+	body := c.current()
+	compiled := &CompiledFunction{
+		Name:         fn.Name,
+		Instructions: body.instructions,
+		NumLocals:    c.symbols.numDefinitions,
+		NumParams:    len(fn.Parameters),
+		Method:       method,
+		Locals:       c.symbols.Names(),
+		Callees:      body.callees,
+	}
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.symbols = c.symbols.Outer
+	if err != nil {
+		return err
+	}
+	if len(compiled.Instructions) > math.MaxUint16 {
+		return fmt.Errorf("function %s is too large", compiled.Name)
+	}
+
+	index, err := c.addConstant(compiled)
+	if err != nil {
+		return err
+	}
+	c.emit(OpClosure, index)
+	return nil
+}
+
+// hoist defines the functions and classes declared in a function body,
+// outside any nested function
+func hoist(stmt ast.Statement, symbols *SymbolTable) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok && fn.Name == stmt.Name.Value {
+			symbols.Define(stmt.Name.Value)
+		}
+	case *ast.ClassStatement:
+		symbols.Define(stmt.Name.Value)
+	case *ast.BlockStatement:
+		for _, s := range stmt.Statements {
+			hoist(s, symbols)
+		}
+	case *ast.IfStatement:
+		hoist(stmt.Consequence, symbols)
+		if stmt.Alternative != nil {
+			hoist(stmt.Alternative, symbols)
+		}
+	case *ast.WhileStatement:
+		hoist(stmt.Body, symbols)
+	case *ast.ForStatement:
+		hoist(stmt.Body, symbols)
+	case *ast.TryStatement:
+		hoist(stmt.Block, symbols)
+		hoist(stmt.Handler, symbols)
+	}
+}
+
+func (c *Compiler) loadSymbol(symbol Symbol) {
+	switch symbol.Scope {
+	case GlobalScope:
+		c.emit(OpGetGlobal, symbol.Index)
+	case LocalScope:
+		c.emit(OpGetLocal, symbol.Index)
+	case FreeScope:
+		c.emit(OpGetFree, symbol.Depth, symbol.Index)
+	}
+}
+
+func (c *Compiler) emitDefine(symbol Symbol, constant bool) {
+	switch {
+	case symbol.Scope == GlobalScope && constant:
+		c.emit(OpDefineGlobalConst, symbol.Index)
+	case symbol.Scope == GlobalScope:
+		c.emit(OpDefineGlobal, symbol.Index)
+	case constant:
+		c.emit(OpDefineLocalConst, symbol.Index)
+	default:
+		c.emit(OpDefineLocal, symbol.Index)
+	}
+}
+
+func (c *Compiler) emitCount(op Opcode, count int) error {
+	if count > math.MaxUint16 {
+		return fmt.Errorf("literal has too many elements")
+	}
+	c.emit(op, count)
+	return nil
+}
+
+func (c *Compiler) emitConstant(obj object.Object) error {
+	index, err := c.addConstant(obj)
+	if err != nil {
+		return err
+	}
+	c.emit(OpConstant, index)
+	return nil
+}
+
+// addString returns the constant holding s, adding it once
+func (c *Compiler) addString(s string) (int, error) {
+	if index, ok := c.strings[s]; ok {
+		return index, nil
+	}
+	index, err := c.addConstant(&object.String{Value: s})
+	if err != nil {
+		return 0, err
+	}
+	c.strings[s] = index
+	return index, nil
+}
+
+func (c *Compiler) addConstant(obj object.Object) (int, error) {
+	if len(c.constants) > math.MaxUint16 {
+		return 0, fmt.Errorf("too many constants")
+	}
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1, nil
+}
+
+func (c *Compiler) current() *scope {
+	return c.scopes[len(c.scopes)-1]
+}
+
+// emit appends an instruction, returning its offset
+func (c *Compiler) emit(op Opcode, operands ...int) int {
+	scope := c.current()
+	pos := len(scope.instructions)
+	scope.instructions = append(scope.instructions, Make(op, operands...)...)
+	return pos
+}
+
+// patchJump points the jump at pos to the next instruction
+func (c *Compiler) patchJump(pos int) error {
+	scope := c.current()
+	target := len(scope.instructions)
+	if target > math.MaxUint16 {
+		return fmt.Errorf("program is too large")
+	}
+	op := Opcode(scope.instructions[pos])
+	copy(scope.instructions[pos:], Make(op, target))
+	return nil
+}
@@ -0,0 +1,293 @@
+package compiler_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"tiger/go/compiler"
+	"tiger/go/lexer"
+	"tiger/go/parser"
+)
+
+func compile(t *testing.T, src string) *compiler.Bytecode {
+	t.Helper()
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatal(p.Errors())
+	}
+	bytecode, err := compiler.New().Compile(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytecode
+}
+
+func concat(instructions ...[]byte) compiler.Instructions {
+	var out compiler.Instructions
+	for _, ins := range instructions {
+		out = append(out, ins...)
+	}
+	return out
+}
+
+// inspect lists the Inspect of each constant
+func inspect(bytecode *compiler.Bytecode) []string {
+	var out []string
+	for _, c := range bytecode.Constants {
+		out = append(out, c.Inspect())
+	}
+	return out
+}
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		op       compiler.Opcode
+		operands []int
+		want     []byte
+	}{
+		{compiler.OpConstant, []int{65534}, []byte{byte(compiler.OpConstant), 255, 254}},
+		{compiler.OpPop, nil, []byte{byte(compiler.OpPop)}},
+		{compiler.OpCall, []int{2, 300}, []byte{byte(compiler.OpCall), 2, 1, 44}},
+		{compiler.OpGetFree, []int{1, 3}, []byte{byte(compiler.OpGetFree), 1, 0, 3}},
+		{compiler.OpClass, []int{7, 2}, []byte{byte(compiler.OpClass), 0, 7, 2}},
+	}
+	for _, tt := range tests {
+		got := compiler.Make(tt.op, tt.operands...)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Make(%d, %v) = %v, want %v", tt.op, tt.operands, got, tt.want)
+		}
+		def, err := compiler.Lookup(byte(tt.op))
+		if err != nil {
+			t.Fatal(err)
+		}
+		operands, read := compiler.ReadOperands(def, got[1:])
+		if read != len(got)-1 || (len(tt.operands) > 0 && !reflect.DeepEqual(operands, tt.operands)) {
+			t.Errorf("ReadOperands(%s) = %v, %d", def.Name, operands, read)
+		}
+	}
+}
+
+func TestInstructionsString(t *testing.T) {
+	ins := concat(
+		compiler.Make(compiler.OpConstant, 1),
+		compiler.Make(compiler.OpGetFree, 2, 65535),
+		compiler.Make(compiler.OpAdd),
+		compiler.Make(compiler.OpCall, 1, 12),
+		[]byte{255},
+	)
+	want := "0000 OpConstant 1\n0003 OpGetFree 2 65535\n0007 OpAdd\n0008 OpCall 1 12\nERROR: opcode 255 undefined\n"
+	if got := ins.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name      string
+		src       string
+		want      compiler.Instructions
+		constants []string // Inspect of each constant
+		globals   []string
+	}{
+		{
+			"arithmetic", "1 + 2",
+			concat(
+				compiler.Make(compiler.OpConstant, 0),
+				compiler.Make(compiler.OpConstant, 1),
+				compiler.Make(compiler.OpAdd),
+				compiler.Make(compiler.OpPop),
+			),
+			[]string{"1", "2"}, nil,
+		},
+		{
+			"strings are pooled once", `let s = "a" + "a"`,
+			concat(
+				compiler.Make(compiler.OpConstant, 0),
+				compiler.Make(compiler.OpConstant, 0),
+				compiler.Make(compiler.OpAdd),
+				compiler.Make(compiler.OpDefineGlobal, 0),
+			),
+			[]string{"a"}, []string{"s"},
+		},
+		{
+			"constants", "const x = -2.5",
+			concat(
+				compiler.Make(compiler.OpConstant, 0),
+				compiler.Make(compiler.OpMinus),
+				compiler.Make(compiler.OpDefineGlobalConst, 0),
+			),
+			[]string{"2.500000"}, []string{"x"},
+		},
+		{
+			"if else", "if (true) { print 1 } else { print 2 }",
+			concat(
+				compiler.Make(compiler.OpTrue),
+				compiler.Make(compiler.OpJumpNotTruthy, 11),
+				compiler.Make(compiler.OpConstant, 0),
+				compiler.Make(compiler.OpPrint),
+				compiler.Make(compiler.OpJump, 15),
+				compiler.Make(compiler.OpConstant, 1),
+				compiler.Make(compiler.OpPrint),
+			),
+			[]string{"1", "2"}, nil,
+		},
+		{
+			"while", "let i = 0\nwhile (i < 3) { i = i + 1 }",
+			concat(
+				compiler.Make(compiler.OpConstant, 0),
+				compiler.Make(compiler.OpDefineGlobal, 0),
+				compiler.Make(compiler.OpGetGlobal, 0),
+				compiler.Make(compiler.OpConstant, 1),
+				compiler.Make(compiler.OpLess),
+				compiler.Make(compiler.OpJumpNotTruthy, 30),
+				compiler.Make(compiler.OpGetGlobal, 0),
+				compiler.Make(compiler.OpConstant, 2),
+				compiler.Make(compiler.OpAdd),
+				compiler.Make(compiler.OpSetGlobal, 0),
+				compiler.Make(compiler.OpPop),
+				compiler.Make(compiler.OpLoop, 6),
+			),
+			[]string{"0", "3", "1"}, []string{"i"},
+		},
+		{
+			"collections", `let m = {"k": [1, 2]}; m.k[0] = 3`,
+			concat(
+				compiler.Make(compiler.OpConstant, 0),
+				compiler.Make(compiler.OpConstant, 1),
+				compiler.Make(compiler.OpConstant, 2),
+				compiler.Make(compiler.OpArray, 2),
+				compiler.Make(compiler.OpMap, 1),
+				compiler.Make(compiler.OpDefineGlobal, 0),
+				compiler.Make(compiler.OpConstant, 3),
+				compiler.Make(compiler.OpGetGlobal, 0),
+				compiler.Make(compiler.OpMember, 0),
+				compiler.Make(compiler.OpConstant, 4),
+				compiler.Make(compiler.OpSetIndex),
+				compiler.Make(compiler.OpPop),
+			),
+			[]string{"k", "1", "2", "3", "0"}, []string{"m"},
+		},
+		{
+			"builtin calls", "print len([])",
+			concat(
+				compiler.Make(compiler.OpGetGlobal, 0),
+				compiler.Make(compiler.OpArray, 0),
+				compiler.Make(compiler.OpCall, 1, 1),
+				compiler.Make(compiler.OpPrint),
+			),
+			nil, []string{"len"},
+		},
+		{
+			"try", `try { throw "x" } catch (e) { print e }`,
+			concat(
+				compiler.Make(compiler.OpTry, 11),
+				compiler.Make(compiler.OpConstant, 0),
+				compiler.Make(compiler.OpThrow),
+				compiler.Make(compiler.OpEndTry),
+				compiler.Make(compiler.OpJump, 18),
+				compiler.Make(compiler.OpDefineGlobal, 0),
+				compiler.Make(compiler.OpGetGlobal, 0),
+				compiler.Make(compiler.OpPrint),
+			),
+			[]string{"x"}, []string{"e"},
+		},
+		{
+			"functions", "func add(a, b) { return a + b }\nadd(1, 2)",
+			concat(
+				compiler.Make(compiler.OpClosure, 0),
+				compiler.Make(compiler.OpDefineGlobal, 0),
+				compiler.Make(compiler.OpGetGlobal, 0),
+				compiler.Make(compiler.OpConstant, 1),
+				compiler.Make(compiler.OpConstant, 2),
+				compiler.Make(compiler.OpCall, 2, 2),
+				compiler.Make(compiler.OpPop),
+			),
+			[]string{"[compiled func add]", "1", "2"}, []string{"add"},
+		},
+		{
+			"classes", "class P { func get() { return this } }",
+			concat(
+				compiler.Make(compiler.OpClosure, 0),
+				compiler.Make(compiler.OpClass, 1, 1),
+				compiler.Make(compiler.OpDefineGlobal, 0),
+			),
+			[]string{"[compiled func get]", "P"}, []string{"P"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bytecode := compile(t, tt.src)
+			if got := bytecode.Main.Instructions.String(); got != tt.want.String() {
+				t.Errorf("instructions:\n%s\nwant:\n%s", got, tt.want)
+			}
+			if got := inspect(bytecode); strings.Join(got, ", ") != strings.Join(tt.constants, ", ") {
+				t.Errorf("constants %q, want %q", got, tt.constants)
+			}
+			if strings.Join(bytecode.Globals, ", ") != strings.Join(tt.globals, ", ") {
+				t.Errorf("globals %q, want %q", bytecode.Globals, tt.globals)
+			}
+		})
+	}
+}
+
+func TestCompileFunction(t *testing.T) {
+	src := "func outer(x) {\n    let n = 1\n    return func() { return n + x }\n}"
+	bytecode := compile(t, src)
+	if got := inspect(bytecode); strings.Join(got, ", ") != "1, [compiled func ], [compiled func outer]" {
+		t.Fatalf("constants %q", got)
+	}
+	inner := bytecode.Constants[1].(*compiler.CompiledFunction)
+	outer := bytecode.Constants[2].(*compiler.CompiledFunction)
+	tests := []struct {
+		fn     *compiler.CompiledFunction
+		want   compiler.Instructions
+		locals []string
+		params int
+	}{
+		{
+			inner,
+			concat(
+				compiler.Make(compiler.OpGetFree, 1, 1),
+				compiler.Make(compiler.OpGetFree, 1, 0),
+				compiler.Make(compiler.OpAdd),
+				compiler.Make(compiler.OpReturn),
+				compiler.Make(compiler.OpReturnNull),
+			),
+			nil, 0,
+		},
+		{
+			outer,
+			concat(
+				compiler.Make(compiler.OpConstant, 0),
+				compiler.Make(compiler.OpDefineLocal, 1),
+				compiler.Make(compiler.OpClosure, 1),
+				compiler.Make(compiler.OpReturn),
+				compiler.Make(compiler.OpReturnNull),
+			),
+			[]string{"x", "n"}, 1,
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.fn.Instructions.String(); got != tt.want.String() {
+			t.Errorf("%s instructions:\n%s\nwant:\n%s", tt.fn.Inspect(), got, tt.want)
+		}
+		if strings.Join(tt.fn.Locals, ", ") != strings.Join(tt.locals, ", ") || tt.fn.NumLocals != len(tt.locals) {
+			t.Errorf("%s locals %q (%d), want %q", tt.fn.Inspect(), tt.fn.Locals, tt.fn.NumLocals, tt.locals)
+		}
+		if tt.fn.NumParams != tt.params || tt.fn.Method {
+			t.Errorf("%s has %d params, method %v", tt.fn.Inspect(), tt.fn.NumParams, tt.fn.Method)
+		}
+	}
+
+	class := compile(t, "class P { func get() { return this } }")
+	method := class.Constants[0].(*compiler.CompiledFunction)
+	want := concat(
+		compiler.Make(compiler.OpGetLocal, 0),
+		compiler.Make(compiler.OpReturn),
+		compiler.Make(compiler.OpReturnNull),
+	)
+	if !method.Method || method.Instructions.String() != want.String() {
+		t.Errorf("method %v instructions:\n%s", method.Method, method.Instructions)
+	}
+}
@@ -0,0 +1,81 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope SymbolScope = "GLOBAL"
+	LocalScope  SymbolScope = "LOCAL"
+	FreeScope   SymbolScope = "FREE"
+)
+
+// Symbol is a resolved name. Free symbols live Depth function scopes
+// out from where they are used.
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+	Depth int
+}
+
+// SymbolTable maps names to slots, one table per function with the
+// global table outermost
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store          map[string]Symbol
+	numDefinitions int
+	names          []string
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{store: make(map[string]Symbol)}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// Define gives name a slot in this table, reusing the slot of an earlier
+// definition of the same name
+func (s *SymbolTable) Define(name string) Symbol {
+	if symbol, ok := s.store[name]; ok {
+		return symbol
+	}
+	scope := LocalScope
+	if s.Outer == nil {
+		scope = GlobalScope
+	}
+	symbol := Symbol{Name: name, Scope: scope, Index: s.numDefinitions}
+	s.store[name] = symbol
+	s.names = append(s.names, name)
+	s.numDefinitions++
+	return symbol
+}
+
+// Resolve finds the symbol name refers to. Names unknown everywhere are
+// globals that may be defined later, by the script or as builtins, so
+// they get a global slot on first use.
+func (s *SymbolTable) Resolve(name string) Symbol {
+	table, depth := s, 0
+	for {
+		if symbol, ok := table.store[name]; ok {
+			if symbol.Scope == LocalScope && depth > 0 {
+				symbol.Scope = FreeScope
+				symbol.Depth = depth
+			}
+			return symbol
+		}
+		if table.Outer == nil {
+			return table.Define(name)
+		}
+		table = table.Outer
+		depth++
+	}
+}
+
+// Names lists the defined names by slot
+func (s *SymbolTable) Names() []string {
+	return s.names
+}
@@ -0,0 +1,241 @@
+// Package cover measures which statements of Tiger files run. A Profile
+// watches the tree-walker through eval.Hooks, counting each statement as
+// it starts, and reports the counts as a coverage profile, annotated
+// source or an HTML page.
+package cover
+
+import (
+	"fmt"
+	"io"
+	"tiger/go/ast"
+	"tiger/go/eval"
+	"tiger/go/lexer"
+	"tiger/go/object"
+	"tiger/go/parser"
+)
+
+// Profile holds the statement counts of a set of files
+type Profile struct {
+	files  []*File
+	byName map[string]*File
+}
+
+// File is the coverage of one Tiger file
+type File struct {
+	Name       string
+	Src        string
+	Statements []*Statement // in source order
+
+	seen map[ast.Statement]*Statement // the statements of each parse run
+}
+
+// Statement is a statement of a file and how often it ran
+type Statement struct {
+	Line  int
+	Count int
+}
+
+// New returns an empty profile
+func New() *Profile {
+	return &Profile{byName: map[string]*File{}}
+}
+
+// Add returns the coverage of the file called name, whose source is
+// src, registering it on first use
+func (p *Profile) Add(name, src string) (*File, error) {
+	if f, ok := p.byName[name]; ok {
+		return f, nil
+	}
+	parse := parser.New(lexer.New(src))
+	program := parse.ParseProgram()
+	if len(parse.Errors()) > 0 {
+		return nil, parse.ErrorList()
+	}
+	f := &File{Name: name, Src: src, seen: map[ast.Statement]*Statement{}}
+	walk(program.Statements, func(stmt ast.Statement) {
+		f.Statements = append(f.Statements, &Statement{Line: stmt.StartLine()})
+	})
+	p.files = append(p.files, f)
+	p.byName[name] = f
+	return f, nil
+}
+
+// Files returns the files of the profile, in the order they were added
+func (p *Profile) Files() []*File {
+	return p.files
+}
+
+// Covered returns how many statements of all files ran, out of how many
+func (p *Profile) Covered() (covered, total int) {
+	for _, f := range p.files {
+		c, t := f.Covered()
+		covered += c
+		total += t
+	}
+	return covered, total
+}
+
+// walk calls visit with stmts and every statement nested in them, those
+// the evaluator runs one by one from a program or block, in parse order
+func walk(stmts []ast.Statement, visit func(ast.Statement)) {
+	w := walker(visit)
+	w.statements(stmts)
+}
+
+type walker func(ast.Statement)
+
+func (w walker) statements(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		w(stmt)
+		w.nested(stmt)
+	}
+}
+
+func (w walker) nested(stmt ast.Statement) {
+	block := func(b *ast.BlockStatement) {
+		if b != nil {
+			w.statements(b.Statements)
+		}
+	}
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		w.expression(stmt.Value)
+	case *ast.ConstStatement:
+		w.expression(stmt.Value)
+	case *ast.PrintStatement:
+		w.expression(stmt.Value)
+	case *ast.ExpressionStatement:
+		w.expression(stmt.Expression)
+	case *ast.ReturnStatement:
+		w.expression(stmt.Value)
+	case *ast.ThrowStatement:
+		w.expression(stmt.Value)
+	case *ast.BlockStatement:
+		block(stmt)
+	case *ast.IfStatement:
+		w.expression(stmt.Condition)
+		block(stmt.Consequence)
+		block(stmt.Alternative)
+	case *ast.WhileStatement:
+		w.expression(stmt.Condition)
+		block(stmt.Body)
+	case *ast.ForStatement:
+		w.nested(stmt.Init)
+		w.expression(stmt.Condition)
+		w.nested(stmt.Update)
+		block(stmt.Body)
+	case *ast.ClassStatement:
+		for _, method := range stmt.Methods {
+			block(method.Body)
+		}
+	case *ast.TryStatement:
+		block(stmt.Block)
+		block(stmt.Handler)
+	}
+}
+
+// expression walks the statements of the functions written inside expr
+func (w walker) expression(expr ast.Expression) {
+	switch expr := expr.(type) {
+	case *ast.FunctionLiteral:
+		w.statements(expr.Body.Statements)
+	case *ast.PrefixExpression:
+		w.expression(expr.Right)
+	case *ast.InfixExpression:
+		w.expression(expr.Left)
+		w.expression(expr.Right)
+	case *ast.ArrayLiteral:
+		for _, el := range expr.Elements {
+			w.expression(el)
+		}
+	case *ast.MapLiteral:
+		for i, key := range expr.Keys {
+			w.expression(key)
+			w.expression(expr.Values[i])
+		}
+	case *ast.IndexExpression:
+		w.expression(expr.Left)
+		w.expression(expr.Index)
+	case *ast.MemberExpression:
+		w.expression(expr.Object)
+	case *ast.AssignExpression:
+		w.expression(expr.Target)
+		w.expression(expr.Value)
+	case *ast.CallExpression:
+		w.expression(expr.Function)
+		for _, arg := range expr.Arguments {
+			w.expression(arg)
+		}
+	}
+}
+
+// Hooks returns the evaluator hooks that count the statements of f as a
+// run of it executes
+func (f *File) Hooks() *eval.Hooks {
+	return &eval.Hooks{Program: f.program, Statement: f.statement}
+}
+
+// program matches the statements of a parse of f, in parse order, with
+// those of f, so that counts from separate parses, one per run, add up.
+// Statements are told apart by position rather than text, since the
+// same statement may be written twice on a line. Programs other than f
+// are left uncounted.
+func (f *File) program(program *ast.Program) {
+	var stmts []ast.Statement
+	walk(program.Statements, func(stmt ast.Statement) {
+		stmts = append(stmts, stmt)
+	})
+	if len(stmts) != len(f.Statements) {
+		return
+	}
+	for i, stmt := range stmts {
+		if stmt.StartLine() != f.Statements[i].Line {
+			return
+		}
+	}
+	for i, stmt := range stmts {
+		f.seen[stmt] = f.Statements[i]
+	}
+}
+
+func (f *File) statement(stmt ast.Statement, env *eval.Environment) *object.Error {
+	if s := f.seen[stmt]; s != nil {
+		s.Count++
+	}
+	return nil
+}
+
+// Covered returns how many statements of f ran, out of how many
+func (f *File) Covered() (covered, total int) {
+	for _, s := range f.Statements {
+		if s.Count > 0 {
+			covered++
+		}
+	}
+	return covered, len(f.Statements)
+}
+
+// Percent returns covered as a percentage of total; with no statements
+// there is nothing left uncovered
+func Percent(covered, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return 100 * float64(covered) / float64(total)
+}
+
+// WriteProfile writes the counts as a coverage profile: a mode line, then
+// a line per statement giving its file, line and count
+func (p *Profile) WriteProfile(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "mode: count"); err != nil {
+		return err
+	}
+	for _, f := range p.files {
+		for _, s := range f.Statements {
+			if _, err := fmt.Fprintf(w, "%s:%d %d\n", f.Name, s.Line, s.Count); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package cover_test
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"tiger/go/cover"
+	"tiger/go/tiger"
+)
+
+func TestCounts(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		runs int
+		want []int // the count of each statement, in source order
+	}{
+		{
+			name: "straight line",
+			src:  "let x = 1\nprint x\n",
+			runs: 1,
+			want: []int{1, 1},
+		},
+		{
+			name: "branch not taken",
+			src:  "let x = 0\nif (x > 0) {\n    x = 1\n} else {\n    x = 2\n}\n",
+			runs: 1,
+			want: []int{1, 1, 0, 1},
+		},
+		{
+			name: "identical statements on a line",
+			src:  "let x = 0\nlet c = true\nif (c) { x = 1; } else { x = 1; }\n",
+			runs: 1,
+			want: []int{1, 1, 1, 1, 0},
+		},
+		{
+			name: "loops and functions",
+			src:  "func f(n) {\n    return n * 2\n}\nlet i = 0\nwhile (i < 3) {\n    f(i)\n    i = i + 1\n}\n",
+			runs: 1,
+			want: []int{1, 3, 1, 1, 3, 3},
+		},
+		{
+			name: "runs of separate parses add up",
+			src:  "let x = 1\nif (x > 1) { print x }\n",
+			runs: 3,
+			want: []int{3, 3, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := cover.New()
+			f, err := p.Add("test.tg", tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < tt.runs; i++ {
+				interp := tiger.New()
+				interp.Stdout = io.Discard
+				interp.Hooks = f.Hooks()
+				if _, err := interp.Run(tt.src); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var got []int
+			for _, s := range f.Statements {
+				got = append(got, s.Count)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("counts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOtherProgramsAreNotCounted(t *testing.T) {
+	p := cover.New()
+	f, err := p.Add("a.tg", "let x = 1\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	interp := tiger.New()
+	interp.Hooks = f.Hooks()
+	if _, err := interp.Run("let y = 2\nlet z = 3\n"); err != nil {
+		t.Fatal(err)
+	}
+	if covered, total := f.Covered(); covered != 0 || total != 1 {
+		t.Errorf("covered %d of %d, want 0 of 1", covered, total)
+	}
+}
+
+func TestWriteProfile(t *testing.T) {
+	p := cover.New()
+	src := "let x = 1\nif (x > 1) {\n    print x\n}\n"
+	f, _ := p.Add("a.tg", src)
+	interp := tiger.New()
+	interp.Hooks = f.Hooks()
+	interp.Run(src)
+	p.Add("b.tg", "print 1\n")
+
+	var out strings.Builder
+	if err := p.WriteProfile(&out); err != nil {
+		t.Fatal(err)
+	}
+	want := "mode: count\na.tg:1 1\na.tg:2 1\na.tg:3 0\nb.tg:1 0\n"
+	if out.String() != want {
+		t.Errorf("profile:\n%s\nwant:\n%s", out.String(), want)
+	}
+	if covered, total := p.Covered(); covered != 2 || total != 4 {
+		t.Errorf("covered %d of %d, want 2 of 4", covered, total)
+	}
+}
+
+func TestPercent(t *testing.T) {
+	tests := []struct {
+		covered, total int
+		want           float64
+	}{
+		{0, 0, 100},
+		{0, 4, 0},
+		{1, 4, 25},
+		{4, 4, 100},
+	}
+	for _, tt := range tests {
+		if got := cover.Percent(tt.covered, tt.total); got != tt.want {
+			t.Errorf("Percent(%d, %d) = %v, want %v", tt.covered, tt.total, got, tt.want)
+		}
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	p := cover.New()
+	src := "let x = 1\nif (x > 1) { x = 2; } else { x = 3; }\nif (x > 5) {\n    print x\n}\n"
+	f, _ := p.Add("a.tg", src)
+	interp := tiger.New()
+	interp.Hooks = f.Hooks()
+	interp.Run(src)
+
+	var out strings.Builder
+	f.WriteText(&out)
+	want := []string{
+		"a.tg: 66.7% of 6 statements",
+		"       1      1  let x = 1",
+		"      1*      2  if (x > 1) { x = 2; } else { x = 3; }",
+		"       1      3  if (x > 5) {",
+		"   #####      4      print x",
+		"              5  }",
+	}
+	if got := strings.Split(strings.TrimRight(out.String(), "\n"), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("report:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,147 @@
+package cover
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// How much of a line ran, judged by the statements starting on it
+type lineStatus int
+
+const (
+	noStatement lineStatus = iota
+	uncovered              // none of its statements ran
+	partial                // some of its statements ran
+	covered                // all of its statements ran
+)
+
+// lineCoverage is what ran of one line
+type lineCoverage struct {
+	status lineStatus
+	count  int // runs of the line's first statement
+}
+
+// lines returns the coverage of each line of f that starts a statement
+func (f *File) lines() map[int]*lineCoverage {
+	lines := map[int]*lineCoverage{}
+	for _, s := range f.Statements {
+		l, ok := lines[s.Line]
+		if !ok {
+			l = &lineCoverage{status: covered, count: s.Count}
+			if s.Count == 0 {
+				l.status = uncovered
+			}
+			lines[s.Line] = l
+			continue
+		}
+		if (s.Count == 0) != (l.status == uncovered) {
+			l.status = partial
+		}
+	}
+	return lines
+}
+
+// Summary describes the coverage of f in a line
+func (f *File) Summary() string {
+	c, t := f.Covered()
+	return fmt.Sprintf("%s: %.1f%% of %d statements", f.Name, Percent(c, t), t)
+}
+
+// WriteText writes the source of f with the runs of each line beside
+// it: ##### marks a line whose statements never ran and a trailing *
+// one where only some did
+func (f *File) WriteText(w io.Writer) {
+	fmt.Fprintln(w, f.Summary())
+	lines := f.lines()
+	for n, text := range strings.Split(strings.TrimSuffix(f.Src, "\n"), "\n") {
+		runs := ""
+		if l, ok := lines[n+1]; ok {
+			switch l.status {
+			case uncovered:
+				runs = "#####"
+			case partial:
+				runs = fmt.Sprintf("%d*", l.count)
+			default:
+				runs = fmt.Sprint(l.count)
+			}
+		}
+		fmt.Fprintf(w, "%8s  %5d  %s\n", runs, n+1, text)
+	}
+}
+
+// WriteHTML writes a page showing the source of every file, with the
+// lines that ran in green and those that did not in red
+func (p *Profile) WriteHTML(w io.Writer) error {
+	page := htmlPage{}
+	for _, f := range p.files {
+		c, t := f.Covered()
+		file := htmlFile{Name: f.Name, Percent: fmt.Sprintf("%.1f%%", Percent(c, t))}
+		lines := f.lines()
+		for n, text := range strings.Split(strings.TrimSuffix(f.Src, "\n"), "\n") {
+			line := htmlLine{Number: n + 1, Text: text}
+			if l, ok := lines[n+1]; ok {
+				line.Class = [...]string{"", "uncov", "partial", "cov"}[l.status]
+				line.Title = fmt.Sprintf("%d runs", l.count)
+			}
+			file.Lines = append(file.Lines, line)
+		}
+		page.Files = append(page.Files, file)
+	}
+	return htmlTemplate.Execute(w, page)
+}
+
+type htmlPage struct {
+	Files []htmlFile
+}
+
+type htmlFile struct {
+	Name    string
+	Percent string
+	Lines   []htmlLine
+}
+
+type htmlLine struct {
+	Number int
+	Text   string
+	Class  string
+	Title  string
+}
+
+var htmlTemplate = template.Must(template.New("cover").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Tiger coverage</title>
+<style>
+body { background: #1e1e1e; color: #ccc; font-family: sans-serif; margin: 0; }
+#nav { padding: 8px; background: #2d2d2d; }
+#legend { margin-left: 16px; }
+pre { font-family: Menlo, Consolas, monospace; margin: 0; padding: 8px; }
+.num { color: #666; display: inline-block; width: 4em; text-align: right; margin-right: 1em; user-select: none; }
+.cov { color: #6c6; }
+.partial { color: #db4; }
+.uncov { color: #e55; }
+</style>
+</head>
+<body>
+<div id="nav">
+<select id="files" onchange="show(this.value)">
+{{range $i, $f := .Files}}<option value="file{{$i}}">{{$f.Name}} ({{$f.Percent}})</option>
+{{end}}</select>
+<span id="legend"><span class="cov">covered</span> <span class="partial">partly covered</span> <span class="uncov">not covered</span> <span>not a statement</span></span>
+</div>
+{{range $i, $f := .Files}}<pre id="file{{$i}}" class="file"{{if $i}} style="display: none"{{end}}>
+{{range $f.Lines}}<span class="num">{{.Number}}</span>{{if .Class}}<span class="{{.Class}}" title="{{.Title}}">{{.Text}}</span>{{else}}{{.Text}}{{end}}
+{{end}}</pre>
+{{end}}<script>
+function show(id) {
+	for (const pre of document.querySelectorAll(".file")) {
+		pre.style.display = pre.id == id ? "" : "none";
+	}
+}
+</script>
+</body>
+</html>
+`))
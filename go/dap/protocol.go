@@ -0,0 +1,119 @@
+package dap
+
+import "encoding/json"
+
+// The subset of the Debug Adapter Protocol the adapter speaks
+
+type request struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type response struct {
+	Seq        int         `json:"seq"`
+	Type       string      `json:"type"`
+	RequestSeq int         `json:"request_seq"`
+	Success    bool        `json:"success"`
+	Command    string      `json:"command"`
+	Message    string      `json:"message,omitempty"`
+	Body       interface{} `json:"body,omitempty"`
+}
+
+type event struct {
+	Seq   int         `json:"seq"`
+	Type  string      `json:"type"`
+	Event string      `json:"event"`
+	Body  interface{} `json:"body,omitempty"`
+}
+
+type InitializeArguments struct {
+	LinesStartAt1 *bool `json:"linesStartAt1"`
+}
+
+type Capabilities struct {
+	SupportsConfigurationDoneRequest bool `json:"supportsConfigurationDoneRequest"`
+	SupportsEvaluateForHovers        bool `json:"supportsEvaluateForHovers"`
+	SupportsTerminateRequest         bool `json:"supportsTerminateRequest"`
+}
+
+type LaunchArguments struct {
+	Program     string `json:"program"`
+	StopOnEntry bool   `json:"stopOnEntry"`
+	NoDebug     bool   `json:"noDebug"`
+}
+
+type Source struct {
+	Name string `json:"name,omitempty"`
+	Path string `json:"path,omitempty"`
+}
+
+type SourceBreakpoint struct {
+	Line int `json:"line"`
+}
+
+type SetBreakpointsArguments struct {
+	Source      Source             `json:"source"`
+	Breakpoints []SourceBreakpoint `json:"breakpoints"`
+}
+
+type Breakpoint struct {
+	Verified bool   `json:"verified"`
+	Line     int    `json:"line,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+type Thread struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type StackFrame struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Source Source `json:"source"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+type FrameArguments struct {
+	FrameID int `json:"frameId"`
+}
+
+type Scope struct {
+	Name               string `json:"name"`
+	VariablesReference int    `json:"variablesReference"`
+	Expensive          bool   `json:"expensive"`
+}
+
+type VariablesArguments struct {
+	VariablesReference int `json:"variablesReference"`
+}
+
+type Variable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	Type               string `json:"type,omitempty"`
+	VariablesReference int    `json:"variablesReference"`
+}
+
+type EvaluateArguments struct {
+	Expression string `json:"expression"`
+	FrameID    *int   `json:"frameId"`
+}
+
+type StoppedEvent struct {
+	Reason            string `json:"reason"`
+	ThreadID          int    `json:"threadId"`
+	AllThreadsStopped bool   `json:"allThreadsStopped"`
+}
+
+type OutputEvent struct {
+	Category string `json:"category"`
+	Output   string `json:"output"`
+}
+
+type ExitedEvent struct {
+	ExitCode int `json:"exitCode"`
+}
@@ -0,0 +1,431 @@
+// Package dap is a Debug Adapter Protocol server for Tiger, used by
+// editors through `tiger dap`. It launches a program on the
+// tree-walker under a debugger.Debugger and reports where it stops,
+// its call stack and its variables.
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"tiger/go/debugger"
+	"tiger/go/eval"
+	"tiger/go/object"
+	"tiger/go/tiger"
+	"tiger/go/wire"
+)
+
+// threadID names the only thread a Tiger program has
+const threadID = 1
+
+type server struct {
+	in *bufio.Reader
+
+	writeMu sync.Mutex
+	out     io.Writer
+	seq     int
+
+	lineBase int // 1 when the client counts lines from 1
+
+	program string
+	src     string
+	noDebug bool
+	d       *debugger.Debugger
+	started bool
+	quit    chan struct{} // closed to end the program
+	done    chan struct{} // closed once the program has ended
+
+	// Set while the program is paused; resume wakes it
+	mu     sync.Mutex
+	pause  *debugger.Pause
+	frames []debugger.Frame
+	refs   []interface{} // variable containers, by reference minus 1
+	resume chan debugger.Command
+}
+
+// Serve runs the adapter on the requests read from r, writing responses
+// and events to w, until the client disconnects
+func Serve(r io.Reader, w io.Writer) error {
+	s := &server{
+		in:       bufio.NewReader(r),
+		out:      w,
+		lineBase: 1,
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+		resume:   make(chan debugger.Command),
+	}
+	for {
+		body, err := wire.Read(s.in)
+		if err == io.EOF {
+			s.stop()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			return err
+		}
+		result, err := s.handle(&req)
+		if err != nil {
+			s.send(&response{Type: "response", RequestSeq: req.Seq, Command: req.Command, Message: err.Error()})
+		} else {
+			s.send(&response{Type: "response", RequestSeq: req.Seq, Command: req.Command, Success: true, Body: result})
+		}
+		s.after(&req)
+		if req.Command == "disconnect" {
+			return nil
+		}
+	}
+}
+
+// send writes a response or event, numbering it
+func (s *server) send(msg interface{}) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.seq++
+	switch msg := msg.(type) {
+	case *response:
+		msg.Seq = s.seq
+	case *event:
+		msg.Seq = s.seq
+	}
+	wire.Write(s.out, msg)
+}
+
+func (s *server) event(name string, body interface{}) {
+	s.send(&event{Type: "event", Event: name, Body: body})
+}
+
+var errNotPaused = errors.New("the program is not paused")
+
+// handle answers one request
+func (s *server) handle(req *request) (interface{}, error) {
+	switch req.Command {
+	case "initialize":
+		var args InitializeArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		if args.LinesStartAt1 != nil && !*args.LinesStartAt1 {
+			s.lineBase = 0
+		}
+		return Capabilities{
+			SupportsConfigurationDoneRequest: true,
+			SupportsEvaluateForHovers:        true,
+			SupportsTerminateRequest:         true,
+		}, nil
+
+	case "launch":
+		var args LaunchArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		src, err := os.ReadFile(args.Program)
+		if err != nil {
+			return nil, err
+		}
+		s.program, s.src, s.noDebug = args.Program, string(src), args.NoDebug
+		s.d = debugger.New(s.src)
+		s.d.StopOnEntry = args.StopOnEntry
+		s.d.Paused = s.paused
+		return nil, nil
+
+	case "setBreakpoints":
+		var args SetBreakpointsArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"breakpoints": s.setBreakpoints(args)}, nil
+
+	case "setExceptionBreakpoints":
+		return nil, nil
+
+	case "configurationDone":
+		if s.d == nil {
+			return nil, errors.New("no program launched")
+		}
+		return nil, nil
+
+	case "threads":
+		return map[string]interface{}{"threads": []Thread{{ID: threadID, Name: "main"}}}, nil
+
+	case "stackTrace":
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.pause == nil {
+			return nil, errNotPaused
+		}
+		source := Source{Name: filepath.Base(s.program), Path: s.program}
+		frames := make([]StackFrame, len(s.frames))
+		for i, frame := range s.frames {
+			frames[i] = StackFrame{ID: i + 1, Name: frame.Function, Source: source, Line: frame.Line - 1 + s.lineBase, Column: 1}
+		}
+		return map[string]interface{}{"stackFrames": frames, "totalFrames": len(frames)}, nil
+
+	case "scopes":
+		var args FrameArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.pause == nil {
+			return nil, errNotPaused
+		}
+		frame := args.FrameID - 1
+		if frame < 0 || frame >= len(s.frames) {
+			return nil, fmt.Errorf("no frame %d", args.FrameID)
+		}
+		scopes := []Scope{{Name: "Globals", VariablesReference: s.ref(s.pause.Globals())}}
+		if frame < len(s.frames)-1 {
+			locals := Scope{Name: "Locals", VariablesReference: s.ref(s.pause.Locals(frame))}
+			scopes = append([]Scope{locals}, scopes...)
+		}
+		return map[string]interface{}{"scopes": scopes}, nil
+
+	case "variables":
+		var args VariablesArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.pause == nil {
+			return nil, errNotPaused
+		}
+		if args.VariablesReference < 1 || args.VariablesReference > len(s.refs) {
+			return nil, fmt.Errorf("no variables %d", args.VariablesReference)
+		}
+		return map[string]interface{}{"variables": s.children(s.refs[args.VariablesReference-1])}, nil
+
+	case "evaluate":
+		var args EvaluateArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.pause == nil {
+			return nil, errNotPaused
+		}
+		frame := 0
+		if args.FrameID != nil {
+			frame = *args.FrameID - 1
+		}
+		val, err := s.pause.Evaluate(args.Expression, frame)
+		if err != nil {
+			return nil, err
+		}
+		v := s.variable("", val)
+		return map[string]interface{}{"result": v.Value, "type": v.Type, "variablesReference": v.VariablesReference}, nil
+
+	case "continue", "next", "stepIn", "stepOut":
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.pause == nil {
+			return nil, errNotPaused
+		}
+		if req.Command == "continue" {
+			return map[string]interface{}{"allThreadsContinued": true}, nil
+		}
+		return nil, nil
+
+	case "pause":
+		if s.d != nil {
+			s.d.RequestPause()
+		}
+		return nil, nil
+
+	case "terminate", "disconnect":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported request %s", req.Command)
+}
+
+// after acts on a request once its response is sent, so that events it
+// causes follow the response
+func (s *server) after(req *request) {
+	switch req.Command {
+	case "launch":
+		if s.d != nil {
+			s.event("initialized", nil)
+		}
+	case "configurationDone":
+		if s.d != nil && !s.started {
+			s.started = true
+			go s.run()
+		}
+	case "continue":
+		s.wake(debugger.Continue)
+	case "next":
+		s.wake(debugger.StepOver)
+	case "stepIn":
+		s.wake(debugger.StepIn)
+	case "stepOut":
+		s.wake(debugger.StepOut)
+	case "terminate", "disconnect":
+		s.stop()
+	}
+}
+
+// run executes the program, reporting its output and exit
+func (s *server) run() {
+	interp := tiger.New()
+	interp.Stdout = &output{s, "stdout"}
+	interp.Stderr = &output{s, "stderr"}
+	if !s.noDebug {
+		interp.Hooks = s.d.Hooks()
+	}
+	code := 0
+	if _, err := interp.Run(s.src); err != nil {
+		code = 1
+	}
+	s.event("exited", ExitedEvent{ExitCode: code})
+	s.event("terminated", nil)
+	close(s.done)
+}
+
+// output sends what the program writes as output events
+type output struct {
+	s        *server
+	category string
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.s.event("output", OutputEvent{Category: o.category, Output: string(p)})
+	return len(p), nil
+}
+
+// paused reports a stop to the client and waits until it resumes the
+// program. It runs on the program's goroutine.
+func (s *server) paused(p *debugger.Pause) debugger.Command {
+	s.mu.Lock()
+	s.pause, s.frames, s.refs = p, p.Frames(), nil
+	s.mu.Unlock()
+	s.event("stopped", StoppedEvent{Reason: p.Reason, ThreadID: threadID, AllThreadsStopped: true})
+	var command debugger.Command
+	select {
+	case command = <-s.resume:
+	case <-s.quit:
+		command = debugger.Quit
+	}
+	s.mu.Lock()
+	s.pause, s.frames, s.refs = nil, nil, nil
+	s.mu.Unlock()
+	return command
+}
+
+// wake resumes a paused program
+func (s *server) wake(command debugger.Command) {
+	s.mu.Lock()
+	paused := s.pause != nil
+	s.mu.Unlock()
+	if paused {
+		s.resume <- command
+	}
+}
+
+// stop ends the program, if it is running, and waits for it
+func (s *server) stop() {
+	if !s.started {
+		return
+	}
+	select {
+	case <-s.quit:
+	default:
+		close(s.quit)
+	}
+	s.d.Terminate()
+	<-s.done
+}
+
+func (s *server) setBreakpoints(args SetBreakpointsArguments) []Breakpoint {
+	breakpoints := make([]Breakpoint, len(args.Breakpoints))
+	if s.d == nil || !samePath(args.Source.Path, s.program) {
+		for i := range breakpoints {
+			breakpoints[i].Message = "not the launched program"
+		}
+		return breakpoints
+	}
+	s.d.ClearBreakpoints()
+	for i, bp := range args.Breakpoints {
+		if line, ok := s.d.SetBreakpoint(bp.Line + 1 - s.lineBase); ok {
+			breakpoints[i] = Breakpoint{Verified: true, Line: line - 1 + s.lineBase}
+		} else {
+			breakpoints[i].Message = "no statement at or after this line"
+		}
+	}
+	return breakpoints
+}
+
+func samePath(a, b string) bool {
+	a, errA := filepath.Abs(a)
+	b, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && filepath.Clean(a) == filepath.Clean(b)
+}
+
+// ref returns a variables reference for a scope or a value with
+// members, valid until the program resumes
+func (s *server) ref(container interface{}) int {
+	s.refs = append(s.refs, container)
+	return len(s.refs)
+}
+
+// children lists the variables of a scope, the elements of an array or
+// the entries of a map or instance
+func (s *server) children(container interface{}) []Variable {
+	variables := []Variable{}
+	switch c := container.(type) {
+	case map[string]object.Object:
+		names := make([]string, 0, len(c))
+		for name := range c {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			variables = append(variables, s.variable(name, c[name]))
+		}
+	case *object.Array:
+		for i, el := range c.Elements {
+			variables = append(variables, s.variable("["+strconv.Itoa(i)+"]", el))
+		}
+	case *object.Map:
+		for _, key := range c.Keys {
+			variables = append(variables, s.variable(key, c.Pairs[key]))
+		}
+	}
+	return variables
+}
+
+// variable describes a value, giving arrays, maps and instances a
+// reference to their members
+func (s *server) variable(name string, val object.Object) Variable {
+	v := Variable{Name: name, Value: val.Inspect(), Type: string(val.Type())}
+	switch val := val.(type) {
+	case *object.String:
+		v.Value = strconv.Quote(val.Value)
+	case *object.Array:
+		if len(val.Elements) > 0 {
+			v.VariablesReference = s.ref(val)
+		}
+	case *object.Map:
+		if len(val.Keys) > 0 {
+			v.VariablesReference = s.ref(val)
+		}
+	case *eval.Instance:
+		v.Type = val.Class.Name
+		if len(val.Fields.Keys) > 0 {
+			v.VariablesReference = s.ref(val.Fields)
+		}
+	}
+	return v
+}
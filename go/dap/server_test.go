@@ -0,0 +1,266 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"tiger/go/wire"
+	"time"
+)
+
+const countProgram = `func count(n) {
+    let total = 0
+    let i = 0
+    while (i < n) {
+        total = total + i; i = i + 1
+    }
+    return total
+}
+print count(3)
+`
+
+// message is a response or event as the client decodes it
+type message struct {
+	Type       string          `json:"type"`
+	Event      string          `json:"event"`
+	Command    string          `json:"command"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// client drives a server over pipes, as an editor would
+type client struct {
+	t        *testing.T
+	w        io.Writer
+	seq      int
+	incoming chan message
+	backlog  []message // read while waiting for something else
+}
+
+func newClient(t *testing.T) *client {
+	toServer, fromClient := io.Pipe()
+	toClient, fromServer := io.Pipe()
+	c := &client{t: t, w: fromClient, incoming: make(chan message, 100)}
+	served := make(chan struct{})
+	go func() {
+		Serve(toServer, fromServer)
+		fromServer.Close()
+		close(served)
+	}()
+	go func() {
+		in := bufio.NewReader(toClient)
+		for {
+			body, err := wire.Read(in)
+			if err != nil {
+				close(c.incoming)
+				return
+			}
+			var msg message
+			if err := json.Unmarshal(body, &msg); err != nil {
+				t.Error(err)
+			}
+			c.incoming <- msg
+		}
+	}()
+	t.Cleanup(func() {
+		fromClient.Close()
+		<-served
+	})
+	return c
+}
+
+// request sends a request and decodes its response body into body,
+// failing the test if it did not succeed
+func (c *client) request(command string, args interface{}, body interface{}) {
+	c.t.Helper()
+	c.seq++
+	seq := c.seq
+	if err := wire.Write(c.w, map[string]interface{}{"seq": seq, "type": "request", "command": command, "arguments": args}); err != nil {
+		c.t.Fatal(err)
+	}
+	resp := c.expect(func(m message) bool { return m.Type == "response" && m.RequestSeq == seq })
+	if !resp.Success {
+		c.t.Fatalf("%s failed: %s", command, resp.Message)
+	}
+	if body != nil {
+		if err := json.Unmarshal(resp.Body, body); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+}
+
+// event waits for the next event called name and decodes its body
+func (c *client) event(name string, body interface{}) {
+	c.t.Helper()
+	msg := c.expect(func(m message) bool { return m.Type == "event" && m.Event == name })
+	if body != nil {
+		if err := json.Unmarshal(msg.Body, body); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+}
+
+// expect returns the first message, in the order sent, that match
+// accepts; the others are kept for later
+func (c *client) expect(match func(message) bool) message {
+	c.t.Helper()
+	for i, msg := range c.backlog {
+		if match(msg) {
+			c.backlog = append(c.backlog[:i], c.backlog[i+1:]...)
+			return msg
+		}
+	}
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg, ok := <-c.incoming:
+			if !ok {
+				c.t.Fatal("server closed the connection")
+			}
+			if match(msg) {
+				return msg
+			}
+			c.backlog = append(c.backlog, msg)
+		case <-timeout:
+			c.t.Fatalf("timed out; received %+v", c.backlog)
+		}
+	}
+}
+
+// stoppedAt waits for a stop and returns the line of the top frame and
+// the locals of its function
+func (c *client) stoppedAt(reason string) (int, map[string]string) {
+	c.t.Helper()
+	var stopped StoppedEvent
+	c.event("stopped", &stopped)
+	if stopped.Reason != reason {
+		c.t.Errorf("stopped for %q, want %q", stopped.Reason, reason)
+	}
+
+	var trace struct{ StackFrames []StackFrame }
+	c.request("stackTrace", map[string]int{"threadId": threadID}, &trace)
+	if len(trace.StackFrames) == 0 {
+		c.t.Fatal("empty stack trace")
+	}
+	top := trace.StackFrames[0]
+
+	var scopes struct{ Scopes []Scope }
+	c.request("scopes", FrameArguments{FrameID: top.ID}, &scopes)
+	locals := map[string]string{}
+	for _, scope := range scopes.Scopes {
+		if scope.Name != "Locals" {
+			continue
+		}
+		var vars struct{ Variables []Variable }
+		c.request("variables", VariablesArguments{VariablesReference: scope.VariablesReference}, &vars)
+		for _, v := range vars.Variables {
+			locals[v.Name] = v.Value
+		}
+	}
+	return top.Line, locals
+}
+
+func TestSession(t *testing.T) {
+	program := filepath.Join(t.TempDir(), "count.tg")
+	if err := os.WriteFile(program, []byte(countProgram), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := newClient(t)
+
+	var caps Capabilities
+	c.request("initialize", map[string]interface{}{"linesStartAt1": true}, &caps)
+	if !caps.SupportsConfigurationDoneRequest {
+		t.Error("configurationDone not supported")
+	}
+	c.request("launch", LaunchArguments{Program: program}, nil)
+	c.event("initialized", nil)
+
+	var set struct{ Breakpoints []Breakpoint }
+	c.request("setBreakpoints", SetBreakpointsArguments{
+		Source:      Source{Path: program},
+		Breakpoints: []SourceBreakpoint{{Line: 5}},
+	}, &set)
+	if len(set.Breakpoints) != 1 || !set.Breakpoints[0].Verified || set.Breakpoints[0].Line != 5 {
+		t.Fatalf("breakpoints = %+v", set.Breakpoints)
+	}
+	c.request("configurationDone", nil, nil)
+
+	// The breakpoint is on the one-line body of the loop, so it stops
+	// on every pass
+	for pass := 0; pass < 3; pass++ {
+		line, locals := c.stoppedAt("breakpoint")
+		if line != 5 {
+			t.Errorf("pass %d: stopped at line %d, want 5", pass, line)
+		}
+		if want := []string{"0", "1", "2"}[pass]; locals["i"] != want {
+			t.Errorf("pass %d: i = %q, want %q", pass, locals["i"], want)
+		}
+		c.request("continue", map[string]int{"threadId": threadID}, nil)
+	}
+
+	var exited ExitedEvent
+	c.event("exited", &exited)
+	if exited.ExitCode != 0 {
+		t.Errorf("exit code %d", exited.ExitCode)
+	}
+	var out OutputEvent
+	c.event("output", &out)
+	if out.Output != "3\n" {
+		t.Errorf("output %q, want %q", out.Output, "3\n")
+	}
+	c.event("terminated", nil)
+	c.request("disconnect", nil, nil)
+}
+
+func TestStepping(t *testing.T) {
+	program := filepath.Join(t.TempDir(), "count.tg")
+	if err := os.WriteFile(program, []byte(countProgram), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := newClient(t)
+	c.request("initialize", map[string]interface{}{}, nil)
+	c.request("launch", LaunchArguments{Program: program, StopOnEntry: true}, nil)
+	c.event("initialized", nil)
+	c.request("configurationDone", nil, nil)
+
+	tests := []struct {
+		reason string
+		line   int
+		next   string // request resuming from the stop
+	}{
+		{"entry", 1, "next"},
+		{"step", 9, "stepIn"},
+		{"step", 2, "next"},
+		{"step", 3, "next"},
+		{"step", 4, "next"},
+		{"step", 5, "next"},
+		{"step", 5, "next"},
+		{"step", 5, "stepOut"},
+	}
+	for _, tt := range tests {
+		line, _ := c.stoppedAt(tt.reason)
+		if line != tt.line {
+			t.Fatalf("stopped at line %d, want %d", line, tt.line)
+		}
+		c.request(tt.next, map[string]int{"threadId": threadID}, nil)
+	}
+	c.event("exited", nil)
+	c.request("disconnect", nil, nil)
+}
+
+func TestNotPaused(t *testing.T) {
+	c := newClient(t)
+	c.request("initialize", map[string]interface{}{}, nil)
+	c.seq++
+	wire.Write(c.w, map[string]interface{}{"seq": c.seq, "type": "request", "command": "stackTrace"})
+	seq := c.seq
+	resp := c.expect(func(m message) bool { return m.Type == "response" && m.RequestSeq == seq })
+	if resp.Success || resp.Message != errNotPaused.Error() {
+		t.Errorf("stackTrace while running: %+v", resp)
+	}
+}
@@ -0,0 +1,163 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"tiger/go/object"
+)
+
+const consoleHelp = `Commands:
+  c, continue        run until the next breakpoint
+  s, step            step to the next statement, entering calls
+  n, next            step to the next statement, over calls
+  o, out             run until the current function returns
+  b, break LINE      set a breakpoint
+  clear LINE         remove a breakpoint
+  breakpoints        list breakpoints
+  bt, stack          show the call stack
+  f, frame N         select frame N of the stack
+  locals             show the variables of the selected frame
+  globals            show the global variables
+  p, print EXPR      evaluate an expression in the selected frame
+  l, list            show the source around the selected frame
+  q, quit            stop the program
+  h, help            show this help`
+
+// Console is the line-based front end of `tiger debug`. Once its input
+// ends, the program runs to completion without stopping.
+type Console struct {
+	d     *Debugger
+	lines []string
+	in    *bufio.Scanner
+	out   io.Writer
+
+	frame int  // selected frame of the current pause
+	done  bool // input has ended
+}
+
+// NewConsole drives d from commands read from in, writing to out. Src
+// is the program's source, for listings.
+func NewConsole(d *Debugger, src string, in io.Reader, out io.Writer) *Console {
+	c := &Console{d: d, lines: strings.Split(src, "\n"), in: bufio.NewScanner(in), out: out}
+	d.Paused = c.paused
+	return c
+}
+
+func (c *Console) paused(p *Pause) Command {
+	if c.done {
+		return Continue
+	}
+	c.frame = 0
+	frames := p.Frames()
+	fmt.Fprintf(c.out, "Stopped at line %d in %s (%s)\n", p.Line, frames[0].Function, p.Reason)
+	c.showLine(p.Line, true)
+
+	for {
+		fmt.Fprint(c.out, "(tdb) ")
+		if !c.in.Scan() {
+			fmt.Fprintln(c.out)
+			c.done = true
+			return Continue
+		}
+		command, arg, _ := strings.Cut(strings.TrimSpace(c.in.Text()), " ")
+		arg = strings.TrimSpace(arg)
+		switch command {
+		case "":
+		case "c", "continue":
+			return Continue
+		case "s", "step":
+			return StepIn
+		case "n", "next":
+			return StepOver
+		case "o", "out", "finish":
+			return StepOut
+		case "q", "quit":
+			return Quit
+		case "b", "break":
+			line, err := strconv.Atoi(arg)
+			if err != nil {
+				fmt.Fprintln(c.out, "usage: break LINE")
+				continue
+			}
+			if at, ok := c.d.SetBreakpoint(line); ok {
+				fmt.Fprintf(c.out, "Breakpoint at line %d\n", at)
+			} else {
+				fmt.Fprintf(c.out, "No statement at or after line %d\n", line)
+			}
+		case "clear":
+			line, err := strconv.Atoi(arg)
+			if err != nil {
+				fmt.Fprintln(c.out, "usage: clear LINE")
+				continue
+			}
+			c.d.ClearBreakpoint(line)
+		case "breakpoints":
+			for _, line := range c.d.Breakpoints() {
+				fmt.Fprintf(c.out, "line %d\n", line)
+			}
+		case "bt", "stack":
+			for i, frame := range frames {
+				marker := " "
+				if i == c.frame {
+					marker = "*"
+				}
+				fmt.Fprintf(c.out, "%s#%d %s at line %d\n", marker, i, frame.Function, frame.Line)
+			}
+		case "f", "frame":
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 0 || n >= len(frames) {
+				fmt.Fprintf(c.out, "frame must be 0 to %d\n", len(frames)-1)
+				continue
+			}
+			c.frame = n
+			fmt.Fprintf(c.out, "#%d %s at line %d\n", n, frames[n].Function, frames[n].Line)
+		case "locals":
+			c.showVars(p.Locals(c.frame))
+		case "globals":
+			c.showVars(p.Globals())
+		case "p", "print":
+			val, err := p.Evaluate(arg, c.frame)
+			if err != nil {
+				fmt.Fprintln(c.out, "error:", err)
+				continue
+			}
+			fmt.Fprintln(c.out, val.Inspect())
+		case "l", "list":
+			line := frames[c.frame].Line
+			for l := max(line-3, 1); l <= min(line+3, len(c.lines)); l++ {
+				c.showLine(l, l == line)
+			}
+		case "h", "help":
+			fmt.Fprintln(c.out, consoleHelp)
+		default:
+			fmt.Fprintf(c.out, "unknown command %q; type help for a list\n", command)
+		}
+	}
+}
+
+// showLine prints a source line, marked when it is the current one
+func (c *Console) showLine(line int, current bool) {
+	if line < 1 || line > len(c.lines) {
+		return
+	}
+	marker := "  "
+	if current {
+		marker = "=>"
+	}
+	fmt.Fprintf(c.out, "%s %4d  %s\n", marker, line, c.lines[line-1])
+}
+
+func (c *Console) showVars(vars map[string]object.Object) {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(c.out, "%s = %s\n", name, vars[name].Inspect())
+	}
+}
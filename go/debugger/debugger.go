@@ -0,0 +1,324 @@
+// Package debugger pauses Tiger programs at breakpoints and steps
+// through them. It drives the tree-walker through eval.Hooks, so the
+// program runs on the usual interpreter; front ends such as the console
+// of `tiger debug` decide what to do each time it stops.
+package debugger
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"tiger/go/ast"
+	"tiger/go/eval"
+	"tiger/go/lexer"
+	"tiger/go/object"
+	"tiger/go/parser"
+)
+
+// Command tells a paused program how to go on
+type Command int
+
+const (
+	Continue Command = iota
+	StepIn           // stop at the next statement, entering calls
+	StepOver         // stop at the next statement of this call or its callers
+	StepOut          // stop once this call has returned
+	Quit             // end the program
+)
+
+// Why a program stopped
+const (
+	ReasonEntry      = "entry"
+	ReasonBreakpoint = "breakpoint"
+	ReasonStep       = "step"
+	ReasonPause      = "pause"
+)
+
+// Debugger decides, statement by statement, when a program stops. Its
+// breakpoints may be changed from another goroutine while it runs.
+type Debugger struct {
+	// Paused is called on the goroutine running the program each time it
+	// stops, and returns how to go on
+	Paused func(p *Pause) Command
+	// StopOnEntry stops before the first statement
+	StopOnEntry bool
+
+	mu          sync.Mutex
+	lines       map[int]bool // lines where a statement starts
+	breakpoints map[int]bool
+	pause       bool // stop at the next statement
+	terminate   bool // end the program at the next statement
+
+	started   bool
+	mode      Command
+	depth     int // call depth of the last stop
+	prevLine  int
+	prevDepth int
+	// onLine holds the statements run since the program reached
+	// prevLine; one running again means a loop came back to the line
+	onLine map[ast.Statement]bool
+}
+
+// New returns a debugger for the program in src
+func New(src string) *Debugger {
+	d := &Debugger{lines: map[int]bool{}, breakpoints: map[int]bool{}}
+	program := parser.New(lexer.New(src)).ParseProgram()
+	for _, stmt := range program.Statements {
+		d.addLines(stmt)
+	}
+	return d
+}
+
+// addLines records the lines of stmt and the statements nested in it
+func (d *Debugger) addLines(stmt ast.Statement) {
+	d.lines[stmt.StartLine()] = true
+	block := func(b *ast.BlockStatement) {
+		if b == nil {
+			return
+		}
+		for _, inner := range b.Statements {
+			d.addLines(inner)
+		}
+	}
+	function := func(fn *ast.FunctionLiteral) {
+		if fn != nil {
+			block(fn.Body)
+		}
+	}
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+			function(fn)
+		}
+	case *ast.ConstStatement:
+		if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+			function(fn)
+		}
+	case *ast.BlockStatement:
+		block(stmt)
+	case *ast.IfStatement:
+		block(stmt.Consequence)
+		block(stmt.Alternative)
+	case *ast.WhileStatement:
+		block(stmt.Body)
+	case *ast.ForStatement:
+		block(stmt.Body)
+	case *ast.ClassStatement:
+		for _, method := range stmt.Methods {
+			function(method)
+		}
+	case *ast.TryStatement:
+		block(stmt.Block)
+		block(stmt.Handler)
+	}
+}
+
+// SetBreakpoint stops the program whenever it reaches line. A line
+// without a statement moves the breakpoint to the next one that has;
+// the line used is returned, with false when there is none.
+func (d *Debugger) SetBreakpoint(line int) (int, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	last := 0
+	for l := range d.lines {
+		last = max(last, l)
+	}
+	for ; line <= last; line++ {
+		if d.lines[line] {
+			d.breakpoints[line] = true
+			return line, true
+		}
+	}
+	return 0, false
+}
+
+// ClearBreakpoint removes the breakpoint at line
+func (d *Debugger) ClearBreakpoint(line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.breakpoints, line)
+}
+
+// ClearBreakpoints removes every breakpoint
+func (d *Debugger) ClearBreakpoints() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.breakpoints = map[int]bool{}
+}
+
+// Breakpoints lists the lines with a breakpoint, in order
+func (d *Debugger) Breakpoints() []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	lines := make([]int, 0, len(d.breakpoints))
+	for line := range d.breakpoints {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// RequestPause stops the running program before its next statement
+func (d *Debugger) RequestPause() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pause = true
+}
+
+// Terminate ends the running program before its next statement
+func (d *Debugger) Terminate() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.terminate = true
+}
+
+// Hooks returns the evaluator hooks to run the program with
+func (d *Debugger) Hooks() *eval.Hooks {
+	return &eval.Hooks{Statement: d.statement}
+}
+
+// errQuit ends a program the user quit; try statements let it through
+var errQuit = &object.Error{Message: "program stopped by the debugger", Limit: true}
+
+func (d *Debugger) statement(stmt ast.Statement, env *eval.Environment) *object.Error {
+	d.mu.Lock()
+	terminate, pause := d.terminate, d.pause
+	d.pause = false
+	d.mu.Unlock()
+	if terminate {
+		return errQuit
+	}
+
+	line, depth := stmt.StartLine(), env.Depth()
+	// Statements sharing a line stop once per pass over it, unless a
+	// pause was requested
+	same := line == d.prevLine && depth == d.prevDepth && !d.onLine[stmt]
+	if !same {
+		d.prevLine, d.prevDepth = line, depth
+		d.onLine = map[ast.Statement]bool{}
+	}
+	d.onLine[stmt] = true
+	var reason string
+	switch {
+	case pause:
+		reason = ReasonPause
+	case !same:
+		reason = d.reason(line, depth)
+	}
+	if reason == "" || d.Paused == nil {
+		return nil
+	}
+
+	command := d.Paused(&Pause{Line: line, Reason: reason, env: env})
+	if command == Quit {
+		return errQuit
+	}
+	d.mode, d.depth = command, depth
+	return nil
+}
+
+// reason returns why the program stops before a statement on a new
+// line, or "" if it does not
+func (d *Debugger) reason(line, depth int) string {
+	if !d.started {
+		d.started = true
+		if d.StopOnEntry {
+			return ReasonEntry
+		}
+	}
+	d.mu.Lock()
+	breakpoint := d.breakpoints[line]
+	d.mu.Unlock()
+	if breakpoint {
+		return ReasonBreakpoint
+	}
+	switch {
+	case d.mode == StepIn,
+		d.mode == StepOver && depth <= d.depth,
+		d.mode == StepOut && depth < d.depth:
+		return ReasonStep
+	}
+	return ""
+}
+
+// Pause is a stopped program. It is only valid until Paused returns.
+type Pause struct {
+	Line   int
+	Reason string
+
+	env *eval.Environment
+}
+
+// Frame is an active call of a paused program. Line is the line it is
+// executing; the outermost frame is the top level of the script.
+type Frame struct {
+	Function string
+	Line     int
+
+	env *eval.Environment
+}
+
+// Frames returns the call stack, innermost first
+func (p *Pause) Frames() []Frame {
+	stack := p.env.CallStack()
+	frames := make([]Frame, 0, len(stack)+1)
+	line, env := p.Line, p.env
+	for i, call := range stack {
+		if i > 0 {
+			env = call.Env()
+		}
+		frames = append(frames, Frame{Function: call.Function, Line: line, env: env})
+		line = call.Line
+	}
+	for env.Outer() != nil {
+		env = env.Outer()
+	}
+	return append(frames, Frame{Function: "<main>", Line: line, env: env})
+}
+
+// Locals returns the variables of a frame, numbered as in Frames, and
+// of the functions enclosing it. The top level has none: its variables
+// are the globals.
+func (p *Pause) Locals(frame int) map[string]object.Object {
+	vars := map[string]object.Object{}
+	frames := p.Frames()
+	if frame < 0 || frame >= len(frames) {
+		return vars
+	}
+	for env := frames[frame].env; env.Outer() != nil; env = env.Outer() {
+		for name, val := range env.Vars() {
+			if _, ok := vars[name]; !ok {
+				vars[name] = val
+			}
+		}
+	}
+	return vars
+}
+
+// Globals returns the variables declared at the top level
+func (p *Pause) Globals() map[string]object.Object {
+	env := p.env
+	for env.Outer() != nil {
+		env = env.Outer()
+	}
+	return env.Vars()
+}
+
+// Evaluate runs src in a frame, numbered as in Frames, returning the
+// value of its last statement. Assignments change the paused program.
+func (p *Pause) Evaluate(src string, frame int) (object.Object, error) {
+	frames := p.Frames()
+	if frame < 0 || frame >= len(frames) {
+		return nil, errors.New("no such frame")
+	}
+	parse := parser.New(lexer.New(src))
+	program := parse.ParseProgram()
+	if len(parse.Errors()) > 0 {
+		return nil, parse.ErrorList()
+	}
+	result := frames[frame].env.Evaluate(program)
+	if err, ok := result.(*object.Error); ok {
+		return nil, errors.New(err.Message)
+	}
+	return result, nil
+}
@@ -0,0 +1,108 @@
+package debugger_test
+
+import (
+	"io"
+	"reflect"
+	"testing"
+	"tiger/go/debugger"
+	"tiger/go/tiger"
+)
+
+const loop = `let i = 0
+while (i < 3) {
+    i = i + 1
+}
+print i
+let j = 0; while (j < 2) { j = j + 1; print j; }
+`
+
+func TestStops(t *testing.T) {
+	tests := []struct {
+		name        string
+		entry       bool
+		breakpoints []int
+		commands    []debugger.Command // answers to the stops in turn, then Continue
+		want        []int              // lines stopped at
+	}{
+		{
+			name:        "breakpoint in a loop stops on every pass",
+			breakpoints: []int{3},
+			want:        []int{3, 3, 3},
+		},
+		{
+			name:        "breakpoint on a one-line loop stops on every pass",
+			breakpoints: []int{6},
+			want:        []int{6, 6},
+		},
+		{
+			name:     "next steps through each pass",
+			entry:    true,
+			commands: []debugger.Command{debugger.StepOver, debugger.StepOver, debugger.StepOver, debugger.StepOver, debugger.StepOver},
+			want:     []int{1, 2, 3, 3, 3, 5},
+		},
+		{
+			name:        "breakpoint moves to the next statement",
+			breakpoints: []int{4},
+			want:        []int{5},
+		},
+		{
+			name:     "quit ends the program",
+			entry:    true,
+			commands: []debugger.Command{debugger.Quit},
+			want:     []int{1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := debugger.New(loop)
+			d.StopOnEntry = tt.entry
+			for _, line := range tt.breakpoints {
+				d.SetBreakpoint(line)
+			}
+			var stops []int
+			d.Paused = func(p *debugger.Pause) debugger.Command {
+				stops = append(stops, p.Line)
+				if len(stops) <= len(tt.commands) {
+					return tt.commands[len(stops)-1]
+				}
+				return debugger.Continue
+			}
+			interp := tiger.New()
+			interp.Stdout = io.Discard
+			interp.Hooks = d.Hooks()
+			interp.Run(loop)
+			if !reflect.DeepEqual(stops, tt.want) {
+				t.Errorf("stopped at %v, want %v", stops, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocals(t *testing.T) {
+	src := `func f(n) {
+    let doubled = n * 2
+    return doubled
+}
+f(21)
+`
+	d := debugger.New(src)
+	d.SetBreakpoint(3)
+	var frames []debugger.Frame
+	var doubled string
+	d.Paused = func(p *debugger.Pause) debugger.Command {
+		frames = p.Frames()
+		doubled = p.Locals(0)["doubled"].Inspect()
+		return debugger.Continue
+	}
+	interp := tiger.New()
+	interp.Hooks = d.Hooks()
+	if _, err := interp.Run(src); err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 2 || frames[0].Function != "f" || frames[0].Line != 3 || frames[1].Line != 5 {
+		t.Errorf("frames = %+v", frames)
+	}
+	if doubled != "42" {
+		t.Errorf("doubled = %s, want 42", doubled)
+	}
+}
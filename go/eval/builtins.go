@@ -0,0 +1,94 @@
+package eval
+
+import (
+	"fmt"
+	"strings"
+	"tiger/go/object"
+)
+
+// Variadic as a builtin's maxArgs accepts any number of extra arguments
+const Variadic = -1
+
+// builtins holds the names registered with RegisterBuiltin; modules such
+// as json are stored under their top-level name
+var builtins = map[string]object.Object{}
+
+// RegisterBuiltin makes fn callable from Tiger code in every environment.
+// A dotted name like "json.parse" places the function in a module,
+// created on first use. Names defined by a script shadow builtins.
+func RegisterBuiltin(name string, minArgs, maxArgs int, fn object.BuiltinFunction) {
+	define(builtins, name, NewBuiltin(name, minArgs, maxArgs, fn))
+}
+
+// NewBuiltin wraps fn as a Tiger value without registering it
+func NewBuiltin(name string, minArgs, maxArgs int, fn object.BuiltinFunction) *object.Builtin {
+	return &object.Builtin{Name: name, MinArgs: minArgs, MaxArgs: maxArgs, Fn: fn}
+}
+
+// LookupBuiltin returns the registered builtin or module called name
+func LookupBuiltin(name string) (object.Object, bool) {
+	obj, ok := builtins[name]
+	return obj, ok
+}
+
+// BuiltinNames lists the top-level builtin and module names
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	return names
+}
+
+// define stores obj under a possibly dotted name, creating the modules
+// on the way. Existing modules found through lookup are copied into
+// scope before being extended, so the originals stay untouched.
+func define(scope map[string]object.Object, name string, obj object.Object) {
+	parts := strings.Split(name, ".")
+	if len(parts) == 1 {
+		scope[name] = obj
+		return
+	}
+
+	var module *object.Module
+	if existing, ok := scope[parts[0]].(*object.Module); ok {
+		module = existing
+	} else {
+		module = &object.Module{Name: parts[0], Members: make(map[string]object.Object)}
+		if global, ok := builtins[parts[0]].(*object.Module); ok {
+			for key, member := range global.Members {
+				module.Members[key] = member
+			}
+		}
+		scope[parts[0]] = module
+	}
+	for i, part := range parts[1 : len(parts)-1] {
+		next, ok := module.Members[part].(*object.Module)
+		if !ok {
+			next = &object.Module{Name: strings.Join(parts[:i+2], "."), Members: make(map[string]object.Object)}
+			module.Members[part] = next
+		}
+		module = next
+	}
+	module.Members[parts[len(parts)-1]] = obj
+}
+
+func checkArity(fn *object.Builtin, got int) *object.Error {
+	if got >= fn.MinArgs && (fn.MaxArgs == Variadic || got <= fn.MaxArgs) {
+		return nil
+	}
+	var want string
+	switch {
+	case fn.MaxArgs == Variadic:
+		want = fmt.Sprintf("at least %d", fn.MinArgs)
+	case fn.MinArgs == fn.MaxArgs:
+		want = fmt.Sprintf("%d", fn.MinArgs)
+	default:
+		want = fmt.Sprintf("%d to %d", fn.MinArgs, fn.MaxArgs)
+	}
+	plural := "s"
+	if want == "1" {
+		plural = ""
+	}
+	return newError("error: %s expects %s argument%s, got %d", fn.Name, want, plural, got)
+}
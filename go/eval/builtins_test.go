@@ -0,0 +1,121 @@
+package eval_test
+
+import (
+	"strings"
+	"testing"
+	"tiger/go/eval"
+	"tiger/go/lexer"
+	"tiger/go/object"
+	"tiger/go/parser"
+	"tiger/go/resolver"
+
+	_ "tiger/go/stdlib"
+)
+
+// run resolves and evaluates src in env the way tiger.Interpreter does,
+// returning the result and what was printed
+func run(t *testing.T, env *eval.Environment, src string) (object.Object, string) {
+	t.Helper()
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatal(p.Errors())
+	}
+	defined := func(name string) bool {
+		_, ok := env.Get(name)
+		return ok
+	}
+	if errs := resolver.Resolve(program, defined); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	var out strings.Builder
+	env.SetOutput(&out)
+	return eval.Eval(program, env), out.String()
+}
+
+// count returns the number of arguments it was called with
+func count(args ...object.Object) (object.Object, error) {
+	return &object.Integer{Value: int64(len(args))}, nil
+}
+
+func TestArity(t *testing.T) {
+	env := eval.NewEnvironment()
+	env.Define("exact", eval.NewBuiltin("exact", 1, 1, count))
+	env.Define("none", eval.NewBuiltin("none", 0, 0, count))
+	env.Define("ranged", eval.NewBuiltin("ranged", 1, 3, count))
+	env.Define("variadic", eval.NewBuiltin("variadic", 2, eval.Variadic, count))
+	tests := []struct {
+		src  string
+		want string // Inspect of the result, or the error
+	}{
+		{"exact(1)", "1"},
+		{"exact()", "error: exact expects 1 argument, got 0"},
+		{"exact(1, 2)", "error: exact expects 1 argument, got 2"},
+		{"none()", "0"},
+		{"none(1)", "error: none expects 0 arguments, got 1"},
+		{"ranged(1, 2, 3)", "3"},
+		{"ranged()", "error: ranged expects 1 to 3 arguments, got 0"},
+		{"ranged(1, 2, 3, 4)", "error: ranged expects 1 to 3 arguments, got 4"},
+		{"variadic(1, 2, 3, 4, 5)", "5"},
+		{"variadic(1)", "error: variadic expects at least 2 arguments, got 1"},
+	}
+	for _, tt := range tests {
+		got, _ := run(t, env, tt.src)
+		if err, ok := got.(*object.Error); ok {
+			if err.Message != tt.want {
+				t.Errorf("%s: got error %s, want %s", tt.src, err.Message, tt.want)
+			}
+		} else if got.Inspect() != tt.want {
+			t.Errorf("%s = %s, want %s", tt.src, got.Inspect(), tt.want)
+		}
+	}
+}
+
+func TestRegisterBuiltinModules(t *testing.T) {
+	eval.RegisterBuiltin("registrytest.inner.count", 0, eval.Variadic, count)
+	eval.RegisterBuiltin("registrytest.count", 0, eval.Variadic, count)
+
+	obj, ok := eval.LookupBuiltin("registrytest")
+	module, isModule := obj.(*object.Module)
+	if !ok || !isModule {
+		t.Fatalf("LookupBuiltin(registrytest) = %v, %v", obj, ok)
+	}
+	inner, ok := module.Members["inner"].(*object.Module)
+	if !ok || inner.Name != "registrytest.inner" {
+		t.Fatalf("registrytest.inner = %v", module.Members["inner"])
+	}
+	if _, ok := eval.LookupBuiltin("registrytest.count"); ok {
+		t.Error("dotted names are registered under their module only")
+	}
+	found := false
+	for _, name := range eval.BuiltinNames() {
+		found = found || name == "registrytest"
+	}
+	if !found {
+		t.Error("BuiltinNames lacks registrytest")
+	}
+
+	got, out := run(t, eval.NewEnvironment(), "print registrytest.inner.count(1, 2)\nprint registrytest.count()\nprint registrytest.inner")
+	if want := "2\n0\n[module registrytest.inner]\n"; out != want {
+		t.Errorf("printed %q, want %q (result %v)", out, want, got)
+	}
+}
+
+func TestDefineExtendsModuleCopy(t *testing.T) {
+	eval.RegisterBuiltin("extendtest.shared", 0, 0, count)
+	global, _ := eval.LookupBuiltin("extendtest")
+
+	env := eval.NewEnvironment()
+	env.Define("extendtest.local", eval.NewBuiltin("extendtest.local", 0, 0, count))
+	if _, out := run(t, env, "print extendtest.shared()\nprint extendtest.local()"); out != "0\n0\n" {
+		t.Errorf("printed %q, want both members callable", out)
+	}
+
+	if _, ok := global.(*object.Module).Members["local"]; ok {
+		t.Error("Define added local to the registered module")
+	}
+	got, _ := run(t, eval.NewEnvironment(), "extendtest.local()")
+	if _, ok := got.(*object.Error); !ok {
+		t.Errorf("another environment called extendtest.local: %v", got.Inspect())
+	}
+}
@@ -1,307 +1,620 @@
 package eval
 
 import (
+	"context"
 	"fmt"
-	"strconv"
+	"io"
+	"os"
 	"tiger/go/ast"
+	"tiger/go/object"
 )
 
 type Environment struct {
-	store map[string]interface{}
-	outer *Environment
+	store  map[string]object.Object
+	consts map[string]bool
+	outer  *Environment
+	rt     *runtime
+
+	// slots holds the variables of a call to a resolved function, numbered
+	// as in names; store is then only used by code that was not resolved
+	slots      []object.Object
+	slotConsts []bool
+	names      []string
 }
 
+// runtime is the state shared by an environment and every scope
+// enclosed in it
+type runtime struct {
+	out io.Writer
+
+	ctx      context.Context
+	maxSteps int64
+	steps    int64
+
+	frames   []Frame
+	maxDepth int
+
+	root      *Environment
+	maxMemory int64
+	allocated int64
+
+	hooks *Hooks
+}
+
+// NewEnvironment creates a global scope that prints to os.Stdout
 func NewEnvironment() *Environment {
-	return &Environment{store: make(map[string]interface{})}
+	env := &Environment{
+		store:  make(map[string]object.Object),
+		consts: make(map[string]bool),
+		rt:     &runtime{out: os.Stdout},
+	}
+	env.rt.root = env
+	return env
+}
+
+// NewEnclosedEnvironment creates a scope whose lookups fall back to outer
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	return &Environment{outer: outer, rt: outer.rt}
 }
 
-func (e *Environment) Set(name string, val interface{}) {
+// newFrameEnvironment creates the scope of a call to fn, with a slot for
+// each of its locals when it has been resolved
+func newFrameEnvironment(fn *ast.FunctionLiteral, outer *Environment) *Environment {
+	env := NewEnclosedEnvironment(outer)
+	if fn.Locals != nil {
+		env.slots = make([]object.Object, len(fn.Locals))
+		env.names = fn.Locals
+	}
+	return env
+}
+
+// SetOutput directs print statements, in this environment and all scopes
+// sharing it, to w as they execute
+func (e *Environment) SetOutput(w io.Writer) {
+	e.rt.out = w
+}
+
+func (e *Environment) Set(name string, val object.Object) {
+	if e.store == nil {
+		e.store = make(map[string]object.Object)
+	}
 	e.store[name] = val
 }
 
-func (e *Environment) Get(name string) (interface{}, bool) {
+func (e *Environment) Get(name string) (object.Object, bool) {
 	val, ok := e.store[name]
-	if !ok && e.outer != nil {
-		return e.outer.Get(name)
+	if !ok {
+		if e.outer != nil {
+			return e.outer.Get(name)
+		}
+		return LookupBuiltin(name)
 	}
 	return val, ok
 }
 
-func Eval(node ast.Node, env *Environment) string {
+// Define binds name like Set, except that a dotted name such as
+// "http.get" is placed inside a module
+func (e *Environment) Define(name string, val object.Object) {
+	if e.store == nil {
+		e.store = make(map[string]object.Object)
+	}
+	define(e.store, name, val)
+}
+
+// Assign updates an existing binding in the scope that declared it
+func (e *Environment) Assign(name string, val object.Object) *object.Error {
+	if _, ok := e.store[name]; ok {
+		if e.consts[name] {
+			return newError("error: cannot assign to constant %s", name)
+		}
+		e.store[name] = val
+		return nil
+	}
+	if e.outer != nil {
+		return e.outer.Assign(name, val)
+	}
+	return newError("undefined variable: %s", name)
+}
+
+// scope returns the environment depth function scopes out from e
+func (e *Environment) scope(depth int) *Environment {
+	for ; depth > 0; depth-- {
+		e = e.outer
+	}
+	return e
+}
+
+// lookup reads the variable at ident, from its slot when the resolver
+// gave it one
+func (e *Environment) lookup(ident *ast.Identifier) (object.Object, bool) {
+	if !ident.Local {
+		return e.Get(ident.Value)
+	}
+	val := e.scope(ident.Depth).slots[ident.Index]
+	return val, val != nil
+}
+
+// declare binds a let, const, class or catch variable at ident in e
+func (e *Environment) declare(ident *ast.Identifier, val object.Object, constant bool) *object.Error {
+	if ident.Local {
+		e.slots[ident.Index] = val
+		e.setSlotConst(ident.Index, constant)
+		return nil
+	}
+	if err := e.charge(ident.Value); err != nil {
+		return err
+	}
+	e.Set(ident.Value, val)
+	if constant {
+		if e.consts == nil {
+			e.consts = make(map[string]bool)
+		}
+		e.consts[ident.Value] = true
+	} else {
+		delete(e.consts, ident.Value)
+	}
+	return nil
+}
+
+// assign updates the existing variable at ident
+func (e *Environment) assign(ident *ast.Identifier, val object.Object) *object.Error {
+	if !ident.Local {
+		return e.Assign(ident.Value, val)
+	}
+	scope := e.scope(ident.Depth)
+	switch {
+	case scope.slots[ident.Index] == nil:
+		return newError("undefined variable: %s", ident.Value)
+	case scope.slotConsts != nil && scope.slotConsts[ident.Index]:
+		return newError("error: cannot assign to constant %s", ident.Value)
+	}
+	scope.slots[ident.Index] = val
+	return nil
+}
+
+func (e *Environment) setSlotConst(i int, constant bool) {
+	if e.slotConsts == nil {
+		if !constant {
+			return
+		}
+		e.slotConsts = make([]bool, len(e.slots))
+	}
+	e.slotConsts[i] = constant
+}
+
+func (e *Environment) write(s string) {
+	fmt.Fprintln(e.rt.out, s)
+}
+
+// Eval executes node and returns its value: for expression statements the
+// value of the expression, for a program the value of its last statement.
+// Errors come back as *object.Error.
+func Eval(node ast.Node, env *Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
-		var output string
+		env.rt.program(node)
+		var result object.Object = object.NULL
 		for _, stmt := range node.Statements {
-			out := Eval(stmt, env)
-			if out != "" {
-				output += out + "\n"
+			if err := env.rt.statement(stmt, env); err != nil {
+				return err
+			}
+			result = Eval(stmt, env)
+			switch result := result.(type) {
+			case *object.ReturnValue:
+				return result.Value
+			case *object.Error:
+				return result
 			}
 		}
-		return output
+		return result
 
 	case *ast.LetStatement:
-		switch val := node.Value.(type) {
-		case *ast.StringLiteral:
-			env.Set(node.Name.Value, val.Value)
-		case *ast.IntegerLiteral:
-			env.Set(node.Name.Value, fmt.Sprintf("%d", val.Value))
-		case *ast.FloatLiteral:
-			env.Set(node.Name.Value, fmt.Sprintf("%.6f", val.Value))
-		case *ast.Boolean:
-			env.Set(node.Name.Value, fmt.Sprintf("%t", val.Value))
-		case *ast.Identifier:
-			if v, ok := env.Get(val.Value); ok {
-				env.Set(node.Name.Value, v)
-			}
-		case *ast.FunctionLiteral:
-			env.Set(node.Name.Value, val)
-		default:
-			// Handle any other expression type (like InfixExpression)
-			result := evalExpression(val, env)
-			env.Set(node.Name.Value, result)
+		val := evalExpression(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		if err := env.declare(node.Name, val, false); err != nil {
+			return err
 		}
 
 	case *ast.ConstStatement:
-		switch val := node.Value.(type) {
-		case *ast.StringLiteral:
-			env.Set(node.Name.Value, val.Value)
-		case *ast.IntegerLiteral:
-			env.Set(node.Name.Value, fmt.Sprintf("%d", val.Value))
-		case *ast.FloatLiteral:
-			env.Set(node.Name.Value, fmt.Sprintf("%.6f", val.Value))
-		case *ast.Boolean:
-			env.Set(node.Name.Value, fmt.Sprintf("%t", val.Value))
-		case *ast.Identifier:
-			if v, ok := env.Get(val.Value); ok {
-				env.Set(node.Name.Value, v)
-			}
-		case *ast.FunctionLiteral:
-			env.Set(node.Name.Value, val)
-		default:
-			// Handle any other expression type (like InfixExpression)
-			result := evalExpression(val, env)
-			env.Set(node.Name.Value, result)
+		val := evalExpression(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		if err := env.declare(node.Name, val, true); err != nil {
+			return err
 		}
 
 	case *ast.PrintStatement:
-		return evalExpression(node.Value, env)
+		val := evalExpression(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		env.write(val.Inspect())
 
 	case *ast.IfStatement:
-		if evalCondition(node.Condition, env) {
+		condition := evalExpression(node.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if IsTruthy(condition) {
 			return Eval(node.Consequence, env)
 		} else if node.Alternative != nil {
 			return Eval(node.Alternative, env)
 		}
 
 	case *ast.WhileStatement:
-		var output string
-		for evalCondition(node.Condition, env) {
-			out := Eval(node.Body, env)
-			if out != "" {
-				output += out + "\n"
+		for {
+			condition := evalExpression(node.Condition, env)
+			if isError(condition) {
+				return condition
+			}
+			if !IsTruthy(condition) {
+				break
+			}
+			if result := Eval(node.Body, env); isInterrupt(result) {
+				return result
+			}
+			if err := env.rt.step(); err != nil {
+				return err
 			}
 		}
-		return output
 
 	case *ast.ForStatement:
-		var output string
 		// Execute init statement
 		if node.Init != nil {
-			Eval(node.Init, env)
+			if result := Eval(node.Init, env); isError(result) {
+				return result
+			}
 		}
 		// Loop while condition is true
-		for evalCondition(node.Condition, env) {
-			out := Eval(node.Body, env)
-			if out != "" {
-				output += out + "\n"
+		for {
+			if node.Condition != nil {
+				condition := evalExpression(node.Condition, env)
+				if isError(condition) {
+					return condition
+				}
+				if !IsTruthy(condition) {
+					break
+				}
+			}
+			if result := Eval(node.Body, env); isInterrupt(result) {
+				return result
 			}
 			// Execute update statement
 			if node.Update != nil {
-				Eval(node.Update, env)
+				if result := Eval(node.Update, env); isError(result) {
+					return result
+				}
+			}
+			if err := env.rt.step(); err != nil {
+				return err
 			}
 		}
-		return output
 
 	case *ast.BlockStatement:
-		var output string
+		var result object.Object = object.NULL
 		for _, stmt := range node.Statements {
-			out := Eval(stmt, env)
-			if out != "" {
-				output += out + "\n"
+			if err := env.rt.statement(stmt, env); err != nil {
+				return err
+			}
+			result = Eval(stmt, env)
+			if isInterrupt(result) {
+				return result
 			}
 		}
-		return output
+		return result
 
 	case *ast.ExpressionStatement:
-		result := evalExpression(node.Expression, env)
-		return result
+		return evalExpression(node.Expression, env)
 
 	case *ast.ReturnStatement:
-		return evalExpression(node.Value, env)
+		if node.Value == nil {
+			return &object.ReturnValue{Value: object.NULL}
+		}
+		val := evalExpression(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		return &object.ReturnValue{Value: val}
 
 	case *ast.ClassStatement:
-		// For now, just store the class definition in the environment
-		env.Set(node.Name.Value, node)
+		if err := env.declare(node.Name, newClass(node, env), false); err != nil {
+			return err
+		}
+
+	case *ast.TryStatement:
+		result := Eval(node.Block, env)
+		errObj, ok := result.(*object.Error)
+		if !ok || errObj.Limit {
+			return result
+		}
+		if node.Param != nil {
+			if err := env.declare(node.Param, CaughtValue(errObj), false); err != nil {
+				return err
+			}
+		}
+		return Eval(node.Handler, env)
+
+	case *ast.ThrowStatement:
+		val := evalExpression(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		return &object.Error{Message: val.Inspect(), Value: val}
 	}
-	return ""
+	return object.NULL
 }
 
-func evalExpression(expr ast.Expression, env *Environment) string {
+func evalExpression(expr ast.Expression, env *Environment) object.Object {
 	switch val := expr.(type) {
 	case *ast.Identifier:
-		if v, ok := env.Get(val.Value); ok {
-			if str, isString := v.(string); isString {
-				return str
-			}
-			return fmt.Sprintf("%v", v)
+		if v, ok := env.lookup(val); ok {
+			return v
 		}
-		return "[undefined variable: " + val.Value + "]"
+		return newError("undefined variable: %s", val.Value)
 	case *ast.StringLiteral:
-		return val.Value
+		return &object.String{Value: val.Value}
 	case *ast.IntegerLiteral:
-		return fmt.Sprintf("%d", val.Value)
+		return &object.Integer{Value: val.Value}
 	case *ast.FloatLiteral:
-		return fmt.Sprintf("%.6f", val.Value)
+		return &object.Float{Value: val.Value}
 	case *ast.Boolean:
-		return fmt.Sprintf("%t", val.Value)
+		return object.NativeBool(val.Value)
+	case *ast.Null:
+		return object.NULL
+	case *ast.ArrayLiteral:
+		elements := make([]object.Object, 0, len(val.Elements))
+		for _, el := range val.Elements {
+			obj := evalExpression(el, env)
+			if isError(obj) {
+				return obj
+			}
+			elements = append(elements, obj)
+		}
+		return env.rt.track(&object.Array{Elements: elements})
+	case *ast.MapLiteral:
+		return evalMapLiteral(val, env)
+	case *ast.FunctionLiteral:
+		return &Function{Literal: val, Env: env}
+	case *ast.PrefixExpression:
+		right := evalExpression(val.Right, env)
+		if isError(right) {
+			return right
+		}
+		return UnaryOp(val.Operator, right)
 	case *ast.InfixExpression:
-		return evalArithmetic(val, env)
+		return evalInfix(val, env)
+	case *ast.IndexExpression:
+		left := evalExpression(val.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := evalExpression(val.Index, env)
+		if isError(index) {
+			return index
+		}
+		return Index(left, index)
+	case *ast.MemberExpression:
+		obj := evalExpression(val.Object, env)
+		if isError(obj) {
+			return obj
+		}
+		return Member(obj, val.Property.Value)
+	case *ast.AssignExpression:
+		return evalAssign(val, env)
 	case *ast.CallExpression:
 		return evalCallExpression(val, env)
 	}
-	return ""
+	return object.NULL
 }
 
-func evalCondition(node ast.Expression, env *Environment) bool {
-	switch val := node.(type) {
-	case *ast.Boolean:
-		return val.Value
-	case *ast.Identifier:
-		if str, ok := env.Get(val.Value); ok {
-			return str == "true"
+func evalMapLiteral(node *ast.MapLiteral, env *Environment) object.Object {
+	m := object.NewMap()
+	for i, keyNode := range node.Keys {
+		key := evalExpression(keyNode, env)
+		if isError(key) {
+			return key
 		}
-	case *ast.InfixExpression:
-		left := evalExpression(val.Left, env)
-		right := evalExpression(val.Right, env)
-
-		// Try to convert to numbers for numeric comparison
-		leftFloat, leftErr := strconv.ParseFloat(left, 64)
-		rightFloat, rightErr := strconv.ParseFloat(right, 64)
-
-		if leftErr == nil && rightErr == nil {
-			// Both are numbers, do numeric comparison
-			switch val.Operator {
-			case "==":
-				return leftFloat == rightFloat
-			case "!=":
-				return leftFloat != rightFloat
-			case "<":
-				return leftFloat < rightFloat
-			case "<=":
-				return leftFloat <= rightFloat
-			case ">":
-				return leftFloat > rightFloat
-			case ">=":
-				return leftFloat >= rightFloat
-			}
-		} else {
-			// String comparison
-			switch val.Operator {
-			case "==":
-				return left == right
-			case "!=":
-				return left != right
-			case "<":
-				return left < right
-			case "<=":
-				return left <= right
-			case ">":
-				return left > right
-			case ">=":
-				return left >= right
-			}
+		str, ok := key.(*object.String)
+		if !ok {
+			return newError("error: map keys must be strings, got %s", key.Type())
 		}
+		val := evalExpression(node.Values[i], env)
+		if isError(val) {
+			return val
+		}
+		m.Set(str.Value, val)
 	}
-	return false
+	return env.rt.track(m)
 }
 
-func evalArithmetic(expr *ast.InfixExpression, env *Environment) string {
+func evalInfix(expr *ast.InfixExpression, env *Environment) object.Object {
 	left := evalExpression(expr.Left, env)
+	if isError(left) {
+		return left
+	}
 	right := evalExpression(expr.Right, env)
+	if isError(right) {
+		return right
+	}
+	result := BinaryOp(expr.Operator, left, right)
+	// Only concatenation makes strings
+	if str, ok := result.(*object.String); ok {
+		return env.rt.track(str)
+	}
+	return result
+}
 
-	// Try to convert to numbers for arithmetic
-	leftFloat, leftErr := strconv.ParseFloat(left, 64)
-	rightFloat, rightErr := strconv.ParseFloat(right, 64)
-
-	if leftErr == nil && rightErr == nil {
-		var result float64
-		switch expr.Operator {
-		case "+":
-			result = leftFloat + rightFloat
-		case "-":
-			result = leftFloat - rightFloat
-		case "*":
-			result = leftFloat * rightFloat
-		case "/":
-			if rightFloat != 0 {
-				result = leftFloat / rightFloat
-			} else {
-				return "[division by zero]"
+func evalAssign(node *ast.AssignExpression, env *Environment) object.Object {
+	val := evalExpression(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		if err := env.assign(target, val); err != nil {
+			return err
+		}
+	case *ast.IndexExpression:
+		left := evalExpression(target.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := evalExpression(target.Index, env)
+		if isError(index) {
+			return index
+		}
+		if m, ok := left.(*object.Map); ok {
+			if key, ok := index.(*object.String); ok {
+				if err := env.rt.chargeKey(m, key.Value); err != nil {
+					return err
+				}
 			}
-		default:
-			return "[unsupported arithmetic operator: " + expr.Operator + "]"
 		}
-
-		// Return as string with appropriate formatting
-		if result == float64(int64(result)) {
-			return fmt.Sprintf("%d", int64(result))
-		} else {
-			return fmt.Sprintf("%.6f", result)
+		if err := SetIndex(left, index, val); err != nil {
+			return err
+		}
+	case *ast.MemberExpression:
+		obj := evalExpression(target.Object, env)
+		if isError(obj) {
+			return obj
+		}
+		name := target.Property.Value
+		switch obj := obj.(type) {
+		case *object.Map:
+			if err := env.rt.chargeKey(obj, name); err != nil {
+				return err
+			}
+		case *Instance:
+			if err := env.rt.chargeKey(obj.Fields, name); err != nil {
+				return err
+			}
+		}
+		if err := SetMember(obj, name, val); err != nil {
+			return err
 		}
 	}
+	return val
+}
 
-	// String concatenation for +
-	if expr.Operator == "+" {
-		return left + right
+func evalCallExpression(call *ast.CallExpression, env *Environment) object.Object {
+	fn := evalExpression(call.Function, env)
+	if isError(fn) {
+		if ident, ok := call.Function.(*ast.Identifier); ok {
+			return newError("undefined function: %s", ident.Value)
+		}
+		return fn
 	}
 
-	return "[invalid arithmetic operation]"
-}
+	args := make([]object.Object, 0, len(call.Arguments))
+	for _, argNode := range call.Arguments {
+		arg := evalExpression(argNode, env)
+		if isError(arg) {
+			return arg
+		}
+		args = append(args, arg)
+	}
 
-func evalCallExpression(call *ast.CallExpression, env *Environment) string {
-	fnName, ok := call.Function.(*ast.Identifier)
-	if !ok {
-		return "[error: unsupported function call]"
+	if builtin, ok := fn.(*object.Builtin); ok && env.rt.maxMemory > 0 {
+		return env.rt.callBuiltin(builtin, args)
 	}
+	return applyFunction(fn, args, call.Line)
+}
 
-	// Handle built-in functions
-	if fnName.Value == "print" {
-		if len(call.Arguments) != 1 {
-			return "[error: print expects exactly 1 argument]"
+// bindArguments sets this and the parameters in the scope of a call
+func bindArguments(env *Environment, fn *Function, args []object.Object) {
+	for i, param := range fn.Literal.Parameters {
+		var arg object.Object = object.NULL
+		if i < len(args) {
+			arg = args[i]
+		}
+		if param.Local {
+			env.slots[param.Index] = arg
+		} else {
+			env.Set(param.Value, arg)
 		}
-		result := evalExpression(call.Arguments[0], env)
-		return result
 	}
-
-	// Handle user-defined functions
-	val, ok := env.Get(fnName.Value)
-	if !ok {
-		return "[undefined function: " + fnName.Value + "]"
+	if fn.This == nil {
+		return
 	}
-	fn, ok := val.(*ast.FunctionLiteral)
-	if !ok {
-		return "[error: not a function: " + fnName.Value + "]"
+	if env.slots != nil {
+		env.slots[0] = fn.This
+	} else {
+		env.Set("this", fn.This)
 	}
+}
 
-	newEnv := &Environment{store: make(map[string]interface{}), outer: env}
+// ApplyFunction calls a Tiger function, builtin or class with evaluated
+// arguments
+func ApplyFunction(fn object.Object, args []object.Object) object.Object {
+	return applyFunction(fn, args, 0)
+}
 
-	for i, param := range fn.Parameters {
-		if i < len(call.Arguments) {
-			arg := evalExpression(call.Arguments[i], env)
-			newEnv.Set(param.Value, arg)
+// applyFunction is ApplyFunction for a call made on the given line
+func applyFunction(fn object.Object, args []object.Object, line int) object.Object {
+	switch fn := fn.(type) {
+	case *Function:
+		rt := fn.Env.rt
+		if err := rt.step(); err != nil {
+			return err
+		}
+		newEnv := newFrameEnvironment(fn.Literal, fn.Env)
+		frame := Frame{Function: frameName(fn), Line: line, Defined: fn.Literal.Line, env: newEnv}
+		if err := rt.pushFrame(frame); err != nil {
+			return err
+		}
+		rt.call(frame)
+		defer rt.leave(frame)
+		if err := rt.alloc(frameSize(fn.Literal)); err != nil {
+			return err
+		}
+		bindArguments(newEnv, fn, args)
+		result := Eval(fn.Literal.Body, newEnv)
+		if ret, ok := result.(*object.ReturnValue); ok {
+			return ret.Value
+		}
+		if isError(result) {
+			return result
+		}
+		return object.NULL
+
+	case *object.Builtin:
+		if err := checkArity(fn, len(args)); err != nil {
+			return err
+		}
+		result, err := fn.Fn(args...)
+		if err != nil {
+			return newError("error: %s: %s", fn.Name, err)
+		}
+		if result == nil {
+			return object.NULL
 		}
+		return result
+
+	case *Class:
+		return instantiate(fn, args, line)
+	case Callable:
+		return fn.Call(args)
 	}
+	return newError("error: not a function: %s", fn.Inspect())
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+func isError(obj object.Object) bool {
+	return obj != nil && obj.Type() == object.ERROR_OBJ
+}
 
-	bodyOutput := Eval(fn.Body, newEnv)
-	return bodyOutput
+// isInterrupt reports whether a statement result should stop the
+// enclosing block: a return or an error
+func isInterrupt(obj object.Object) bool {
+	if obj == nil {
+		return false
+	}
+	return obj.Type() == object.RETURN_OBJ || obj.Type() == object.ERROR_OBJ
 }
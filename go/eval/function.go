@@ -0,0 +1,92 @@
+package eval
+
+import (
+	"tiger/go/ast"
+	"tiger/go/object"
+)
+
+// Function is a user-defined function closed over its defining scope.
+// Methods looked up on an instance carry the instance in This.
+type Function struct {
+	Literal *ast.FunctionLiteral
+	Env     *Environment
+	This    *Instance
+}
+
+func (f *Function) Type() object.ObjectType { return object.FUNCTION_OBJ }
+func (f *Function) Inspect() string {
+	if f.Literal.Name == "" {
+		return "[func]"
+	}
+	return "[func " + f.Literal.Name + "]"
+}
+
+// Callable is a function value from outside the tree-walker, such as a
+// compiled closure, that ApplyFunction can call
+type Callable interface {
+	object.Object
+	Call(args []object.Object) object.Object
+}
+
+// Class is a class declared in Tiger. Classes compiled to bytecode set
+// Bind, which then supplies the methods in place of Methods and Env.
+type Class struct {
+	Name    string
+	Methods map[string]*ast.FunctionLiteral
+	Env     *Environment
+	Bind    func(this *Instance, name string) (object.Object, bool)
+}
+
+func (c *Class) Type() object.ObjectType { return object.CLASS_OBJ }
+func (c *Class) Inspect() string         { return "[class " + c.Name + "]" }
+
+type Instance struct {
+	Class  *Class
+	Fields *object.Map
+}
+
+func (i *Instance) Type() object.ObjectType { return object.INSTANCE_OBJ }
+func (i *Instance) Inspect() string         { return i.Class.Name + " " + i.Fields.Inspect() }
+
+func newClass(node *ast.ClassStatement, env *Environment) *Class {
+	class := &Class{
+		Name:    node.Name.Value,
+		Methods: make(map[string]*ast.FunctionLiteral),
+		Env:     env,
+	}
+	for _, method := range node.Methods {
+		class.Methods[method.Name] = method
+	}
+	return class
+}
+
+// instantiate creates an instance of class, running its init method
+// with args when the class defines one
+func instantiate(class *Class, args []object.Object, line int) object.Object {
+	instance := &Instance{Class: class, Fields: object.NewMap()}
+	if class.Env != nil {
+		if err := class.Env.rt.alloc(object.ValueSize + object.Size(instance.Fields)); err != nil {
+			return err
+		}
+	}
+	if init, ok := BindMethod(instance, "init"); ok {
+		result := applyFunction(init, args, line)
+		if isError(result) {
+			return result
+		}
+	}
+	return instance
+}
+
+// BindMethod looks up a method of the instance's class, bound to the
+// instance
+func BindMethod(this *Instance, name string) (object.Object, bool) {
+	if this.Class.Bind != nil {
+		return this.Class.Bind(this, name)
+	}
+	method, ok := this.Class.Methods[name]
+	if !ok {
+		return nil, false
+	}
+	return &Function{Literal: method, Env: this.Class.Env, This: this}, true
+}
@@ -0,0 +1,140 @@
+package eval
+
+import (
+	"tiger/go/ast"
+	"tiger/go/object"
+)
+
+// Hooks let tools such as debuggers watch a program as the tree-walker
+// runs it. Nil fields are skipped.
+type Hooks struct {
+	// Program runs before the statements of a program, so that tools can
+	// index the tree the statements passed to Statement belong to
+	Program func(program *ast.Program)
+	// Statement runs before each statement of a program or block, with
+	// the scope the statement runs in. Returning an error stops the
+	// program with it.
+	Statement func(stmt ast.Statement, env *Environment) *object.Error
+	// Call runs as a Tiger function is entered, and Return as it is left,
+	// however it ends
+	Call   func(frame Frame)
+	Return func(frame Frame)
+}
+
+// SetHooks installs hooks for this environment and every scope sharing
+// it; nil removes them
+func (e *Environment) SetHooks(hooks *Hooks) {
+	e.rt.hooks = hooks
+}
+
+// statement runs the Statement hook, if any
+func (rt *runtime) statement(stmt ast.Statement, env *Environment) *object.Error {
+	if rt.hooks == nil || rt.hooks.Statement == nil {
+		return nil
+	}
+	return rt.hooks.Statement(stmt, env)
+}
+
+// program runs the Program hook, if any
+func (rt *runtime) program(program *ast.Program) {
+	if rt.hooks != nil && rt.hooks.Program != nil {
+		rt.hooks.Program(program)
+	}
+}
+
+// call runs the Call hook, if any
+func (rt *runtime) call(frame Frame) {
+	if rt.hooks != nil && rt.hooks.Call != nil {
+		rt.hooks.Call(frame)
+	}
+}
+
+// leave runs the Return hook, if any, and exits the call
+func (rt *runtime) leave(frame Frame) {
+	if rt.hooks != nil && rt.hooks.Return != nil {
+		rt.hooks.Return(frame)
+	}
+	rt.popFrame()
+}
+
+// Depth returns how many Tiger function calls are active
+func (e *Environment) Depth() int {
+	return len(e.rt.frames)
+}
+
+// Outer returns the scope enclosing e, or nil for the global scope
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
+// Env returns the scope of the call
+func (f Frame) Env() *Environment {
+	return f.env
+}
+
+// Vars returns the variables declared directly in e, whether stored by
+// name or in the slots of a resolved function
+func (e *Environment) Vars() map[string]object.Object {
+	vars := make(map[string]object.Object, len(e.store)+len(e.slots))
+	for name, val := range e.store {
+		vars[name] = val
+	}
+	for i, val := range e.slots {
+		if val != nil {
+			vars[e.names[i]] = val
+		}
+	}
+	return vars
+}
+
+// Evaluate runs a program, which must not have been resolved, as if it
+// were written where e is active: it sees e's variables and may assign
+// them. Names it declares are discarded afterwards. Hooks are off while
+// it runs.
+func (e *Environment) Evaluate(program *ast.Program) object.Object {
+	root := e
+	for root.outer != nil {
+		root = root.outer
+	}
+
+	// Unresolved code finds variables by name, so the locals of e and its
+	// enclosing scopes are copied into a scope of their own
+	type origin struct {
+		env *Environment
+		val object.Object
+	}
+	view := NewEnclosedEnvironment(root)
+	view.store = map[string]object.Object{}
+	origins := map[string]origin{}
+	for scope := e; scope != root; scope = scope.outer {
+		for name, val := range scope.Vars() {
+			if _, ok := view.store[name]; !ok {
+				view.store[name] = val
+				origins[name] = origin{scope, val}
+			}
+		}
+	}
+
+	hooks := e.rt.hooks
+	e.rt.hooks = nil
+	result := Eval(program, view)
+	e.rt.hooks = hooks
+
+	for name, from := range origins {
+		if val := view.store[name]; val != from.val {
+			from.env.setVar(name, val)
+		}
+	}
+	return result
+}
+
+// setVar updates the variable called name declared directly in e
+func (e *Environment) setVar(name string, val object.Object) {
+	for i, n := range e.names {
+		if n == name && e.slots[i] != nil {
+			e.slots[i] = val
+			return
+		}
+	}
+	e.Set(name, val)
+}
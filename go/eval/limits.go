@@ -0,0 +1,71 @@
+package eval
+
+import (
+	"context"
+	"tiger/go/ast"
+	"tiger/go/object"
+	"time"
+)
+
+// contextCheckInterval is how many steps pass between context checks
+const contextCheckInterval = 256
+
+// EvalContext is Eval bounded by ctx: once ctx is cancelled or its
+// deadline passes, evaluation stops at the next loop iteration or call
+// with an execution limit error.
+func EvalContext(ctx context.Context, node ast.Node, env *Environment) object.Object {
+	var result object.Object
+	WithContext(ctx, env, func() {
+		result = Eval(node, env)
+	})
+	return result
+}
+
+// WithContext runs fn with ctx bounding any evaluation in env, such as
+// ApplyFunction calls made by host code
+func WithContext(ctx context.Context, env *Environment, fn func()) {
+	prev := env.rt.ctx
+	env.rt.ctx = ctx
+	defer func() { env.rt.ctx = prev }()
+	fn()
+}
+
+// SetMaxSteps limits how many steps, loop iterations plus function
+// calls, the environment may still run. Zero removes the limit. The step
+// count restarts from zero.
+func (e *Environment) SetMaxSteps(n int64) {
+	e.rt.maxSteps = n
+	e.rt.steps = 0
+}
+
+// Steps returns the number of steps counted since SetMaxSteps
+func (e *Environment) Steps() int64 {
+	return e.rt.steps
+}
+
+// step counts one loop iteration or call against the limits
+func (rt *runtime) step() *object.Error {
+	rt.steps++
+	if rt.maxSteps > 0 && rt.steps > rt.maxSteps {
+		return LimitError("step budget of %d exhausted", rt.maxSteps)
+	}
+	if rt.ctx != nil && rt.steps%contextCheckInterval == 0 {
+		if err := rt.ctx.Err(); err != nil {
+			return LimitError("%s", err)
+		}
+		// Timers cannot fire while a single-threaded runtime such as
+		// WebAssembly is busy evaluating, so compare with the deadline
+		if deadline, ok := rt.ctx.Deadline(); ok && time.Now().After(deadline) {
+			return LimitError("%s", context.DeadlineExceeded)
+		}
+	}
+	return nil
+}
+
+// LimitError makes an error for a script stopped by an execution limit.
+// try statements let it through.
+func LimitError(format string, a ...interface{}) *object.Error {
+	err := newError("execution limit exceeded: "+format, a...)
+	err.Limit = true
+	return err
+}
@@ -0,0 +1,116 @@
+package eval_test
+
+import (
+	"context"
+	"testing"
+	"tiger/go/eval"
+	"tiger/go/object"
+	"time"
+)
+
+func TestStepBudget(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		max  int64
+		want string // printed, or the error
+	}{
+		{"within budget", "let i = 0\nwhile (i < 10) { i = i + 1 }\nprint i", 11, "10\n"},
+		{"loop", "while (true) {}", 100, "execution limit exceeded: step budget of 100 exhausted"},
+		{"for loop", "for (let i = 0; true; i = i + 1) {}", 100, "execution limit exceeded: step budget of 100 exhausted"},
+		{"calls", "func f(n) { if (n > 0) { f(n - 1) } }\nf(20)", 10, "execution limit exceeded: step budget of 10 exhausted"},
+		{"no limit", "let i = 0\nwhile (i < 5000) { i = i + 1 }\nprint i", 0, "5000\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := eval.NewEnvironment()
+			env.SetMaxSteps(tt.max)
+			got, out := run(t, env, tt.src)
+			if err, ok := got.(*object.Error); ok {
+				if !err.Limit {
+					t.Errorf("%s is not a limit error", err.Message)
+				}
+				out = err.Message
+			}
+			if out != tt.want {
+				t.Errorf("got %q, want %q", out, tt.want)
+			}
+			if tt.max > 0 && env.Steps() > tt.max+1 {
+				t.Errorf("ran %d steps past a budget of %d", env.Steps(), tt.max)
+			}
+		})
+	}
+}
+
+func TestSetMaxStepsRestartsCount(t *testing.T) {
+	env := eval.NewEnvironment()
+	env.SetMaxSteps(50)
+	run(t, env, "let i = 0\nwhile (i < 30) { i = i + 1 }")
+	if env.Steps() != 30 {
+		t.Errorf("counted %d steps, want 30", env.Steps())
+	}
+	env.SetMaxSteps(50)
+	if got, _ := run(t, env, "let j = 0\nwhile (j < 30) { j = j + 1 }"); got.Type() == object.ERROR_OBJ {
+		t.Errorf("second run failed with %s", got.Inspect())
+	}
+}
+
+func TestContextLimits(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{"cancelled", cancelled, "execution limit exceeded: context canceled"},
+		{"deadline", expired, "execution limit exceeded: context deadline exceeded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := eval.NewEnvironment()
+			var got object.Object
+			eval.WithContext(tt.ctx, env, func() {
+				got, _ = run(t, env, "func spin() { while (true) {} }\nspin()")
+			})
+			err, ok := got.(*object.Error)
+			if !ok || !err.Limit || err.Message != tt.want {
+				t.Errorf("got %s, want the limit error %s", got.Inspect(), tt.want)
+			}
+		})
+	}
+
+	// Without a context, the environment runs unbounded again
+	env := eval.NewEnvironment()
+	eval.WithContext(cancelled, env, func() {})
+	if got, out := run(t, env, "let i = 0\nwhile (i < 1000) { i = i + 1 }\nprint i"); out != "1000\n" {
+		t.Errorf("got %s after the context was removed", got.Inspect())
+	}
+}
+
+func TestTryDoesNotCatchLimits(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"loop in try", `try { while (true) {} } catch (e) { print "caught" }`},
+		{"call in try", "func spin() { while (true) {} }\ntry { spin() } catch (e) { print \"caught\" }"},
+		{"try in the looping function", "func spin() { try { while (true) {} } catch (e) { print \"inner\" } }\ntry { spin() } catch (e) { print \"outer\" }"},
+		{"throw in the handler", `try { while (true) {} } catch (e) { throw "replaced" }`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := eval.NewEnvironment()
+			env.SetMaxSteps(100)
+			got, out := run(t, env, tt.src)
+			if err, ok := got.(*object.Error); !ok || !err.Limit {
+				t.Errorf("got %s, want a limit error", got.Inspect())
+			}
+			if out != "" {
+				t.Errorf("a handler ran and printed %q", out)
+			}
+		})
+	}
+}
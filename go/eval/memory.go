@@ -0,0 +1,202 @@
+package eval
+
+import (
+	"tiger/go/ast"
+	"tiger/go/object"
+)
+
+// scopeSize is the fixed cost of the scope each function call creates
+const scopeSize = 12 * object.WordSize
+
+// SetMaxMemory limits the bytes the environment's strings, arrays, maps
+// and scopes may occupy. Zero removes the limit. Usage is measured again
+// from the values currently reachable.
+func (e *Environment) SetMaxMemory(n int64) {
+	e.rt.maxMemory = n
+	if n > 0 {
+		e.rt.allocated = e.rt.liveSize()
+	}
+}
+
+// MemoryUsage estimates the bytes held by values reachable from the
+// global scope and the active calls
+func (e *Environment) MemoryUsage() int64 {
+	return e.rt.liveSize()
+}
+
+// alloc charges n newly allocated bytes. Allocations are only summed
+// until the total passes the ceiling; then what is still reachable is
+// measured, so garbage does not count against the script.
+func (rt *runtime) alloc(n int64) *object.Error {
+	if rt.maxMemory <= 0 {
+		return nil
+	}
+	rt.allocated += n
+	if rt.allocated <= rt.maxMemory {
+		return nil
+	}
+	rt.allocated = rt.liveSize() + n
+	if rt.allocated > rt.maxMemory {
+		err := newError("memory limit exceeded: more than %d bytes in use", rt.maxMemory)
+		err.Limit = true
+		return err
+	}
+	return nil
+}
+
+// chargeKey charges for key when adding it to m
+func (rt *runtime) chargeKey(m *object.Map, key string) *object.Error {
+	if _, ok := m.Get(key); ok {
+		return nil
+	}
+	return rt.alloc(object.KeySize(key))
+}
+
+// track charges a newly made object, returning it or the memory limit
+// error
+func (rt *runtime) track(obj object.Object) object.Object {
+	if err := rt.alloc(object.Size(obj)); err != nil {
+		return err
+	}
+	return obj
+}
+
+// callBuiltin calls a builtin while a memory limit is set, charging for
+// a new result and for the growth of containers passed to it
+func (rt *runtime) callBuiltin(fn *object.Builtin, args []object.Object) object.Object {
+	before := make([]int64, len(args))
+	for i, arg := range args {
+		before[i] = object.Size(arg)
+	}
+	result := applyFunction(fn, args, 0)
+	if isError(result) {
+		return result
+	}
+
+	// A result that is one of the arguments is not new
+	var grown int64
+	s := newSizer()
+	for i, arg := range args {
+		grown += object.Size(arg) - before[i]
+		switch arg.(type) {
+		case *object.String, *object.Array, *object.Map:
+			s.visit(arg)
+		}
+	}
+	s.object(result)
+	if err := rt.alloc(grown + s.total); err != nil {
+		return err
+	}
+	return result
+}
+
+// frameSize is the estimated size of a scope for a call to fn: every
+// slot up front for resolved functions, otherwise the parameters
+func frameSize(fn *ast.FunctionLiteral) int64 {
+	if fn.Locals != nil {
+		return scopeSize + int64(len(fn.Locals))*object.ValueSize
+	}
+	return scopeSize + int64(len(fn.Parameters))*object.EntrySize
+}
+
+// charge accounts for binding name in env, unless it is already bound
+// there
+func (e *Environment) charge(name string) *object.Error {
+	if _, ok := e.store[name]; ok {
+		return nil
+	}
+	return e.rt.alloc(object.KeySize(name))
+}
+
+// liveSize measures the values reachable from the global scope and the
+// scopes of active calls
+func (rt *runtime) liveSize() int64 {
+	s := newSizer()
+	s.env(rt.root)
+	for _, frame := range rt.frames {
+		s.env(frame.env)
+	}
+	return s.total
+}
+
+// sizer sums object sizes, counting values shared by several
+// containers once
+type sizer struct {
+	seen  map[any]bool
+	total int64
+}
+
+func newSizer() *sizer {
+	return &sizer{seen: make(map[any]bool)}
+}
+
+// visit reports whether key is seen for the first time
+func (s *sizer) visit(key any) bool {
+	if s.seen[key] {
+		return false
+	}
+	s.seen[key] = true
+	return true
+}
+
+func (s *sizer) env(env *Environment) {
+	for ; env != nil && s.visit(env); env = env.outer {
+		s.total += scopeSize
+		for name, val := range env.store {
+			s.total += object.KeySize(name)
+			s.object(val)
+		}
+		s.total += int64(len(env.slots)) * object.ValueSize
+		for _, val := range env.slots {
+			if val != nil {
+				s.object(val)
+			}
+		}
+	}
+}
+
+func (s *sizer) object(obj object.Object) {
+	switch obj := obj.(type) {
+	case *object.String:
+		if s.visit(obj) {
+			s.total += object.Size(obj)
+		}
+	case *object.Array:
+		if s.visit(obj) {
+			s.total += object.Size(obj)
+			for _, el := range obj.Elements {
+				s.object(el)
+			}
+		}
+	case *object.Map:
+		if s.visit(obj) {
+			s.total += object.Size(obj)
+			for _, val := range obj.Pairs {
+				s.object(val)
+			}
+		}
+	case *object.Module:
+		if s.visit(obj) {
+			for _, member := range obj.Members {
+				s.object(member)
+			}
+		}
+	case *object.ReturnValue:
+		s.object(obj.Value)
+	case *Function:
+		if s.visit(obj) {
+			s.total += object.ValueSize
+			s.env(obj.Env)
+		}
+	case *Class:
+		if s.visit(obj) {
+			s.total += object.ValueSize
+			s.env(obj.Env)
+		}
+	case *Instance:
+		if s.visit(obj) {
+			s.total += object.ValueSize
+			s.object(obj.Fields)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package eval_test
+
+import (
+	"testing"
+	"tiger/go/eval"
+	"tiger/go/object"
+)
+
+func TestMemoryUsage(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup string
+		grow  string
+		want  int64 // growth of MemoryUsage
+	}{
+		{"push", "let a = []", "push(a, 1); push(a, 2); push(a, 3)", 3 * object.ValueSize},
+		{"new key", "let m = {}", `m["abc"] = 1`, object.KeySize("abc")},
+		{"existing key", `let m = {"abc": 1}`, `m["abc"] = 2`, 0},
+		{"string", "let s = 1", `s = "hello"`, object.Size(&object.String{Value: "hello"})},
+		{"global", "let a = 1", "let longer = 1", object.KeySize("longer")},
+		{"garbage", "let a = [1, 2, 3]", "a = 0", -object.Size(&object.Array{Elements: make([]object.Object, 3)})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := eval.NewEnvironment()
+			run(t, env, tt.setup)
+			before := env.MemoryUsage()
+			if got, _ := run(t, env, tt.grow); got.Type() == object.ERROR_OBJ {
+				t.Fatal(got.Inspect())
+			}
+			if got := env.MemoryUsage() - before; got != tt.want {
+				t.Errorf("grew by %d bytes, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxMemory(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   string
+		limit bool // whether the script runs out of memory
+	}{
+		{"push", "let a = []\nwhile (true) { push(a, 1) }", true},
+		{"new keys", "let m = {}\nlet i = 0\nwhile (true) { m[\"k\" + i] = i; i = i + 1 }", true},
+		{"same key", "let m = {}\nlet i = 0\nwhile (i < 5000) { m[\"k\"] = i; i = i + 1 }", false},
+		{"string growth", "let s = \"\"\nwhile (true) { s = s + \"abcdefgh\" }", true},
+		{"deep calls", "func f(n) { return f(n + 1) }\nf(0)", true},
+		{"garbage", "let i = 0\nwhile (i < 5000) { let tmp = [1, 2, 3, 4, 5, 6, 7, 8]; i = i + 1 }", false},
+		{"garbage in calls", "func f() { let a = \"abcdefgh\" + \"ijklmnop\"; return len(a) }\nlet i = 0\nwhile (i < 5000) { f(); i = i + 1 }", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := eval.NewEnvironment()
+			env.SetMaxMemory(20000)
+			got, _ := run(t, env, tt.src)
+			err, isErr := got.(*object.Error)
+			switch {
+			case tt.limit && (!isErr || !err.Limit || err.Message != "memory limit exceeded: more than 20000 bytes in use"):
+				t.Errorf("got %s, want the memory limit error", got.Inspect())
+			case !tt.limit && isErr:
+				t.Errorf("got %s, want no error", err.Message)
+			}
+		})
+	}
+}
+
+func TestMaxMemoryNotCaught(t *testing.T) {
+	env := eval.NewEnvironment()
+	env.SetMaxMemory(20000)
+	got, out := run(t, env, "let a = []\ntry { while (true) { push(a, \"abcdefgh\") } } catch (e) { print \"caught\" }")
+	if err, ok := got.(*object.Error); !ok || !err.Limit {
+		t.Errorf("got %s, want a limit error", got.Inspect())
+	}
+	if out != "" {
+		t.Errorf("the handler ran and printed %q", out)
+	}
+
+	// Removing the limit lets the script go on with the values it made
+	env.SetMaxMemory(0)
+	if got, _ := run(t, env, "push(a, 1)\nlen(a) > 0"); got != object.TRUE {
+		t.Errorf("got %s after removing the limit", got.Inspect())
+	}
+}
@@ -0,0 +1,269 @@
+package eval
+
+import (
+	"math"
+	"tiger/go/object"
+)
+
+// The operations below give Tiger values their meaning. Evaluators
+// share them, so a program behaves the same however it is run.
+
+// IsTruthy reports whether obj counts as true in a condition
+func IsTruthy(obj object.Object) bool {
+	switch obj := obj.(type) {
+	case *object.Boolean:
+		return obj.Value
+	case *object.Null:
+		return false
+	case *object.Integer:
+		return obj.Value != 0
+	case *object.Float:
+		return obj.Value != 0
+	case *object.String:
+		return obj.Value != ""
+	default:
+		return true
+	}
+}
+
+// UnaryOp applies a prefix operator to an evaluated operand
+func UnaryOp(operator string, right object.Object) object.Object {
+	switch operator {
+	case "!":
+		return object.NativeBool(!IsTruthy(right))
+	case "-":
+		switch right := right.(type) {
+		case *object.Integer:
+			return &object.Integer{Value: -right.Value}
+		case *object.Float:
+			return &object.Float{Value: -right.Value}
+		}
+	}
+	return newError("error: unsupported operand for %s: %s", operator, right.Type())
+}
+
+func toFloat(obj object.Object) (float64, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value), true
+	case *object.Float:
+		return obj.Value, true
+	}
+	return 0, false
+}
+
+// BinaryOp applies an infix operator to evaluated operands
+func BinaryOp(operator string, left, right object.Object) object.Object {
+	leftFloat, leftNum := toFloat(left)
+	rightFloat, rightNum := toFloat(right)
+
+	if leftNum && rightNum {
+		switch operator {
+		case "==":
+			return object.NativeBool(leftFloat == rightFloat)
+		case "!=":
+			return object.NativeBool(leftFloat != rightFloat)
+		case "<":
+			return object.NativeBool(leftFloat < rightFloat)
+		case "<=":
+			return object.NativeBool(leftFloat <= rightFloat)
+		case ">":
+			return object.NativeBool(leftFloat > rightFloat)
+		case ">=":
+			return object.NativeBool(leftFloat >= rightFloat)
+		}
+
+		// Integer operands stay exact where the result is an integer
+		leftInt, leftIsInt := left.(*object.Integer)
+		rightInt, rightIsInt := right.(*object.Integer)
+		if leftIsInt && rightIsInt {
+			switch operator {
+			case "+":
+				return &object.Integer{Value: leftInt.Value + rightInt.Value}
+			case "-":
+				return &object.Integer{Value: leftInt.Value - rightInt.Value}
+			case "*":
+				return &object.Integer{Value: leftInt.Value * rightInt.Value}
+			}
+		}
+
+		var result float64
+		switch operator {
+		case "+":
+			result = leftFloat + rightFloat
+		case "-":
+			result = leftFloat - rightFloat
+		case "*":
+			result = leftFloat * rightFloat
+		case "/":
+			if rightFloat != 0 {
+				result = leftFloat / rightFloat
+			} else {
+				return newError("division by zero")
+			}
+		default:
+			return newError("unsupported arithmetic operator: %s", operator)
+		}
+
+		// Whole results are integers, as in 1.5 * 2
+		if result == math.Trunc(result) && math.Abs(result) < math.MaxInt64 {
+			return &object.Integer{Value: int64(result)}
+		}
+		return &object.Float{Value: result}
+	}
+
+	switch operator {
+	case "==":
+		return object.NativeBool(object.Equal(left, right))
+	case "!=":
+		return object.NativeBool(!object.Equal(left, right))
+	}
+
+	leftStr, leftIsStr := left.(*object.String)
+	rightStr, rightIsStr := right.(*object.String)
+	if leftIsStr && rightIsStr {
+		switch operator {
+		case "<":
+			return object.NativeBool(leftStr.Value < rightStr.Value)
+		case "<=":
+			return object.NativeBool(leftStr.Value <= rightStr.Value)
+		case ">":
+			return object.NativeBool(leftStr.Value > rightStr.Value)
+		case ">=":
+			return object.NativeBool(leftStr.Value >= rightStr.Value)
+		}
+	}
+
+	// String concatenation for +
+	if operator == "+" && (leftIsStr || rightIsStr) {
+		return &object.String{Value: left.Inspect() + right.Inspect()}
+	}
+
+	return newError("invalid arithmetic operation")
+}
+
+// Index evaluates left[index] for arrays, maps and strings
+func Index(left, index object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return newError("error: array index must be an integer, got %s", index.Type())
+		}
+		if i.Value < 0 || i.Value >= int64(len(left.Elements)) {
+			return newError("error: index %d out of range", i.Value)
+		}
+		return left.Elements[i.Value]
+	case *object.Map:
+		key, ok := index.(*object.String)
+		if !ok {
+			return newError("error: map keys must be strings, got %s", index.Type())
+		}
+		if val, ok := left.Get(key.Value); ok {
+			return val
+		}
+		return object.NULL
+	case *object.String:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return newError("error: string index must be an integer, got %s", index.Type())
+		}
+		runes := []rune(left.Value)
+		if i.Value < 0 || i.Value >= int64(len(runes)) {
+			return newError("error: index %d out of range", i.Value)
+		}
+		return &object.String{Value: string(runes[i.Value])}
+	}
+	return newError("error: cannot index %s", left.Type())
+}
+
+// SetIndex performs left[index] = val
+func SetIndex(left, index, val object.Object) *object.Error {
+	switch left := left.(type) {
+	case *object.Array:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return newError("error: array index must be an integer, got %s", index.Type())
+		}
+		if i.Value < 0 || i.Value >= int64(len(left.Elements)) {
+			return newError("error: index %d out of range", i.Value)
+		}
+		left.Elements[i.Value] = val
+	case *object.Map:
+		key, ok := index.(*object.String)
+		if !ok {
+			return newError("error: map keys must be strings, got %s", index.Type())
+		}
+		left.Set(key.Value, val)
+	default:
+		return newError("error: cannot assign to index of %s", left.Type())
+	}
+	return nil
+}
+
+// SetMember performs obj.name = val
+func SetMember(obj object.Object, name string, val object.Object) *object.Error {
+	switch obj := obj.(type) {
+	case *object.Map:
+		obj.Set(name, val)
+	case *Instance:
+		obj.Fields.Set(name, val)
+	case object.MemberSetter:
+		if err := obj.SetMember(name, val); err != nil {
+			return newError("error: %s", err)
+		}
+	default:
+		return newError("error: cannot assign to member of %s", obj.Type())
+	}
+	return nil
+}
+
+// Member evaluates obj.name
+func Member(obj object.Object, name string) object.Object {
+	switch obj := obj.(type) {
+	case *object.Module:
+		if member, ok := obj.Members[name]; ok {
+			return member
+		}
+		return newError("error: module %s has no member %s", obj.Name, name)
+	case *object.Map:
+		if val, ok := obj.Get(name); ok {
+			return val
+		}
+		return object.NULL
+	case *Instance:
+		if val, ok := obj.Fields.Get(name); ok {
+			return val
+		}
+		if method, ok := BindMethod(obj, name); ok {
+			return method
+		}
+		return object.NULL
+	case object.MemberGetter:
+		if member, ok := obj.GetMember(name); ok {
+			return member
+		}
+	}
+	return newError("error: %s has no member %s", obj.Type(), name)
+}
+
+// CaughtValue is what a catch clause binds: the thrown value, or for
+// runtime errors a map with the error message
+func CaughtValue(err *object.Error) object.Object {
+	if err.Value != nil {
+		return err.Value
+	}
+	m := object.NewMap()
+	m.Set("message", &object.String{Value: err.Message})
+	if err.Kind != "" {
+		m.Set("kind", &object.String{Value: err.Kind})
+	}
+	if err.Stack != nil {
+		stack := make([]object.Object, len(err.Stack))
+		for i, frame := range err.Stack {
+			stack[i] = &object.String{Value: frame}
+		}
+		m.Set("stack", &object.Array{Elements: stack})
+	}
+	return m
+}
@@ -0,0 +1,88 @@
+package eval
+
+import (
+	"fmt"
+	"tiger/go/object"
+)
+
+// DefaultMaxDepth is the call depth allowed before a StackOverflow error
+const DefaultMaxDepth = 10000
+
+// overflowFrames is how many of the innermost frames a StackOverflow
+// error reports
+const overflowFrames = 5
+
+// Frame is one active Tiger function call. Line is the line of the call
+// that entered it, or 0 when host code made the call; Defined is the
+// line of the function's func keyword.
+type Frame struct {
+	Function string
+	Line     int
+	Defined  int
+
+	env *Environment // scope of the call
+}
+
+func (f Frame) String() string {
+	if f.Line == 0 {
+		return "at " + f.Function
+	}
+	return fmt.Sprintf("at %s (line %d)", f.Function, f.Line)
+}
+
+// SetMaxDepth limits how deeply Tiger functions may call each other.
+// Zero restores DefaultMaxDepth.
+func (e *Environment) SetMaxDepth(n int) {
+	e.rt.maxDepth = n
+}
+
+// CallStack returns the active calls, innermost first
+func (e *Environment) CallStack() []Frame {
+	frames := e.rt.frames
+	stack := make([]Frame, len(frames))
+	for i, frame := range frames {
+		stack[len(frames)-1-i] = frame
+	}
+	return stack
+}
+
+// pushFrame enters a call, failing with a catchable StackOverflow error
+// once the maximum depth is reached
+func (rt *runtime) pushFrame(frame Frame) *object.Error {
+	maxDepth := rt.maxDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	if len(rt.frames) >= maxDepth {
+		return StackOverflow(maxDepth, append(rt.frames, frame))
+	}
+	rt.frames = append(rt.frames, frame)
+	return nil
+}
+
+// StackOverflow makes the error raised when a call would exceed
+// maxDepth, listing the innermost of frames, which run outermost first
+func StackOverflow(maxDepth int, frames []Frame) *object.Error {
+	err := newError("StackOverflow: maximum call depth of %d exceeded", maxDepth)
+	err.Kind = "StackOverflow"
+	for i := len(frames) - 1; i >= 0 && len(err.Stack) < overflowFrames; i-- {
+		err.Stack = append(err.Stack, frames[i].String())
+	}
+	return err
+}
+
+func (rt *runtime) popFrame() {
+	rt.frames = rt.frames[:len(rt.frames)-1]
+}
+
+// frameName is how fn appears in stack traces
+func frameName(fn *Function) string {
+	name := fn.Literal.Name
+	if name == "" {
+		name = "<anonymous>"
+	}
+	if fn.This != nil {
+		name = fn.This.Class.Name + "." + name
+	}
+	return name
+}
@@ -0,0 +1,80 @@
+package eval_test
+
+import (
+	"strings"
+	"testing"
+	"tiger/go/eval"
+	"tiger/go/object"
+)
+
+func TestStackOverflow(t *testing.T) {
+	env := eval.NewEnvironment()
+	env.SetMaxDepth(20)
+	got, _ := run(t, env, "func down(n) { return down(n + 1) }\n\ndown(0)")
+	err, ok := got.(*object.Error)
+	if !ok {
+		t.Fatalf("got %s, want an error", got.Inspect())
+	}
+	if err.Message != "StackOverflow: maximum call depth of 20 exceeded" || err.Kind != "StackOverflow" || err.Limit {
+		t.Errorf("got %q of kind %q, limit %v", err.Message, err.Kind, err.Limit)
+	}
+	// Only the innermost frames are kept
+	want := []string{"at down (line 1)", "at down (line 1)", "at down (line 1)", "at down (line 1)", "at down (line 1)"}
+	if strings.Join(err.Stack, "|") != strings.Join(want, "|") {
+		t.Errorf("stack %q, want %q", err.Stack, want)
+	}
+	if frames := env.CallStack(); len(frames) != 0 {
+		t.Errorf("%d frames left on the stack after the overflow", len(frames))
+	}
+}
+
+func TestStackOverflowFrames(t *testing.T) {
+	env := eval.NewEnvironment()
+	env.SetMaxDepth(3)
+	src := `class Walker {
+    func step(n) { return walk(n) }
+}
+func walk(n) { return Walker().step(n) }
+walk(1)`
+	got, _ := run(t, env, src)
+	err, ok := got.(*object.Error)
+	if !ok {
+		t.Fatalf("got %s, want an error", got.Inspect())
+	}
+	// The frame that would exceed the depth comes first
+	want := []string{"at Walker.step (line 4)", "at walk (line 2)", "at Walker.step (line 4)", "at walk (line 5)"}
+	if strings.Join(err.Stack, "|") != strings.Join(want, "|") {
+		t.Errorf("stack %q, want %q", err.Stack, want)
+	}
+
+	frames := []eval.Frame{{Function: "main"}, {Function: "f", Line: 3}}
+	for i := 0; i < 10; i++ {
+		frames = append(frames, eval.Frame{Function: "g", Line: 10 + i})
+	}
+	overflow := eval.StackOverflow(7, frames)
+	want = []string{"at g (line 19)", "at g (line 18)", "at g (line 17)", "at g (line 16)", "at g (line 15)"}
+	if strings.Join(overflow.Stack, "|") != strings.Join(want, "|") {
+		t.Errorf("StackOverflow kept %q, want the innermost five %q", overflow.Stack, want)
+	}
+}
+
+func TestStackOverflowIsCatchable(t *testing.T) {
+	env := eval.NewEnvironment()
+	env.SetMaxDepth(10)
+	src := `func down() { return down() }
+try {
+    down()
+} catch (e) {
+    print e.kind
+    print e.message
+    print len(e.stack)
+    print e.stack[0]
+}
+func ok(n) { if (n == 0) { return "recovered" }; return ok(n - 1) }
+print ok(5)`
+	got, out := run(t, env, src)
+	want := "StackOverflow\nStackOverflow: maximum call depth of 10 exceeded\n5\nat down (line 1)\nrecovered\n"
+	if out != want {
+		t.Errorf("printed %q, want %q (result %s)", out, want, got.Inspect())
+	}
+}
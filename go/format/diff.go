@@ -0,0 +1,149 @@
+package format
+
+import (
+	"fmt"
+	"strings"
+)
+
+// context is the number of unchanged lines shown around each change
+const context = 3
+
+// Diff returns a unified diff turning old into new, or nil when they are
+// equal. Name labels both sides.
+func Diff(name string, old, new []byte) []byte {
+	if string(old) == string(new) {
+		return nil
+	}
+	a := splitLines(string(old))
+	b := splitLines(string(new))
+	ops := edits(a, b)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s.orig\n+++ %s\n", name, name)
+	for start := 0; start < len(ops); {
+		// Find the next change and the extent of its hunk
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+		first := max(start-context, 0)
+		end := start
+		for unchanged := 0; end < len(ops) && unchanged <= 2*context; end++ {
+			if ops[end].kind == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+		}
+		last := end
+		for last > start && ops[last-1].kind == ' ' {
+			last--
+		}
+		last = min(last+context, len(ops))
+
+		hunk := ops[first:last]
+		aStart, bStart := ops[first].a+1, ops[first].b+1
+		aLen, bLen := 0, 0
+		for _, op := range hunk {
+			if op.kind != '+' {
+				aLen++
+			}
+			if op.kind != '-' {
+				bLen++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", aStart, aLen, bStart, bLen)
+		for _, op := range hunk {
+			out.WriteByte(op.kind)
+			out.WriteString(op.text)
+			out.WriteByte('\n')
+		}
+		start = last
+	}
+	return []byte(out.String())
+}
+
+func splitLines(s string) []string {
+	lines := strings.Split(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// edit is one line of a diff: kept (' '), removed ('-') or added ('+'),
+// with its index in the old and new text
+type edit struct {
+	kind byte
+	text string
+	a, b int
+}
+
+// edits computes a shortest edit script with Myers' algorithm
+func edits(a, b []string) []edit {
+	n, m := len(a), len(b)
+	offset := n + m
+	v := make([]int, 2*offset+2)
+	var trace [][]int
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b, offset)
+			}
+		}
+	}
+	return nil
+}
+
+// backtrack walks the saved frontiers from the end of both texts back
+// to the start, recording each step
+func backtrack(trace [][]int, a, b []string, offset int) []edit {
+	var ops []edit
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, edit{' ', a[x], x, y})
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			y--
+			ops = append(ops, edit{'+', b[y], x, y})
+		} else {
+			x--
+			ops = append(ops, edit{'-', a[x], x, y})
+		}
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
@@ -0,0 +1,499 @@
+// Package format pretty-prints Tiger source in a single canonical style:
+// four-space indentation, braces on the line that opens them, spaces
+// around binary operators and only the parentheses precedence requires.
+// Comments and single blank lines between statements are kept, and a
+// list of arguments, elements or entries written across lines is printed
+// one to a line.
+package format
+
+import (
+	"strings"
+	"tiger/go/ast"
+	"tiger/go/lexer"
+	"tiger/go/parser"
+)
+
+const indentation = "    "
+
+// Source formats a Tiger program. Formatting its output again changes
+// nothing.
+func Source(src []byte) ([]byte, error) {
+	l := lexer.New(string(src))
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return nil, p.ErrorList()
+	}
+
+	pr := &printer{
+		lines:    strings.Split(string(src), "\n"),
+		comments: l.Comments(),
+		fresh:    true,
+	}
+	for _, stmt := range program.Statements {
+		pr.statement(stmt)
+	}
+	pr.leading(len(pr.lines) + 1)
+	return []byte(pr.out.String()), nil
+}
+
+type printer struct {
+	out      strings.Builder
+	lines    []string
+	comments []lexer.Comment
+	indent   int
+
+	line  int  // source line whose trailing comments end the output line
+	last  int  // source line of the last statement or comment printed
+	fresh bool // nothing printed yet in the current block
+}
+
+func (p *printer) write(s string) {
+	p.out.WriteString(s)
+}
+
+func (p *printer) writeIndent() {
+	p.write(strings.Repeat(indentation, p.indent))
+}
+
+// newline ends the output line, first appending the comments written
+// after the code on p.line
+func (p *printer) newline() {
+	for len(p.comments) > 0 && p.line > 0 && p.comments[0].Line == p.line {
+		p.write(" " + p.comments[0].Text)
+		p.comments = p.comments[1:]
+	}
+	p.line = 0
+	p.write("\n")
+}
+
+// at writes, within the line, the comments before the token at line
+// and column that is printed next. A line comment there would end the
+// line early, so it is written as a block comment.
+func (p *printer) at(line, column int) {
+	for p.before(line, column) {
+		p.write(inlineComment(p.comments[0].Text) + " ")
+		p.comments = p.comments[1:]
+	}
+	p.line = max(p.line, line)
+}
+
+// closing is at for a closing bracket, which follows the comments
+// before it instead of being preceded by them
+func (p *printer) closing(line, column int) {
+	for p.before(line, column) {
+		p.write(" " + inlineComment(p.comments[0].Text))
+		p.comments = p.comments[1:]
+	}
+	p.line = max(p.line, line)
+}
+
+func (p *printer) before(line, column int) bool {
+	if len(p.comments) == 0 || line == 0 {
+		return false
+	}
+	c := p.comments[0]
+	return c.Line < line || c.Line == line && c.Column < column
+}
+
+func inlineComment(text string) string {
+	if !strings.HasPrefix(text, "//") {
+		return text
+	}
+	return "/* " + strings.ReplaceAll(strings.TrimSpace(text[2:]), "*/", "* /") + " */"
+}
+
+// leading prints, each on its own line, the comments before line
+func (p *printer) leading(line int) {
+	for len(p.comments) > 0 && p.comments[0].Line < line {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+		p.separate(c.Line)
+		p.writeIndent()
+		p.write(c.Text)
+		p.write("\n")
+	}
+}
+
+// separate keeps one blank line before line when the source had any
+func (p *printer) separate(line int) {
+	if !p.fresh && line-1 > p.last && line >= 2 && strings.TrimSpace(p.lines[line-2]) == "" {
+		p.write("\n")
+	}
+	p.fresh = false
+	p.last = line
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	line := stmt.StartLine()
+	p.leading(line)
+	p.separate(line)
+	p.writeIndent()
+	p.line = line
+	p.simple(stmt)
+	p.newline()
+}
+
+// simple prints a statement without indentation or the final newline
+func (p *printer) simple(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok && fn.Name == stmt.Name.Value {
+			p.function(fn)
+			return
+		}
+		p.write("let " + declared(stmt.Name) + " = ")
+		p.expression(stmt.Value, parser.LOWEST)
+		p.write(";")
+	case *ast.ConstStatement:
+		p.write("const " + declared(stmt.Name) + " = ")
+		p.expression(stmt.Value, parser.LOWEST)
+		p.write(";")
+	case *ast.PrintStatement:
+		p.write("print ")
+		p.expression(stmt.Value, parser.LOWEST)
+		p.write(";")
+	case *ast.ExpressionStatement:
+		p.expressionStatement(stmt)
+		p.write(";")
+	case *ast.ReturnStatement:
+		p.write("return")
+		if stmt.Value != nil {
+			p.write(" ")
+			p.expression(stmt.Value, parser.LOWEST)
+		}
+		p.write(";")
+	case *ast.ThrowStatement:
+		p.write("throw ")
+		p.expression(stmt.Value, parser.LOWEST)
+		p.write(";")
+	case *ast.IfStatement:
+		p.write("if ")
+		p.expression(stmt.Condition, parser.LOWEST)
+		p.write(" ")
+		p.block(stmt.Consequence)
+		if stmt.Alternative != nil {
+			p.write(" else ")
+			p.block(stmt.Alternative)
+		}
+	case *ast.WhileStatement:
+		p.write("while ")
+		p.expression(stmt.Condition, parser.LOWEST)
+		p.write(" ")
+		p.block(stmt.Body)
+	case *ast.ForStatement:
+		p.write("for (")
+		switch init := stmt.Init.(type) {
+		case *ast.LetStatement:
+			p.write("let " + declared(init.Name) + " = ")
+			p.expression(init.Value, parser.LOWEST)
+		case *ast.ExpressionStatement:
+			p.expressionStatement(init)
+		}
+		p.write(";")
+		if stmt.Condition != nil {
+			p.write(" ")
+			p.expression(stmt.Condition, parser.LOWEST)
+		}
+		p.write(";")
+		if update, ok := stmt.Update.(*ast.ExpressionStatement); ok {
+			p.write(" ")
+			p.expressionStatement(update)
+		}
+		p.write(") ")
+		p.block(stmt.Body)
+	case *ast.BlockStatement:
+		p.block(stmt)
+	case *ast.ClassStatement:
+		p.class(stmt)
+	case *ast.TryStatement:
+		p.write("try ")
+		p.block(stmt.Block)
+		p.write(" catch ")
+		if stmt.Param != nil {
+			p.write("(" + stmt.Param.Value + ") ")
+		}
+		p.block(stmt.Handler)
+	}
+}
+
+// expressionStatement prints an expression in statement position, where
+// a leading brace would open a block rather than a map
+func (p *printer) expressionStatement(stmt *ast.ExpressionStatement) {
+	if startsWithMap(stmt.Expression) {
+		p.write("(")
+		p.expression(stmt.Expression, parser.LOWEST)
+		p.write(")")
+		return
+	}
+	p.expression(stmt.Expression, parser.LOWEST)
+}
+
+func startsWithMap(expr ast.Expression) bool {
+	for {
+		switch e := expr.(type) {
+		case *ast.MapLiteral:
+			return true
+		case *ast.InfixExpression:
+			expr = e.Left
+		case *ast.CallExpression:
+			expr = e.Function
+		case *ast.IndexExpression:
+			expr = e.Left
+		case *ast.MemberExpression:
+			expr = e.Object
+		case *ast.AssignExpression:
+			expr = e.Target
+		default:
+			return false
+		}
+	}
+}
+
+func (p *printer) block(block *ast.BlockStatement) {
+	p.write("{")
+	if len(block.Statements) == 0 && !p.commentsBefore(block.EndLine) {
+		p.write("}")
+		p.line = block.EndLine
+		return
+	}
+	p.newline()
+	p.indent++
+	p.fresh = true
+	for _, stmt := range block.Statements {
+		p.statement(stmt)
+	}
+	p.leading(block.EndLine)
+	p.indent--
+	p.writeIndent()
+	p.write("}")
+	p.last = block.EndLine
+	p.line = block.EndLine
+}
+
+func (p *printer) commentsBefore(line int) bool {
+	return len(p.comments) > 0 && p.comments[0].Line < line
+}
+
+func (p *printer) class(stmt *ast.ClassStatement) {
+	p.write("class " + stmt.Name.Value + " {")
+	if len(stmt.Methods) == 0 && !p.commentsBefore(stmt.EndLine) {
+		p.write("}")
+		return
+	}
+	p.newline()
+	p.indent++
+	p.fresh = true
+	for _, method := range stmt.Methods {
+		p.leading(method.Line)
+		p.separate(method.Line)
+		p.writeIndent()
+		p.line = method.Line
+		p.function(method)
+		p.newline()
+	}
+	p.leading(stmt.EndLine)
+	p.indent--
+	p.writeIndent()
+	p.write("}")
+	p.last = stmt.EndLine
+	p.line = stmt.EndLine
+}
+
+// function prints a function definition or literal
+func (p *printer) function(fn *ast.FunctionLiteral) {
+	p.write("func")
+	if fn.Name != "" {
+		p.write(" " + fn.Name)
+	}
+	params := make([]ast.Expression, len(fn.Parameters))
+	for i, param := range fn.Parameters {
+		params[i] = param
+	}
+	// The parameters end where the return type or body begins
+	endLine, endColumn := fn.Body.Line, fn.Body.Column
+	if fn.ReturnType != nil {
+		endLine, endColumn = fn.ReturnType.Line, fn.ReturnType.Column
+	}
+	p.write("(")
+	p.list(fn.Line, params, params, endLine, endColumn, ")", func(i int) {
+		p.at(ast.Start(params[i]))
+		p.write(declared(fn.Parameters[i]))
+	})
+	if fn.ReturnType != nil {
+		p.write(": " + fn.ReturnType.Name)
+	}
+	p.write(" ")
+	p.block(fn.Body)
+}
+
+// declared renders a declared name with its type annotation
+func declared(ident *ast.Identifier) string {
+	if ident.Type == nil {
+		return ident.Value
+	}
+	return ident.Value + ": " + ident.Type.Name
+}
+
+// precedence returns how tightly expr binds, on the parser's scale
+func precedence(expr ast.Expression) int {
+	switch expr := expr.(type) {
+	case *ast.AssignExpression:
+		return parser.ASSIGN
+	case *ast.InfixExpression:
+		return parser.OperatorPrecedence(expr.Operator)
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.CallExpression, *ast.IndexExpression, *ast.MemberExpression:
+		return parser.POSTFIX
+	}
+	return parser.POSTFIX + 1
+}
+
+// expression prints expr, parenthesized if it binds less tightly than
+// min
+func (p *printer) expression(expr ast.Expression, min int) {
+	p.at(ast.Start(expr))
+	if precedence(expr) < min {
+		p.write("(")
+		defer p.write(")")
+	}
+
+	switch expr := expr.(type) {
+	case *ast.InfixExpression:
+		prec := precedence(expr)
+		p.expression(expr.Left, prec)
+		p.write(" ")
+		p.at(expr.Line, expr.Column)
+		p.write(expr.Operator + " ")
+		// Operators are left-associative
+		p.expression(expr.Right, prec+1)
+	case *ast.PrefixExpression:
+		p.write(expr.Operator)
+		// -(-a) would read as --a
+		if right, ok := expr.Right.(*ast.PrefixExpression); ok && right.Operator == expr.Operator {
+			p.write("(")
+			p.expression(right, parser.LOWEST)
+			p.write(")")
+			break
+		}
+		p.expression(expr.Right, parser.PREFIX)
+	case *ast.AssignExpression:
+		p.expression(expr.Target, parser.POSTFIX)
+		p.write(" = ")
+		p.expression(expr.Value, parser.ASSIGN)
+	case *ast.CallExpression:
+		p.expression(expr.Function, parser.POSTFIX)
+		p.write("(")
+		p.expressions(expr.Line, expr.Arguments, expr.EndLine, expr.EndColumn, ")")
+	case *ast.IndexExpression:
+		p.expression(expr.Left, parser.POSTFIX)
+		p.write("[")
+		p.expression(expr.Index, parser.LOWEST)
+		p.write("]")
+	case *ast.MemberExpression:
+		p.expression(expr.Object, parser.POSTFIX)
+		p.write("." + expr.Property.Value)
+	case *ast.ArrayLiteral:
+		p.write("[")
+		p.expressions(expr.Line, expr.Elements, expr.EndLine, expr.EndColumn, "]")
+	case *ast.MapLiteral:
+		p.write("{")
+		p.list(expr.Line, expr.Keys, expr.Values, expr.EndLine, expr.EndColumn, "}", func(i int) {
+			p.expression(expr.Keys[i], parser.LOWEST)
+			p.write(": ")
+			p.expression(expr.Values[i], parser.LOWEST)
+		})
+	case *ast.FunctionLiteral:
+		p.function(expr)
+	default:
+		p.write(expr.String())
+	}
+}
+
+func (p *printer) expressions(open int, exprs []ast.Expression, endLine, endColumn int, closer string) {
+	p.list(open, exprs, exprs, endLine, endColumn, closer, func(i int) {
+		p.expression(exprs[i], parser.LOWEST)
+	})
+}
+
+// list prints with item the elements of a list opened on line open, up
+// to its closer at endLine and endColumn; each element begins with the
+// expression in elems and ends with the one in ends. When an element
+// starts on a later line than the one before it ends, each is printed on
+// its own line with a trailing comma, so that comments stay beside the
+// element they were written next to.
+func (p *printer) list(open int, elems, ends []ast.Expression, endLine, endColumn int, closer string, item func(i int)) {
+	multiline, prev := false, open
+	for i, elem := range elems {
+		if line, _ := ast.Start(elem); line > prev {
+			multiline = true
+		}
+		prev = lastLine(ends[i])
+	}
+	if !multiline {
+		for i := range elems {
+			if i > 0 {
+				p.closing(ast.Start(elems[i]))
+				p.write(", ")
+			}
+			item(i)
+		}
+		p.closing(endLine, endColumn)
+		p.write(closer)
+		return
+	}
+
+	// Comments after the opening bracket stay on its line, unless the
+	// first element shares it
+	p.line = open
+	if line, _ := ast.Start(elems[0]); line == open {
+		p.line = 0
+	}
+	p.newline()
+	p.indent++
+	p.fresh = true
+	for i, elem := range elems {
+		line, _ := ast.Start(elem)
+		p.leading(line)
+		p.separate(line)
+		p.writeIndent()
+		p.line = line
+		item(i)
+		p.write(",")
+		p.newline()
+	}
+	p.leading(endLine)
+	p.indent--
+	p.writeIndent()
+	p.write(closer)
+	p.last = endLine
+	p.line = endLine
+}
+
+// lastLine returns the line on which expr ends, or where its last part
+// with a known position starts
+func lastLine(expr ast.Expression) int {
+	switch expr := expr.(type) {
+	case *ast.CallExpression:
+		return expr.EndLine
+	case *ast.ArrayLiteral:
+		return expr.EndLine
+	case *ast.MapLiteral:
+		return expr.EndLine
+	case *ast.FunctionLiteral:
+		return expr.Body.EndLine
+	case *ast.PrefixExpression:
+		return lastLine(expr.Right)
+	case *ast.InfixExpression:
+		return lastLine(expr.Right)
+	case *ast.IndexExpression:
+		return lastLine(expr.Index)
+	case *ast.MemberExpression:
+		return expr.Property.Line
+	case *ast.AssignExpression:
+		return lastLine(expr.Value)
+	}
+	line, _ := ast.Start(expr)
+	return line
+}
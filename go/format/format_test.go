@@ -0,0 +1,104 @@
+package format_test
+
+import (
+	"testing"
+	"tiger/go/format"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "canonical layout",
+			src:  "let x=1\nif (x>0) {print x}\nwhile ((x < 3)) { x = x + 1 }\nfor (let i = 0; i < 2; i = i + 1) {}\n",
+			want: "let x = 1;\nif x > 0 {\n    print x;\n}\nwhile x < 3 {\n    x = x + 1;\n}\nfor (let i = 0; i < 2; i = i + 1) {}\n",
+		},
+		{
+			name: "conditions starting with a map",
+			src:  "if ({\"a\": 1}[\"a\"] == 1) { print 1 }\n",
+			want: "if {\"a\": 1}[\"a\"] == 1 {\n    print 1;\n}\n",
+		},
+		{
+			name: "only the parentheses precedence requires",
+			src:  "let a = ((1 + 2)) * 3 - (4 - 5)\nlet b = (a + 1)\n",
+			want: "let a = (1 + 2) * 3 - (4 - 5);\nlet b = a + 1;\n",
+		},
+		{
+			name: "repeated unary operators",
+			src:  "let a = 1\nlet b = -(-a)\nlet c = !(!true)\nlet d = -(!a)\n",
+			want: "let a = 1;\nlet b = -(-a);\nlet c = !(!true);\nlet d = -!a;\n",
+		},
+		{
+			name: "comments and blank lines",
+			src:  "// lead\nlet x = 1 // trailing\n\n\n/* block */\nprint x\n",
+			want: "// lead\nlet x = 1; // trailing\n\n/* block */\nprint x;\n",
+		},
+		{
+			name: "map entries keep their comments",
+			src:  "let m = { // settings\n    \"a\": 1, // first\n    // about b\n    \"b\": 2\n}\n",
+			want: "let m = { // settings\n    \"a\": 1, // first\n    // about b\n    \"b\": 2,\n};\n",
+		},
+		{
+			name: "parameter comments",
+			src:  "func f(a /* int */, b /* string */) {\n    return a\n}\n",
+			want: "func f(a /* int */, b /* string */) {\n    return a;\n}\n",
+		},
+		{
+			name: "comment after a call argument",
+			src:  "print max(1, // one\n    2)\n",
+			want: "print max(\n    1, // one\n    2,\n);\n",
+		},
+		{
+			name: "line comment inside an expression",
+			src:  "let z = 1 + // why\n    2\n",
+			want: "let z = 1 + /* why */ 2;\n",
+		},
+		{
+			name: "comment before a closing bracket",
+			src:  "print [1, 2 /* end */]\n",
+			want: "print [1, 2 /* end */];\n",
+		},
+		{
+			name: "function arguments stay on the call's line",
+			src:  "f(func() {\n    print 1\n}, \"x\")\n",
+			want: "f(func() {\n    print 1;\n}, \"x\");\n",
+		},
+		{
+			name: "a statement starting with a map",
+			src:  "({\"a\": 1})[\"a\"]\n",
+			want: "({\"a\": 1}[\"a\"]);\n",
+		},
+		{
+			name: "empty blocks and classes",
+			src:  "class C {}\nfunc f() {\n}\n",
+			want: "class C {}\nfunc f() {}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := format.Source([]byte(tt.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+			again, err := format.Source(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(again) != string(got) {
+				t.Errorf("formatting again gives:\n%s", again)
+			}
+		})
+	}
+}
+
+func TestSourceParseError(t *testing.T) {
+	if _, err := format.Source([]byte("let = 1")); err == nil {
+		t.Error("no error for invalid source")
+	}
+}
@@ -1,6 +1,7 @@
 package lexer
 
 import (
+	"strings"
 	"tiger/go/token"
 	"unicode"
 )
@@ -10,15 +11,32 @@ type Lexer struct {
 	position     int  // current position in input (points to current char)
 	readPosition int  // current reading position in input (after current char)
 	ch           byte // current char under examination
+	line         int  // 1-based line of ch
+	column       int  // 1-based column of ch, in bytes
+
+	comments []Comment
+}
+
+// Comment is a comment skipped by the lexer, kept for tools such as the
+// formatter. Text includes the // or /* */ delimiters.
+type Comment struct {
+	Text   string
+	Line   int
+	Column int
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+	l.column++
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -30,7 +48,17 @@ func (l *Lexer) readChar() {
 
 func (l *Lexer) NextToken() token.Token {
 	l.skipWhitespace()
+	line, column := l.line, l.column
 
+	tok := l.readToken()
+	// Tokens found after a comment already carry their own position
+	if tok.Line == 0 {
+		tok.Line, tok.Column = line, column
+	}
+	return tok
+}
+
+func (l *Lexer) readToken() token.Token {
 	tok := token.Token{Literal: string(l.ch)}
 
 	switch l.ch {
@@ -81,11 +109,17 @@ func (l *Lexer) NextToken() token.Token {
 			ch := l.ch
 			l.readChar()
 			tok = token.Token{Type: token.NOT_EQ, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok.Type = token.BANG
 		}
 	case ',':
 		tok.Type = token.COMMA
 	case ';':
 		tok.Type = token.SEMICOLON
+	case ':':
+		tok.Type = token.COLON
+	case '.':
+		tok.Type = token.DOT
 	case '(':
 		tok.Type = token.LPAREN
 	case ')':
@@ -94,6 +128,10 @@ func (l *Lexer) NextToken() token.Token {
 		tok.Type = token.LBRACE
 	case '}':
 		tok.Type = token.RBRACE
+	case '[':
+		tok.Type = token.LBRACKET
+	case ']':
+		tok.Type = token.RBRACKET
 	case '"':
 		tok.Type = token.STRING
 		tok.Literal = l.readString()
@@ -155,14 +193,35 @@ func (l *Lexer) readNumber() (string, bool) {
 }
 
 func (l *Lexer) readString() string {
-	start := l.position + 1
+	var out strings.Builder
 	for {
 		l.readChar()
 		if l.ch == '"' || l.ch == 0 {
 			break
 		}
+		if l.ch == '\\' {
+			l.readChar()
+			switch l.ch {
+			case 'n':
+				out.WriteByte('\n')
+			case 't':
+				out.WriteByte('\t')
+			case 'r':
+				out.WriteByte('\r')
+			case '"', '\\':
+				out.WriteByte(l.ch)
+			case 0:
+				return out.String()
+			default:
+				// Unknown escapes are kept as written
+				out.WriteByte('\\')
+				out.WriteByte(l.ch)
+			}
+			continue
+		}
+		out.WriteByte(l.ch)
 	}
-	return l.input[start:l.position]
+	return out.String()
 }
 
 func (l *Lexer) skipWhitespace() {
@@ -179,13 +238,22 @@ func isDigit(ch byte) bool {
 	return '0' <= ch && ch <= '9'
 }
 
+// Comments returns the comments read so far, in source order
+func (l *Lexer) Comments() []Comment {
+	return l.comments
+}
+
 func (l *Lexer) skipSingleLineComment() {
+	start, line, column := l.position, l.line, l.column
 	for l.ch != '\n' && l.ch != 0 {
 		l.readChar()
 	}
+	l.addComment(start, line, column)
 }
 
 func (l *Lexer) skipMultiLineComment() {
+	start, line, column := l.position, l.line, l.column
+	defer l.addComment(start, line, column)
 	l.readChar() // skip initial '/'
 	l.readChar() // skip initial '*'
 	
@@ -201,3 +269,12 @@ func (l *Lexer) skipMultiLineComment() {
 		l.readChar()
 	}
 }
+
+func (l *Lexer) addComment(start, line, column int) {
+	end := min(l.position, len(l.input))
+	l.comments = append(l.comments, Comment{
+		Text:   strings.TrimRight(l.input[start:end], " \t\r"),
+		Line:   line,
+		Column: column,
+	})
+}
@@ -0,0 +1,518 @@
+// Package lint reports likely mistakes in Tiger programs without running
+// them. Each diagnostic names the rule that found it; a comment
+//
+//	// lint:ignore rule-id
+//
+// on the line before, or at the end of the offending line, silences it.
+// Without rule IDs the comment silences every rule for that line.
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"tiger/go/ast"
+	"tiger/go/eval"
+	"tiger/go/lexer"
+	"tiger/go/object"
+	"tiger/go/parser"
+)
+
+// Rule IDs
+const (
+	Undefined       = "undefined"        // name declared nowhere
+	UnusedVariable  = "unused-variable"  // local never read
+	UnusedParameter = "unused-parameter" // parameter never read
+	Unreachable     = "unreachable"      // code after return or throw
+	ConstAssign     = "const-assign"     // assignment to a constant
+	ArgCount        = "arg-count"        // wrong number of arguments
+	Shadow          = "shadow"           // local hiding an outer name
+)
+
+// Diagnostic is one problem found in a program. Column is 0, and left
+// out of JSON, when only the line is known.
+type Diagnostic struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column,omitempty"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	pos := fmt.Sprintf("%d", d.Line)
+	if d.Column > 0 {
+		pos += fmt.Sprintf(":%d", d.Column)
+	}
+	if d.File != "" {
+		pos = d.File + ":" + pos
+	}
+	return fmt.Sprintf("%s: %s (%s)", pos, d.Message, d.Rule)
+}
+
+// WriteJSON writes diagnostics as an indented JSON array
+func WriteJSON(w io.Writer, diagnostics []Diagnostic) error {
+	if diagnostics == nil {
+		diagnostics = []Diagnostic{}
+	}
+	out, err := json.MarshalIndent(diagnostics, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", out)
+	return err
+}
+
+// Source lints a program, returning its diagnostics ordered by position.
+// Builtins are those registered with eval.RegisterBuiltin.
+func Source(src []byte) ([]Diagnostic, error) {
+	l := lexer.New(string(src))
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return nil, p.ErrorList()
+	}
+
+	c := &checker{}
+	c.program(program)
+	diagnostics := suppress(c.diagnostics, l.Comments(), strings.Split(string(src), "\n"))
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].Line != diagnostics[j].Line {
+			return diagnostics[i].Line < diagnostics[j].Line
+		}
+		return diagnostics[i].Column < diagnostics[j].Column
+	})
+	return diagnostics, nil
+}
+
+// suppress drops the diagnostics silenced by lint:ignore comments
+func suppress(diagnostics []Diagnostic, comments []lexer.Comment, lines []string) []Diagnostic {
+	ignored := map[int][]string{}
+	for _, c := range comments {
+		text := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+		if !strings.HasPrefix(text, "lint:ignore") {
+			continue
+		}
+		rules := strings.FieldsFunc(strings.TrimPrefix(text, "lint:ignore"), func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		if rules == nil {
+			rules = []string{}
+		}
+		line := c.Line
+		// A comment on a line of its own covers the next line
+		if strings.TrimSpace(lines[c.Line-1][:c.Column-1]) == "" {
+			line++
+		}
+		ignored[line] = rules
+	}
+
+	kept := diagnostics[:0]
+	for _, d := range diagnostics {
+		rules, ok := ignored[d.Line]
+		if ok && (len(rules) == 0 || contains(rules, d.Rule)) {
+			continue
+		}
+		kept = append(kept, d)
+	}
+	return kept
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// binding is a declared name. Fn and Class record what a name was
+// declared as, for argument counts; they are cleared when the name is
+// declared twice.
+type binding struct {
+	ident    *ast.Identifier
+	kind     string // "let", "const", "param", "catch", "func" or "class"
+	used     bool
+	assigned bool
+	fn       *ast.FunctionLiteral
+	class    *ast.ClassStatement
+}
+
+// scope is a function body, or the program for globals
+type scope struct {
+	vars   map[string]*binding
+	method bool
+	outer  *scope
+}
+
+func (s *scope) lookup(name string) *binding {
+	for ; s != nil; s = s.outer {
+		if b, ok := s.vars[name]; ok {
+			return b
+		}
+	}
+	return nil
+}
+
+// call is a call to a named function, checked once every assignment
+// has been seen
+type call struct {
+	expr    *ast.CallExpression
+	ident   *ast.Identifier
+	binding *binding
+}
+
+type checker struct {
+	scope       *scope
+	calls       []call
+	diagnostics []Diagnostic
+}
+
+func (c *checker) report(line, column int, rule, format string, a ...interface{}) {
+	c.diagnostics = append(c.diagnostics, Diagnostic{
+		Line:    line,
+		Column:  column,
+		Rule:    rule,
+		Message: fmt.Sprintf(format, a...),
+	})
+}
+
+func (c *checker) program(program *ast.Program) {
+	c.scope = &scope{vars: map[string]*binding{}}
+	for _, stmt := range program.Statements {
+		c.declare(stmt)
+	}
+	c.statements(program.Statements)
+	c.checkCalls()
+}
+
+// declare adds the names stmt declares to the current scope, without
+// entering nested functions
+func (c *checker) declare(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		b := c.add(stmt.Name, "let")
+		if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok && fn.Name == stmt.Name.Value {
+			b.kind = "func"
+			b.fn = fn
+		} else if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+			b.fn = fn
+		}
+	case *ast.ConstStatement:
+		b := c.add(stmt.Name, "const")
+		if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+			b.fn = fn
+		}
+	case *ast.ClassStatement:
+		c.add(stmt.Name, "class").class = stmt
+	case *ast.BlockStatement:
+		for _, inner := range stmt.Statements {
+			c.declare(inner)
+		}
+	case *ast.IfStatement:
+		c.declare(stmt.Consequence)
+		if stmt.Alternative != nil {
+			c.declare(stmt.Alternative)
+		}
+	case *ast.WhileStatement:
+		c.declare(stmt.Body)
+	case *ast.ForStatement:
+		if stmt.Init != nil {
+			c.declare(stmt.Init)
+		}
+		c.declare(stmt.Body)
+	case *ast.TryStatement:
+		c.declare(stmt.Block)
+		if stmt.Param != nil {
+			c.add(stmt.Param, "catch")
+		}
+		c.declare(stmt.Handler)
+	}
+}
+
+// add declares ident in the current scope. Redeclaring a name keeps the
+// first binding but forgets what it holds.
+func (c *checker) add(ident *ast.Identifier, kind string) *binding {
+	if b, ok := c.scope.vars[ident.Value]; ok {
+		b.fn, b.class = nil, nil
+		if kind != "const" && b.kind == "const" {
+			b.kind = kind
+		}
+		return &binding{}
+	}
+	if c.scope.outer != nil && !strings.HasPrefix(ident.Value, "_") {
+		if outer := c.scope.outer.lookup(ident.Value); outer != nil {
+			c.report(ident.Line, ident.Column, Shadow, "%s shadows the %s declared on line %d", ident.Value, describe(outer), outer.ident.Line)
+		}
+	}
+	b := &binding{ident: ident, kind: kind}
+	c.scope.vars[ident.Value] = b
+	return b
+}
+
+func describe(b *binding) string {
+	switch b.kind {
+	case "param":
+		return "parameter"
+	case "func":
+		return "function"
+	case "class":
+		return "class"
+	case "const":
+		return "constant"
+	}
+	return "variable"
+}
+
+// statements checks a statement list, reporting the first statement
+// after a return or throw. Unreachable code is still checked, so the
+// names it uses do not show up as unused.
+func (c *checker) statements(stmts []ast.Statement) {
+	reachable := true
+	for _, stmt := range stmts {
+		if !reachable {
+			c.report(stmt.StartLine(), 0, Unreachable, "unreachable code")
+			reachable = true
+		}
+		c.statement(stmt)
+		switch stmt.(type) {
+		case *ast.ReturnStatement, *ast.ThrowStatement:
+			reachable = false
+		}
+	}
+}
+
+func (c *checker) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		c.expression(stmt.Value)
+	case *ast.ConstStatement:
+		c.expression(stmt.Value)
+	case *ast.PrintStatement:
+		c.expression(stmt.Value)
+	case *ast.ExpressionStatement:
+		c.expression(stmt.Expression)
+	case *ast.ReturnStatement:
+		if stmt.Value != nil {
+			c.expression(stmt.Value)
+		}
+	case *ast.ThrowStatement:
+		c.expression(stmt.Value)
+	case *ast.BlockStatement:
+		c.statements(stmt.Statements)
+	case *ast.IfStatement:
+		c.expression(stmt.Condition)
+		c.statements(stmt.Consequence.Statements)
+		if stmt.Alternative != nil {
+			c.statements(stmt.Alternative.Statements)
+		}
+	case *ast.WhileStatement:
+		c.expression(stmt.Condition)
+		c.statements(stmt.Body.Statements)
+	case *ast.ForStatement:
+		if stmt.Init != nil {
+			c.statement(stmt.Init)
+		}
+		if stmt.Condition != nil {
+			c.expression(stmt.Condition)
+		}
+		if stmt.Update != nil {
+			c.statement(stmt.Update)
+		}
+		c.statements(stmt.Body.Statements)
+	case *ast.ClassStatement:
+		for _, method := range stmt.Methods {
+			c.function(method, true)
+		}
+	case *ast.TryStatement:
+		c.statements(stmt.Block.Statements)
+		c.statements(stmt.Handler.Statements)
+	}
+}
+
+func (c *checker) expression(expr ast.Expression) {
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		c.use(expr)
+	case *ast.PrefixExpression:
+		c.expression(expr.Right)
+	case *ast.InfixExpression:
+		c.expression(expr.Left)
+		c.expression(expr.Right)
+	case *ast.ArrayLiteral:
+		for _, el := range expr.Elements {
+			c.expression(el)
+		}
+	case *ast.MapLiteral:
+		for i, key := range expr.Keys {
+			c.expression(key)
+			c.expression(expr.Values[i])
+		}
+	case *ast.IndexExpression:
+		c.expression(expr.Left)
+		c.expression(expr.Index)
+	case *ast.MemberExpression:
+		c.expression(expr.Object)
+	case *ast.AssignExpression:
+		c.expression(expr.Value)
+		if ident, ok := expr.Target.(*ast.Identifier); ok {
+			c.assign(ident)
+		} else {
+			c.expression(expr.Target)
+		}
+	case *ast.CallExpression:
+		c.callExpression(expr)
+	case *ast.FunctionLiteral:
+		c.function(expr, false)
+	}
+}
+
+// use records a read of ident
+func (c *checker) use(ident *ast.Identifier) *binding {
+	if ident.Value == "this" && c.inMethod() {
+		return nil
+	}
+	if b := c.scope.lookup(ident.Value); b != nil {
+		b.used = true
+		return b
+	}
+	if _, ok := eval.LookupBuiltin(ident.Value); !ok {
+		c.report(ident.Line, ident.Column, Undefined, "undefined: %s", ident.Value)
+	}
+	return nil
+}
+
+func (c *checker) inMethod() bool {
+	for s := c.scope; s != nil; s = s.outer {
+		if s.method {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *checker) assign(ident *ast.Identifier) {
+	b := c.scope.lookup(ident.Value)
+	if b == nil {
+		c.report(ident.Line, ident.Column, Undefined, "undefined: %s", ident.Value)
+		return
+	}
+	if b.kind == "const" {
+		c.report(ident.Line, ident.Column, ConstAssign, "cannot assign to constant %s", ident.Value)
+	}
+	b.assigned = true
+}
+
+func (c *checker) callExpression(expr *ast.CallExpression) {
+	switch fn := expr.Function.(type) {
+	case *ast.Identifier:
+		if b := c.use(fn); b != nil {
+			c.calls = append(c.calls, call{expr: expr, ident: fn, binding: b})
+		} else if builtin, ok := eval.LookupBuiltin(fn.Value); ok {
+			c.builtinCall(expr, fn, builtin)
+		}
+	case *ast.MemberExpression:
+		c.expression(fn)
+		// Module functions such as json.parse
+		if module, ok := fn.Object.(*ast.Identifier); ok && c.scope.lookup(module.Value) == nil {
+			if m, ok := eval.LookupBuiltin(module.Value); ok {
+				if m, ok := m.(*object.Module); ok {
+					c.builtinCall(expr, fn.Property, m.Members[fn.Property.Value])
+				}
+			}
+		}
+	default:
+		c.expression(fn)
+	}
+	for _, arg := range expr.Arguments {
+		c.expression(arg)
+	}
+}
+
+func (c *checker) builtinCall(expr *ast.CallExpression, ident *ast.Identifier, obj object.Object) {
+	builtin, ok := obj.(*object.Builtin)
+	if !ok {
+		return
+	}
+	got := len(expr.Arguments)
+	if got < builtin.MinArgs || (builtin.MaxArgs != eval.Variadic && got > builtin.MaxArgs) {
+		var want string
+		switch {
+		case builtin.MaxArgs == eval.Variadic:
+			want = fmt.Sprintf("at least %d", builtin.MinArgs)
+		case builtin.MinArgs == builtin.MaxArgs:
+			want = fmt.Sprintf("%d", builtin.MinArgs)
+		default:
+			want = fmt.Sprintf("%d to %d", builtin.MinArgs, builtin.MaxArgs)
+		}
+		c.report(ident.Line, ident.Column, ArgCount, "%s takes %s %s, got %d", builtin.Name, want, plural(builtin.MinArgs, builtin.MaxArgs), got)
+	}
+}
+
+// checkCalls compares the calls of functions and classes with their
+// parameters, for names never reassigned
+func (c *checker) checkCalls() {
+	for _, call := range c.calls {
+		b := call.binding
+		if b.assigned {
+			continue
+		}
+		fn := b.fn
+		name := call.ident.Value
+		if b.class != nil {
+			fn = nil
+			for _, method := range b.class.Methods {
+				if method.Name == "init" {
+					fn = method
+				}
+			}
+			if fn == nil {
+				continue
+			}
+		}
+		if fn == nil {
+			continue
+		}
+		want, got := len(fn.Parameters), len(call.expr.Arguments)
+		if want != got {
+			c.report(call.ident.Line, call.ident.Column, ArgCount, "%s takes %d %s, got %d", name, want, plural(want, want), got)
+		}
+	}
+}
+
+func plural(min, max int) string {
+	if min == 1 && max == 1 {
+		return "argument"
+	}
+	return "arguments"
+}
+
+// function checks fn in a scope of its own and reports what it left
+// unused
+func (c *checker) function(fn *ast.FunctionLiteral, method bool) {
+	outer := c.scope
+	c.scope = &scope{vars: map[string]*binding{}, method: method, outer: outer}
+	var params []*binding
+	for _, param := range fn.Parameters {
+		params = append(params, c.add(param, "param"))
+	}
+	for _, stmt := range fn.Body.Statements {
+		c.declare(stmt)
+	}
+	c.statements(fn.Body.Statements)
+
+	for _, b := range params {
+		if b.ident != nil && !b.used && !strings.HasPrefix(b.ident.Value, "_") {
+			c.report(b.ident.Line, b.ident.Column, UnusedParameter, "parameter %s is not used", b.ident.Value)
+		}
+	}
+	for _, b := range c.scope.vars {
+		if b.kind != "param" && !b.used && !strings.HasPrefix(b.ident.Value, "_") {
+			c.report(b.ident.Line, b.ident.Column, UnusedVariable, "%s %s is declared but not used", describe(b), b.ident.Value)
+		}
+	}
+	c.scope = outer
+}
@@ -0,0 +1,122 @@
+package lint_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"tiger/go/lint"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			name: "clean",
+			src:  "func add(a, b) {\n    return a + b\n}\nprint add(1, 2)\n",
+		},
+		{
+			name: "undefined",
+			src:  "print y\n",
+			want: []string{"1:7: undefined: y (undefined)"},
+		},
+		{
+			name: "unused variable",
+			src:  "func f() {\n    let x = 1\n}\nf()\n",
+			want: []string{"2:9: variable x is declared but not used (unused-variable)"},
+		},
+		{
+			name: "unused parameter",
+			src:  "func f(a) {\n    return 1\n}\nf(1)\n",
+			want: []string{"1:8: parameter a is not used (unused-parameter)"},
+		},
+		{
+			name: "unreachable",
+			src:  "func f() {\n    return 1\n    print 2\n}\nf()\n",
+			want: []string{"3: unreachable code (unreachable)"},
+		},
+		{
+			name: "const assign",
+			src:  "const c = 1\nc = 2\n",
+			want: []string{"2:1: cannot assign to constant c (const-assign)"},
+		},
+		{
+			name: "arg count",
+			src:  "func f(a) {\n    return a\n}\nf(1, 2)\n",
+			want: []string{"4:1: f takes 1 argument, got 2 (arg-count)"},
+		},
+		{
+			name: "shadow",
+			src:  "let x = 1\nfunc f() {\n    let x = 2\n    return x\n}\nprint f() + x\n",
+			want: []string{"3:9: x shadows the variable declared on line 1 (shadow)"},
+		},
+		{
+			name: "ignored on the line",
+			src:  "print y // lint:ignore undefined\n",
+		},
+		{
+			name: "ignored on the line before",
+			src:  "// lint:ignore\nprint y\n",
+		},
+		{
+			name: "other rules are not ignored",
+			src:  "print y // lint:ignore shadow\n",
+			want: []string{"1:7: undefined: y (undefined)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diagnostics, err := lint.Source([]byte(tt.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, d := range diagnostics {
+				got = append(got, d.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diagnostics:\n%q\nwant:\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	diagnostics, err := lint.Source([]byte("print y\nfunc f() {\n    return 1\n    print 2\n}\nf()\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range diagnostics {
+		diagnostics[i].File = "a.tg"
+	}
+	var out strings.Builder
+	if err := lint.WriteJSON(&out, diagnostics); err != nil {
+		t.Fatal(err)
+	}
+	want := `[
+  {
+    "file": "a.tg",
+    "line": 1,
+    "column": 7,
+    "rule": "undefined",
+    "message": "undefined: y"
+  },
+  {
+    "file": "a.tg",
+    "line": 4,
+    "rule": "unreachable",
+    "message": "unreachable code"
+  }
+]
+`
+	if out.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	if err := lint.WriteJSON(&out, nil); err != nil || out.String() != "[]\n" {
+		t.Errorf("no diagnostics wrote %q, %v", out.String(), err)
+	}
+}
@@ -0,0 +1,416 @@
+package lsp
+
+import (
+	"strings"
+	"tiger/go/ast"
+	"tiger/go/lexer"
+)
+
+// symbol is a declared name: a variable, constant, parameter, catch
+// binding, function, class or method
+type symbol struct {
+	name   string
+	kind   string // "let", "const", "param", "catch", "func", "class" or "method"
+	line   int
+	column int
+	doc    string
+
+	fn    *ast.FunctionLiteral // functions, methods and functions bound by let
+	class *ast.ClassStatement  // classes, and the class of a method
+	typ   *ast.TypeAnnotation  // declared type of a variable or parameter
+	end   int                  // last line of a function or class
+	inner []*symbol            // functions and methods declared inside
+	refs  []occurrence         // every occurrence, the declaration first
+}
+
+// occurrence is a name at a source position
+type occurrence struct {
+	line, column int
+	sym          *symbol
+}
+
+// scope is a function body, or the program for globals. Start and end
+// are the lines it covers.
+type scope struct {
+	vars       map[string]*symbol
+	outer      *scope
+	class      *ast.ClassStatement
+	start, end int
+}
+
+func (s *scope) lookup(name string) *symbol {
+	for ; s != nil; s = s.outer {
+		if sym, ok := s.vars[name]; ok {
+			return sym
+		}
+	}
+	return nil
+}
+
+// index records where every name of a document is declared and used
+type index struct {
+	lines       []string
+	comments    map[int]lexer.Comment // leading comments by their last line
+	symbols     []*symbol             // top-level functions and classes
+	occurrences []occurrence
+	scopes      []*scope
+	methods     map[string][]*symbol
+
+	scope *scope
+	owner *symbol
+}
+
+// newIndex indexes a program parsed from src without errors
+func newIndex(src string, program *ast.Program, comments []lexer.Comment) *index {
+	ix := &index{
+		lines:    strings.Split(src, "\n"),
+		comments: map[int]lexer.Comment{},
+		methods:  map[string][]*symbol{},
+	}
+	for _, c := range comments {
+		if text := ix.lines[c.Line-1]; c.Column <= len(text) && strings.TrimSpace(text[:c.Column-1]) == "" {
+			ix.comments[c.Line+strings.Count(c.Text, "\n")] = c
+		}
+	}
+
+	ix.scope = &scope{vars: map[string]*symbol{}, start: 1, end: len(ix.lines)}
+	ix.scopes = append(ix.scopes, ix.scope)
+	ix.declareAll(program.Statements)
+	ix.statements(program.Statements)
+	return ix
+}
+
+// doc returns the comments written on the lines right above line
+func (ix *index) doc(line int) string {
+	var parts []string
+	for {
+		c, ok := ix.comments[line-1]
+		if !ok {
+			break
+		}
+		parts = append([]string{commentText(c.Text)}, parts...)
+		line = c.Line
+	}
+	return strings.Join(parts, "\n")
+}
+
+// commentText strips the comment markers from a comment
+func commentText(text string) string {
+	if strings.HasPrefix(text, "//") {
+		return strings.TrimSpace(strings.TrimPrefix(text, "//"))
+	}
+	text = strings.TrimSuffix(strings.TrimPrefix(text, "/*"), "*/")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		lines[i] = strings.TrimSpace(strings.TrimPrefix(line, "*"))
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// method makes the symbol of a method. The parser keeps no position
+// for method names, so the name is found after func on its line.
+func (ix *index) method(class *ast.ClassStatement, fn *ast.FunctionLiteral) *symbol {
+	column := 0
+	if fn.Line <= len(ix.lines) {
+		text := ix.lines[fn.Line-1]
+		if at := strings.Index(text, "func"); at >= 0 {
+			if name := strings.Index(text[at:], fn.Name); name >= 0 {
+				column = at + name + 1
+			}
+		}
+	}
+	sym := &symbol{
+		name:   fn.Name,
+		kind:   "method",
+		line:   fn.Line,
+		column: column,
+		doc:    ix.doc(fn.Line),
+		fn:     fn,
+		class:  class,
+		end:    fn.Body.EndLine,
+	}
+	sym.refs = append(sym.refs, occurrence{sym.line, sym.column, sym})
+	ix.occurrences = append(ix.occurrences, sym.refs[0])
+	return sym
+}
+
+func (ix *index) declareAll(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		ix.declare(stmt)
+	}
+}
+
+// declare adds the names stmt declares to the current scope, without
+// entering nested functions
+func (ix *index) declare(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		sym := ix.add(stmt.Name, "let")
+		if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok && sym != nil {
+			sym.fn = fn
+			if fn.Name == stmt.Name.Value {
+				sym.kind = "func"
+				sym.end = fn.Body.EndLine
+				ix.nest(sym)
+			}
+		}
+	case *ast.ConstStatement:
+		sym := ix.add(stmt.Name, "const")
+		if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok && sym != nil {
+			sym.fn = fn
+		}
+	case *ast.ClassStatement:
+		// Methods are indexed with their class, before the bodies that
+		// may call them through this
+		var methods []*symbol
+		for _, method := range stmt.Methods {
+			m := ix.method(stmt, method)
+			ix.methods[method.Name] = append(ix.methods[method.Name], m)
+			methods = append(methods, m)
+		}
+		if sym := ix.add(stmt.Name, "class"); sym != nil {
+			sym.class = stmt
+			sym.end = stmt.EndLine
+			sym.inner = methods
+			ix.nest(sym)
+		}
+	case *ast.BlockStatement:
+		ix.declareAll(stmt.Statements)
+	case *ast.IfStatement:
+		ix.declare(stmt.Consequence)
+		if stmt.Alternative != nil {
+			ix.declare(stmt.Alternative)
+		}
+	case *ast.WhileStatement:
+		ix.declare(stmt.Body)
+	case *ast.ForStatement:
+		if stmt.Init != nil {
+			ix.declare(stmt.Init)
+		}
+		ix.declare(stmt.Body)
+	case *ast.TryStatement:
+		ix.declare(stmt.Block)
+		if stmt.Param != nil {
+			ix.add(stmt.Param, "catch")
+		}
+		ix.declare(stmt.Handler)
+	}
+}
+
+// nest lists a function or class under the function declaring it
+func (ix *index) nest(sym *symbol) {
+	if ix.owner == nil {
+		ix.symbols = append(ix.symbols, sym)
+	} else {
+		ix.owner.inner = append(ix.owner.inner, sym)
+	}
+}
+
+// add declares ident in the current scope. Declaring a name twice in a
+// scope refers back to the first declaration and returns nil.
+func (ix *index) add(ident *ast.Identifier, kind string) *symbol {
+	if sym, ok := ix.scope.vars[ident.Value]; ok {
+		ix.use(ident, sym)
+		return nil
+	}
+	sym := &symbol{
+		name:   ident.Value,
+		kind:   kind,
+		line:   ident.Line,
+		column: ident.Column,
+		doc:    ix.doc(ident.Line),
+		typ:    ident.Type,
+	}
+	ix.scope.vars[ident.Value] = sym
+	ix.use(ident, sym)
+	return sym
+}
+
+// use records an occurrence of sym
+func (ix *index) use(ident *ast.Identifier, sym *symbol) {
+	occ := occurrence{ident.Line, ident.Column, sym}
+	sym.refs = append(sym.refs, occ)
+	ix.occurrences = append(ix.occurrences, occ)
+}
+
+func (ix *index) statements(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		ix.statement(stmt)
+	}
+}
+
+func (ix *index) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok && fn.Name == stmt.Name.Value {
+			ix.function(fn, nil, ix.scope.lookup(fn.Name))
+			return
+		}
+		ix.expression(stmt.Value)
+	case *ast.ConstStatement:
+		ix.expression(stmt.Value)
+	case *ast.PrintStatement:
+		ix.expression(stmt.Value)
+	case *ast.ExpressionStatement:
+		ix.expression(stmt.Expression)
+	case *ast.ReturnStatement:
+		if stmt.Value != nil {
+			ix.expression(stmt.Value)
+		}
+	case *ast.ThrowStatement:
+		ix.expression(stmt.Value)
+	case *ast.BlockStatement:
+		ix.statements(stmt.Statements)
+	case *ast.IfStatement:
+		ix.expression(stmt.Condition)
+		ix.statements(stmt.Consequence.Statements)
+		if stmt.Alternative != nil {
+			ix.statements(stmt.Alternative.Statements)
+		}
+	case *ast.WhileStatement:
+		ix.expression(stmt.Condition)
+		ix.statements(stmt.Body.Statements)
+	case *ast.ForStatement:
+		if stmt.Init != nil {
+			ix.statement(stmt.Init)
+		}
+		if stmt.Condition != nil {
+			ix.expression(stmt.Condition)
+		}
+		if stmt.Update != nil {
+			ix.statement(stmt.Update)
+		}
+		ix.statements(stmt.Body.Statements)
+	case *ast.ClassStatement:
+		for _, method := range stmt.Methods {
+			for _, m := range ix.methods[method.Name] {
+				if m.fn == method {
+					ix.function(method, stmt, m)
+				}
+			}
+		}
+	case *ast.TryStatement:
+		ix.statements(stmt.Block.Statements)
+		ix.statements(stmt.Handler.Statements)
+	}
+}
+
+func (ix *index) expression(expr ast.Expression) {
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		if sym := ix.scope.lookup(expr.Value); sym != nil {
+			ix.use(expr, sym)
+		}
+	case *ast.PrefixExpression:
+		ix.expression(expr.Right)
+	case *ast.InfixExpression:
+		ix.expression(expr.Left)
+		ix.expression(expr.Right)
+	case *ast.ArrayLiteral:
+		for _, el := range expr.Elements {
+			ix.expression(el)
+		}
+	case *ast.MapLiteral:
+		for i, key := range expr.Keys {
+			ix.expression(key)
+			ix.expression(expr.Values[i])
+		}
+	case *ast.IndexExpression:
+		ix.expression(expr.Left)
+		ix.expression(expr.Index)
+	case *ast.MemberExpression:
+		ix.expression(expr.Object)
+		if sym := ix.member(expr); sym != nil {
+			ix.use(expr.Property, sym)
+		}
+	case *ast.AssignExpression:
+		ix.expression(expr.Value)
+		ix.expression(expr.Target)
+	case *ast.CallExpression:
+		ix.expression(expr.Function)
+		for _, arg := range expr.Arguments {
+			ix.expression(arg)
+		}
+	case *ast.FunctionLiteral:
+		ix.function(expr, nil, nil)
+	}
+}
+
+// member finds the method a member expression names: one of the
+// enclosing class for this.name, otherwise the only method so called
+func (ix *index) member(expr *ast.MemberExpression) *symbol {
+	candidates := ix.methods[expr.Property.Value]
+	if this, ok := expr.Object.(*ast.Identifier); ok && this.Value == "this" {
+		for s := ix.scope; s != nil; s = s.outer {
+			if s.class == nil {
+				continue
+			}
+			for _, m := range candidates {
+				if m.class == s.class {
+					return m
+				}
+			}
+			return nil
+		}
+	}
+	if len(candidates) == 1 {
+		return candidates[0]
+	}
+	return nil
+}
+
+// function indexes fn in a scope of its own. Class is set for methods;
+// sym is the function's symbol when it has one.
+func (ix *index) function(fn *ast.FunctionLiteral, class *ast.ClassStatement, sym *symbol) {
+	outer, owner := ix.scope, ix.owner
+	ix.scope = &scope{
+		vars:  map[string]*symbol{},
+		outer: outer,
+		class: class,
+		start: fn.Line,
+		end:   fn.Body.EndLine,
+	}
+	ix.scopes = append(ix.scopes, ix.scope)
+	if sym != nil && sym.fn == fn {
+		ix.owner = sym
+	}
+	for _, param := range fn.Parameters {
+		ix.add(param, "param")
+	}
+	ix.declareAll(fn.Body.Statements)
+	ix.statements(fn.Body.Statements)
+	ix.scope, ix.owner = outer, owner
+}
+
+// at returns the occurrence of a name covering a 1-based line and byte
+// column
+func (ix *index) at(line, column int) *occurrence {
+	for i, occ := range ix.occurrences {
+		if occ.line == line && occ.column <= column && column <= occ.column+len(occ.sym.name) {
+			return &ix.occurrences[i]
+		}
+	}
+	return nil
+}
+
+// visible lists the names in scope at a line, innermost first
+func (ix *index) visible(line int) []*symbol {
+	var inner *scope
+	for _, s := range ix.scopes {
+		if s.start <= line && line <= s.end && (inner == nil || s.end-s.start <= inner.end-inner.start) {
+			inner = s
+		}
+	}
+	seen := map[string]bool{}
+	var names []*symbol
+	for s := inner; s != nil; s = s.outer {
+		for name, sym := range s.vars {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, sym)
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,438 @@
+// Package lsp is a Language Server Protocol server for Tiger, used by
+// editors through `tiger lsp`. It publishes syntax errors and answers
+// go-to-definition, references, hover, document symbol and completion
+// requests from the syntax tree of each open document.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"tiger/go/ast"
+	"tiger/go/eval"
+	"tiger/go/lexer"
+	"tiger/go/object"
+	"tiger/go/parser"
+	"tiger/go/token"
+	"tiger/go/wire"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// document is an open file. Index comes from the last version that
+// parsed, so navigation keeps working while an edit is incomplete.
+type document struct {
+	lines []string
+	index *index
+}
+
+type server struct {
+	in   *bufio.Reader
+	out  io.Writer
+	docs map[string]*document
+}
+
+// Serve answers the requests read from r, writing responses and
+// notifications to w, until the client sends exit or closes r
+func Serve(r io.Reader, w io.Writer) error {
+	s := &server{in: bufio.NewReader(r), out: w, docs: map[string]*document{}}
+	for {
+		body, err := wire.Read(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			s.fail(nil, parseError, err.Error())
+			continue
+		}
+		if req.Method == "exit" {
+			return nil
+		}
+		if err := s.handle(&req); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *server) write(v interface{}) error {
+	return wire.Write(s.out, v)
+}
+
+func (s *server) reply(id *json.RawMessage, result interface{}) error {
+	return s.write(response{JSONRPC: "2.0", ID: id, Result: result})
+}
+
+func (s *server) fail(id *json.RawMessage, code int, message string) error {
+	return s.write(errorResponse{JSONRPC: "2.0", ID: id, Error: &responseError{Code: code, Message: message}})
+}
+
+func (s *server) notify(method string, params interface{}) error {
+	return s.write(notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// handle answers one request or acts on one notification
+func (s *server) handle(req *request) error {
+	var result interface{}
+	var err error
+	switch req.Method {
+	case "initialize":
+		result = map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":       map[string]interface{}{"openClose": true, "change": 1},
+				"definitionProvider":     true,
+				"referencesProvider":     true,
+				"hoverProvider":          true,
+				"documentSymbolProvider": true,
+				"completionProvider":     map[string]interface{}{},
+			},
+			"serverInfo": map[string]string{"name": "tiger"},
+		}
+	case "shutdown":
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			return s.update(params.TextDocument.URI, params.TextDocument.Text)
+		}
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err = json.Unmarshal(req.Params, &params); err == nil && len(params.ContentChanges) > 0 {
+			// Changes are whole documents, as announced in initialize
+			return s.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+		}
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			delete(s.docs, params.TextDocument.URI)
+			return s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+		}
+	case "textDocument/definition":
+		var params TextDocumentPositionParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			result = s.definition(params)
+		}
+	case "textDocument/references":
+		var params ReferenceParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			result = s.references(params)
+		}
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			result = s.hover(params)
+		}
+	case "textDocument/documentSymbol":
+		var params DocumentSymbolParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			result = s.documentSymbols(params.TextDocument.URI)
+		}
+	case "textDocument/completion":
+		var params TextDocumentPositionParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			result = s.completion(params)
+		}
+	default:
+		if req.ID == nil {
+			// Notifications the server does not use, such as initialized
+			return nil
+		}
+		return s.fail(req.ID, methodNotFound, "method not supported: "+req.Method)
+	}
+
+	if req.ID == nil {
+		return nil
+	}
+	if err != nil {
+		return s.fail(req.ID, invalidParams, err.Error())
+	}
+	return s.reply(req.ID, result)
+}
+
+// update stores a new version of a document and publishes its syntax
+// errors
+func (s *server) update(uri, text string) error {
+	doc, ok := s.docs[uri]
+	if !ok {
+		doc = &document{}
+		s.docs[uri] = doc
+	}
+	doc.lines = strings.Split(text, "\n")
+
+	l := lexer.New(text)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	diagnostics := []Diagnostic{}
+	for _, e := range p.ErrorList() {
+		line := min(max(e.Line, 1), len(doc.lines))
+		start := doc.position(line, max(e.Column, 1))
+		end := start
+		end.Character++
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    Range{Start: start, End: end},
+			Severity: SeverityError,
+			Source:   "tiger",
+			Message:  e.Message,
+		})
+	}
+	if len(diagnostics) == 0 {
+		doc.index = newIndex(text, program, l.Comments())
+	}
+	return s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: uri, Diagnostics: diagnostics})
+}
+
+// position converts a 1-based line and byte column to an LSP position
+func (d *document) position(line, column int) Position {
+	pos := Position{Line: line - 1}
+	if line < 1 || line > len(d.lines) {
+		return pos
+	}
+	text := d.lines[line-1]
+	pos.Character = utf16Len(text[:min(column-1, len(text))])
+	return pos
+}
+
+// column converts an LSP position to a 1-based line and byte column
+func (d *document) column(pos Position) (int, int) {
+	line := pos.Line + 1
+	if line < 1 || line > len(d.lines) {
+		return line, 1
+	}
+	text := d.lines[line-1]
+	units, offset := 0, 0
+	for offset < len(text) && units < pos.Character {
+		r, size := utf8.DecodeRuneInString(text[offset:])
+		units += len(utf16.Encode([]rune{r}))
+		offset += size
+	}
+	return line, offset + 1
+}
+
+func utf16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+// nameRange is the range of a name starting at line and column
+func (d *document) nameRange(line, column int, name string) Range {
+	start := d.position(line, column)
+	end := start
+	end.Character += utf16Len(name)
+	return Range{Start: start, End: end}
+}
+
+// lookup returns the document and the name under a position, if any
+func (s *server) lookup(params TextDocumentPositionParams) (*document, *occurrence) {
+	doc, ok := s.docs[params.TextDocument.URI]
+	if !ok || doc.index == nil {
+		return doc, nil
+	}
+	line, column := doc.column(params.Position)
+	return doc, doc.index.at(line, column)
+}
+
+func (s *server) definition(params TextDocumentPositionParams) interface{} {
+	doc, occ := s.lookup(params)
+	if occ == nil || occ.sym.column == 0 {
+		return nil
+	}
+	return Location{
+		URI:   params.TextDocument.URI,
+		Range: doc.nameRange(occ.sym.line, occ.sym.column, occ.sym.name),
+	}
+}
+
+func (s *server) references(params ReferenceParams) []Location {
+	locations := []Location{}
+	doc, occ := s.lookup(params.TextDocumentPositionParams)
+	if occ == nil {
+		return locations
+	}
+	for i, ref := range occ.sym.refs {
+		if (i == 0 && !params.Context.IncludeDeclaration) || ref.column == 0 {
+			continue
+		}
+		locations = append(locations, Location{
+			URI:   params.TextDocument.URI,
+			Range: doc.nameRange(ref.line, ref.column, occ.sym.name),
+		})
+	}
+	return locations
+}
+
+func (s *server) hover(params TextDocumentPositionParams) interface{} {
+	doc, occ := s.lookup(params)
+	if doc == nil {
+		return nil
+	}
+	if occ == nil {
+		// Builtins have no declaration to point at
+		line, column := doc.column(params.Position)
+		if line < 1 || line > len(doc.lines) {
+			return nil
+		}
+		name := wordAt(doc.lines[line-1], column)
+		if _, ok := eval.LookupBuiltin(name); !ok || name == "" {
+			return nil
+		}
+		return Hover{Contents: MarkupContent{Kind: "markdown", Value: "```tiger\nbuiltin " + name + "\n```"}}
+	}
+
+	value := "```tiger\n" + signature(occ.sym) + "\n```"
+	if occ.sym.doc != "" {
+		value += "\n\n" + occ.sym.doc
+	}
+	r := doc.nameRange(occ.line, occ.column, occ.sym.name)
+	return Hover{Contents: MarkupContent{Kind: "markdown", Value: value}, Range: &r}
+}
+
+// wordAt returns the identifier around a 1-based byte column
+func wordAt(text string, column int) string {
+	isWord := func(c byte) bool {
+		return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+	}
+	start, end := column-1, column-1
+	for start > 0 && isWord(text[start-1]) {
+		start--
+	}
+	for end < len(text) && isWord(text[end]) {
+		end++
+	}
+	return text[start:end]
+}
+
+// signature renders the declaration of a symbol as Tiger source
+func signature(sym *symbol) string {
+	switch sym.kind {
+	case "func":
+		return "func " + sym.name + params(sym.fn)
+	case "method":
+		return "func " + sym.class.Name.Value + "." + sym.name + params(sym.fn)
+	case "class":
+		for _, method := range sym.class.Methods {
+			if method.Name == "init" {
+				return "class " + sym.name + "\nfunc init" + params(method)
+			}
+		}
+		return "class " + sym.name
+	case "param":
+		return "param " + typed(sym)
+	case "catch":
+		return "catch (" + sym.name + ")"
+	}
+	if sym.fn != nil {
+		return sym.kind + " " + sym.name + " = func" + params(sym.fn)
+	}
+	return sym.kind + " " + typed(sym)
+}
+
+func typed(sym *symbol) string {
+	if sym.typ == nil {
+		return sym.name
+	}
+	return sym.name + ": " + sym.typ.Name
+}
+
+// params renders the parameter list and return type of a function
+func params(fn *ast.FunctionLiteral) string {
+	list := make([]string, len(fn.Parameters))
+	for i, param := range fn.Parameters {
+		list[i] = param.Value
+		if param.Type != nil {
+			list[i] += ": " + param.Type.Name
+		}
+	}
+	s := "(" + strings.Join(list, ", ") + ")"
+	if fn.ReturnType != nil {
+		s += ": " + fn.ReturnType.Name
+	}
+	return s
+}
+
+func (s *server) documentSymbols(uri string) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	doc, ok := s.docs[uri]
+	if !ok || doc.index == nil {
+		return symbols
+	}
+	for _, sym := range doc.index.symbols {
+		symbols = append(symbols, doc.documentSymbol(sym))
+	}
+	return symbols
+}
+
+func (d *document) documentSymbol(sym *symbol) DocumentSymbol {
+	kind := SymbolFunction
+	switch sym.kind {
+	case "class":
+		kind = SymbolClass
+	case "method":
+		kind = SymbolMethod
+	}
+	selection := d.nameRange(sym.line, max(sym.column, 1), sym.name)
+	whole := Range{Start: Position{Line: sym.line - 1}, End: d.position(sym.end, math.MaxInt)}
+	ds := DocumentSymbol{
+		Name:           sym.name,
+		Kind:           kind,
+		Range:          whole,
+		SelectionRange: selection,
+	}
+	if sym.fn != nil {
+		ds.Detail = "func" + params(sym.fn)
+	}
+	for _, inner := range sym.inner {
+		ds.Children = append(ds.Children, d.documentSymbol(inner))
+	}
+	return ds
+}
+
+// completion offers the keywords, the names in scope at the position
+// and the builtins
+func (s *server) completion(params TextDocumentPositionParams) []CompletionItem {
+	items := []CompletionItem{}
+	for _, word := range token.Keywords() {
+		items = append(items, CompletionItem{Label: word, Kind: CompletionKeyword})
+	}
+
+	seen := map[string]bool{}
+	if doc, ok := s.docs[params.TextDocument.URI]; ok && doc.index != nil {
+		names := doc.index.visible(params.Position.Line + 1)
+		sort.Slice(names, func(i, j int) bool { return names[i].name < names[j].name })
+		for _, sym := range names {
+			seen[sym.name] = true
+			items = append(items, CompletionItem{Label: sym.name, Kind: completionKind(sym), Detail: signature(sym)})
+		}
+	}
+
+	builtins := eval.BuiltinNames()
+	sort.Strings(builtins)
+	for _, name := range builtins {
+		if seen[name] {
+			continue
+		}
+		kind := CompletionFunction
+		if obj, _ := eval.LookupBuiltin(name); obj != nil {
+			if _, ok := obj.(*object.Module); ok {
+				kind = CompletionModule
+			}
+		}
+		items = append(items, CompletionItem{Label: name, Kind: kind, Detail: "builtin"})
+	}
+	return items
+}
+
+func completionKind(sym *symbol) int {
+	switch {
+	case sym.kind == "class":
+		return CompletionClass
+	case sym.fn != nil:
+		return CompletionFunction
+	case sym.kind == "const":
+		return CompletionConstant
+	}
+	return CompletionVariable
+}
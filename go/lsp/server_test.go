@@ -0,0 +1,185 @@
+package lsp_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"tiger/go/lsp"
+	"tiger/go/wire"
+)
+
+const uri = "file:///a.tg"
+
+const source = `func add(a, b) {
+    return a + b
+}
+let total = add(1, 2)
+print total
+`
+
+// serve sends msgs to a server and returns what it wrote, keyed by
+// request id, with notifications under their method
+func serve(t *testing.T, msgs ...map[string]interface{}) map[string][]string {
+	t.Helper()
+	var in, out bytes.Buffer
+	for _, msg := range msgs {
+		msg["jsonrpc"] = "2.0"
+		if err := wire.Write(&in, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := lsp.Serve(&in, &out); err != nil {
+		t.Fatal(err)
+	}
+	got := map[string][]string{}
+	r := bufio.NewReader(&out)
+	for {
+		body, err := wire.Read(r)
+		if err != nil {
+			break
+		}
+		var msg struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Result json.RawMessage `json:"result"`
+			Error  json.RawMessage `json:"error"`
+			Params json.RawMessage `json:"params"`
+		}
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case msg.Method != "":
+			got[msg.Method] = append(got[msg.Method], string(msg.Params))
+		case msg.Error != nil:
+			got[string(msg.ID)] = append(got[string(msg.ID)], string(msg.Error))
+		default:
+			got[string(msg.ID)] = append(got[string(msg.ID)], string(msg.Result))
+		}
+	}
+	return got
+}
+
+func open(text string) map[string]interface{} {
+	return map[string]interface{}{
+		"method": "textDocument/didOpen",
+		"params": map[string]interface{}{"textDocument": map[string]string{"uri": uri, "text": text}},
+	}
+}
+
+func at(id int, method string, line, character int) map[string]interface{} {
+	return map[string]interface{}{
+		"id":     id,
+		"method": method,
+		"params": map[string]interface{}{
+			"textDocument": map[string]string{"uri": uri},
+			"position":     map[string]int{"line": line, "character": character},
+			"context":      map[string]bool{"includeDeclaration": true},
+		},
+	}
+}
+
+func TestRequests(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		request map[string]interface{}
+		want    string
+	}{
+		{
+			name:    "definition",
+			text:    source,
+			request: at(1, "textDocument/definition", 4, 7),
+			want:    `{"uri":"file:///a.tg","range":{"start":{"line":3,"character":4},"end":{"line":3,"character":9}}}`,
+		},
+		{
+			name:    "definition after a surrogate pair",
+			text:    "let s = \"😀\"; let v = 1\nprint v\n",
+			request: at(1, "textDocument/definition", 1, 6),
+			want:    `{"uri":"file:///a.tg","range":{"start":{"line":0,"character":18},"end":{"line":0,"character":19}}}`,
+		},
+		{
+			name:    "no definition",
+			text:    source,
+			request: at(1, "textDocument/definition", 1, 0),
+			want:    `null`,
+		},
+		{
+			name:    "references",
+			text:    source,
+			request: at(1, "textDocument/references", 0, 5),
+			want: `[{"uri":"file:///a.tg","range":{"start":{"line":0,"character":5},"end":{"line":0,"character":8}}},` +
+				`{"uri":"file:///a.tg","range":{"start":{"line":3,"character":12},"end":{"line":3,"character":15}}}]`,
+		},
+		{
+			name:    "hover",
+			text:    source,
+			request: at(1, "textDocument/hover", 3, 13),
+			want:    "{\"contents\":{\"kind\":\"markdown\",\"value\":\"```tiger\\nfunc add(a, b)\\n```\"},\"range\":{\"start\":{\"line\":3,\"character\":12},\"end\":{\"line\":3,\"character\":15}}}",
+		},
+		{
+			name: "document symbols",
+			text: source,
+			request: map[string]interface{}{
+				"id":     1,
+				"method": "textDocument/documentSymbol",
+				"params": map[string]interface{}{"textDocument": map[string]string{"uri": uri}},
+			},
+			want: `[{"name":"add","detail":"func(a, b)","kind":12,"range":{"start":{"line":0,"character":0},"end":{"line":2,"character":1}},` +
+				`"selectionRange":{"start":{"line":0,"character":5},"end":{"line":0,"character":8}}}]`,
+		},
+		{
+			name:    "unknown method",
+			text:    source,
+			request: map[string]interface{}{"id": 1, "method": "textDocument/rename"},
+			want:    `{"code":-32601,"message":"method not supported: textDocument/rename"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := serve(t, open(tt.text), tt.request, map[string]interface{}{"method": "exit"})
+			if len(got["1"]) != 1 || got["1"][0] != tt.want {
+				t.Errorf("got %s\nwant %s", got["1"], tt.want)
+			}
+		})
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	got := serve(t, open(source), at(1, "textDocument/completion", 4, 8))
+	for _, want := range []string{
+		`{"label":"while","kind":14}`,
+		`{"label":"add","kind":3,"detail":"func add(a, b)"}`,
+		`{"label":"total","kind":6,"detail":"let total"}`,
+	} {
+		if len(got["1"]) != 1 || !strings.Contains(got["1"][0], want) {
+			t.Errorf("completions %s lack %s", got["1"], want)
+		}
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	change := map[string]interface{}{
+		"method": "textDocument/didChange",
+		"params": map[string]interface{}{
+			"textDocument":   map[string]string{"uri": uri},
+			"contentChanges": []map[string]string{{"text": "let = 1\n"}},
+		},
+	}
+	closeDoc := map[string]interface{}{
+		"method": "textDocument/didClose",
+		"params": map[string]interface{}{"textDocument": map[string]string{"uri": uri}},
+	}
+	got := serve(t, open(source), change, closeDoc)["textDocument/publishDiagnostics"]
+	want := []string{
+		`{"uri":"file:///a.tg","diagnostics":[]}`,
+		`{"uri":"file:///a.tg","diagnostics":[{"range":{"start":{"line":0,"character":4},"end":{"line":0,"character":5}},"severity":1,"source":"tiger","message":"expected IDENT, got \"=\""},` +
+			`{"range":{"start":{"line":0,"character":4},"end":{"line":0,"character":5}},"severity":1,"source":"tiger","message":"unexpected \"=\""}]}`,
+		`{"uri":"file:///a.tg","diagnostics":[]}`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("diagnostics:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -3,21 +3,56 @@
 package main
 
 import (
-	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"io/fs"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"tiger/go/ast"
+	"tiger/go/compiler"
+	"tiger/go/cover"
+	"tiger/go/dap"
+	"tiger/go/debugger"
 	"tiger/go/eval"
+	"tiger/go/format"
 	"tiger/go/lexer"
+	"tiger/go/lint"
+	"tiger/go/lsp"
+	"tiger/go/object"
+	"tiger/go/optimizer"
 	"tiger/go/parser"
+	"tiger/go/profile"
+	"tiger/go/repl"
+	"tiger/go/resolver"
+	"tiger/go/testrunner"
+	"tiger/go/tiger"
+	"tiger/go/types"
+	"tiger/go/vm"
+	"time"
 )
 
 func main() {
 	if len(os.Args) < 2 {
 		fmt.Println("Tiger Programming Language CLI")
 		fmt.Println("Usage:")
-		fmt.Println("  tiger run <file.tg>  - Run a Tiger file")
+		fmt.Println("  tiger run [--timeout 5s] [--max-steps N] [--max-depth N] [--max-memory BYTES] [--vm] [-O] [--check] <file.tg>  - Run a Tiger file")
+		fmt.Println("  tiger ast [--optimized] <file.tg>  - Print the syntax tree of a Tiger file")
+		fmt.Println("  tiger fmt [-w] [-d] [path ...]  - Format Tiger files in the canonical style")
+		fmt.Println("  tiger lint [--json] <path ...>  - Report likely mistakes in Tiger files")
+		fmt.Println("  tiger check <path ...>  - Type-check Tiger files without running them")
+		fmt.Println("  tiger lsp           - Start a language server on stdin and stdout")
+		fmt.Println("  tiger debug <file.tg>  - Run a Tiger file under the debugger")
+		fmt.Println("  tiger dap           - Start a debug adapter on stdin and stdout")
+		fmt.Println("  tiger profile [--sort exclusive|inclusive|calls] [--top N] [--pprof FILE] <file.tg>  - Run a Tiger file and report where its time went")
+		fmt.Println("  tiger test [-v] [--run REGEX] [--timeout 5s] [--tap] [--junit FILE] [path ...]  - Run the test_* functions of *_test.tg files")
+		fmt.Println("  tiger cover [-o FILE] [--html FILE] [--text] <path ...>  - Run Tiger files and report which statements ran")
 		fmt.Println("  tiger repl          - Start interactive REPL")
 		return
 	}
@@ -26,71 +61,569 @@ func main() {
 	
 	switch command {
 	case "run":
-		if len(os.Args) < 3 {
-			fmt.Println("Error: Please specify a file to run")
-			fmt.Println("Usage: tiger run <file.tg>")
-			return
+		runCommand(os.Args[2:])
+	case "ast":
+		astCommand(os.Args[2:])
+	case "fmt":
+		fmtCommand(os.Args[2:])
+	case "lint":
+		lintCommand(os.Args[2:])
+	case "check":
+		checkCommand(os.Args[2:])
+	case "debug":
+		debugCommand(os.Args[2:])
+	case "profile":
+		profileCommand(os.Args[2:])
+	case "test":
+		testCommand(os.Args[2:])
+	case "cover":
+		coverCommand(os.Args[2:])
+	case "dap":
+		if err := dap.Serve(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "dap:", err)
+			os.Exit(1)
+		}
+	case "lsp":
+		if err := lsp.Serve(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "lsp:", err)
+			os.Exit(1)
 		}
-		runFile(os.Args[2])
 	case "repl":
 		runRepl()
 	default:
 		fmt.Printf("Unknown command: %s\n", command)
-		fmt.Println("Available commands: run, repl")
+		fmt.Println("Available commands: run, ast, fmt, lint, check, lsp, debug, dap, profile, test, cover, repl")
 	}
 }
 
-func runFile(filename string) {
-	content, err := ioutil.ReadFile(filename)
-	if err != nil {
-		fmt.Printf("Error reading file %s: %v\n", filename, err)
+func runCommand(args []string) {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	timeout := flags.Duration("timeout", 0, "stop the program after this long, e.g. 5s")
+	maxSteps := flags.Int64("max-steps", 0, "stop the program after this many loop iterations and calls")
+	maxDepth := flags.Int("max-depth", 0, "raise StackOverflow past this call depth (default 10000)")
+	maxMemory := flags.Int64("max-memory", 0, "stop the program once its values take more than this many bytes")
+	useVM := flags.Bool("vm", false, "compile to bytecode and run it on the virtual machine")
+	optimize := flags.Bool("O", false, "fold constants and remove dead code before running")
+	check := flags.Bool("check", false, "type-check the file and refuse to run it on errors")
+	flags.Parse(args)
+
+	if flags.NArg() < 1 {
+		fmt.Println("Error: Please specify a file to run")
+		fmt.Println("Usage: tiger run [--timeout 5s] [--max-steps N] [--max-depth N] [--max-memory BYTES] [--vm] [-O] [--check] <file.tg>")
 		return
 	}
 
-	code := string(content)
-	result := executeCode(code)
-	fmt.Print(result)
+	if *useVM {
+		if *maxMemory > 0 {
+			fmt.Fprintln(os.Stderr, "Error: --max-memory is not supported with --vm")
+			os.Exit(2)
+		}
+		runVM(flags.Arg(0), *timeout, *maxSteps, *maxDepth, *optimize, *check)
+		return
+	}
+
+	interp := tiger.New()
+	interp.Stderr = os.Stderr
+	interp.Timeout = *timeout
+	interp.MaxSteps = *maxSteps
+	interp.MaxDepth = *maxDepth
+	interp.MaxMemory = *maxMemory
+	interp.Optimize = *optimize
+	interp.Check = *check
+	runFile(interp, flags.Arg(0))
 }
 
-func runRepl() {
-	fmt.Println("🐯 Tiger Language REPL")
-	fmt.Println("Type 'exit' to quit")
+func runFile(interp *tiger.Interpreter, filename string) {
+	if _, err := interp.RunFile(filename); err != nil {
+		os.Exit(1)
+	}
+}
+
+// runVM compiles filename to bytecode and runs it, reporting errors as
+// the interpreter does
+func runVM(filename string, timeout time.Duration, maxSteps int64, maxDepth int, optimize, check bool) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	p := parser.New(lexer.New(string(content)))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		fmt.Fprintln(os.Stderr, &tiger.ParseError{Errors: p.ErrorList()})
+		os.Exit(1)
+	}
+	if check {
+		if errs := types.Check(program); len(errs) > 0 {
+			fmt.Fprintln(os.Stderr, &tiger.TypeError{Errors: errs})
+			os.Exit(1)
+		}
+	}
+	if optimize {
+		optimizer.Optimize(program)
+	}
+	builtin := func(name string) bool {
+		_, ok := eval.LookupBuiltin(name)
+		return ok
+	}
+	if errs := resolver.Resolve(program, builtin); len(errs) > 0 {
+		fmt.Fprintln(os.Stderr, &tiger.ResolveError{Errors: errs})
+		os.Exit(1)
+	}
+	bytecode, err := compiler.New().Compile(program)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "compile error:", err)
+		os.Exit(1)
+	}
+
+	machine := vm.New(bytecode)
+	machine.SetMaxSteps(maxSteps)
+	machine.SetMaxDepth(maxDepth)
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if errObj, ok := machine.RunContext(ctx).(*object.Error); ok {
+		if errObj.Limit {
+			fmt.Fprintln(os.Stderr, &tiger.LimitError{Message: errObj.Message})
+		} else {
+			fmt.Fprintln(os.Stderr, &tiger.RuntimeError{Message: errObj.Message, Kind: errObj.Kind, Stack: errObj.Stack})
+		}
+		os.Exit(1)
+	}
+}
+
+// astCommand prints the syntax tree of a file, after optimization when
+// --optimized is given
+func astCommand(args []string) {
+	flags := flag.NewFlagSet("ast", flag.ExitOnError)
+	optimized := flags.Bool("optimized", false, "show the tree as run -O would run it")
+	flags.Parse(args)
+
+	if flags.NArg() < 1 {
+		fmt.Println("Error: Please specify a file")
+		fmt.Println("Usage: tiger ast [--optimized] <file.tg>")
+		return
+	}
+	content, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	p := parser.New(lexer.New(string(content)))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		fmt.Fprintln(os.Stderr, &tiger.ParseError{Errors: p.ErrorList()})
+		os.Exit(1)
+	}
+	if *optimized {
+		optimizer.Optimize(program)
+	}
+	ast.Fprint(os.Stdout, program)
+}
+
+// fmtCommand formats the given files, and the .tg files under the given
+// directories, printing the result unless -w or -d is set. Without paths
+// it formats standard input.
+func fmtCommand(args []string) {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := flags.Bool("w", false, "write the result back to each file")
+	diff := flags.Bool("d", false, "print diffs instead of the formatted source")
+	flags.Parse(args)
 
-	scanner := bufio.NewScanner(os.Stdin)
-	env := eval.NewEnvironment()
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		if err == nil {
+			err = formatFile("<stdin>", src, false, *diff)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	failed := false
+	err := walkSources(flags.Args(), func(file string, src []byte) {
+		if err := formatFile(file, src, *write, *diff); err != nil {
+			reportFileError(file, err)
+			failed = true
+		}
+	})
+	if err != nil || failed {
+		os.Exit(1)
+	}
+}
 
-	for {
-		fmt.Print(">>> ")
-		if !scanner.Scan() {
-			break
+// walkSources calls fn with each file in paths and each .tg file under
+// the directories among them. Errors reading files are printed, and the
+// last one returned.
+func walkSources(paths []string, fn func(file string, src []byte)) error {
+	var last error
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || (file != path && filepath.Ext(file) != ".tg") {
+				return nil
+			}
+			src, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			fn(file, src)
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			last = err
 		}
+	}
+	return last
+}
+
+// reportFileError prints an error about file, giving each syntax error
+// its own file:line:column line
+func reportFileError(file string, err error) {
+	var syntax parser.ErrorList
+	var parse *tiger.ParseError
+	switch {
+	case errors.As(err, &syntax):
+	case errors.As(err, &parse):
+		syntax = parse.Errors
+	default:
+		fmt.Fprintf(os.Stderr, "%s: %v\n", file, err)
+		return
+	}
+	for _, e := range syntax {
+		fmt.Fprintf(os.Stderr, "%s:%d:%d: %s\n", file, e.Line, e.Column, e.Message)
+	}
+}
+
+func formatFile(name string, src []byte, write, diff bool) error {
+	formatted, err := format.Source(src)
+	if err != nil {
+		return err
+	}
+	if diff {
+		os.Stdout.Write(format.Diff(name, src, formatted))
+	}
+	if write && !bytes.Equal(src, formatted) {
+		return os.WriteFile(name, formatted, 0644)
+	}
+	if !write && !diff {
+		os.Stdout.Write(formatted)
+	}
+	return nil
+}
+
+// lintCommand reports the lint diagnostics of the given files and
+// directories, exiting with status 1 if there are any
+func lintCommand(args []string) {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print diagnostics as a JSON array")
+	flags.Parse(args)
 
-		input := strings.TrimSpace(scanner.Text())
-		if input == "exit" {
-			break
+	if flags.NArg() < 1 {
+		fmt.Println("Error: Please specify files or directories to lint")
+		fmt.Println("Usage: tiger lint [--json] <path ...>")
+		return
+	}
+
+	diagnostics := []lint.Diagnostic{}
+	failed := false
+	err := walkSources(flags.Args(), func(file string, src []byte) {
+		found, err := lint.Source(src)
+		if err != nil {
+			reportFileError(file, err)
+			failed = true
+			return
+		}
+		for _, d := range found {
+			d.File = file
+			diagnostics = append(diagnostics, d)
 		}
+	})
 
-		if input == "" {
-			continue
+	if *asJSON {
+		lint.WriteJSON(os.Stdout, diagnostics)
+	} else {
+		for _, d := range diagnostics {
+			fmt.Println(d)
 		}
+	}
+	if err != nil || failed || len(diagnostics) > 0 {
+		os.Exit(1)
+	}
+}
+
+// checkCommand type-checks the given files and directories, exiting
+// with status 1 if any has errors
+func checkCommand(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Error: Please specify files or directories to check")
+		fmt.Println("Usage: tiger check <path ...>")
+		return
+	}
 
-		l := lexer.New(input)
-		p := parser.New(l)
+	failed := false
+	err := walkSources(args, func(file string, src []byte) {
+		p := parser.New(lexer.New(string(src)))
 		program := p.ParseProgram()
-		result := eval.Eval(program, env)
-		
-		if result != "" {
-			fmt.Println(result)
+		if errs := p.ErrorList(); len(errs) > 0 {
+			for _, e := range errs {
+				fmt.Printf("%s:%d:%d: %s\n", file, e.Line, e.Column, e.Message)
+			}
+			failed = true
+			return
 		}
+		for _, e := range types.Check(program) {
+			fmt.Printf("%s:%d:%d: %s\n", file, e.Line, e.Column, e.Message)
+			failed = true
+		}
+	})
+	if err != nil || failed {
+		os.Exit(1)
+	}
+}
+
+// debugCommand runs a file under the console debugger, stopped before
+// its first statement
+func debugCommand(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Error: Please specify a file to debug")
+		fmt.Println("Usage: tiger debug <file.tg>")
+		return
+	}
+	src, err := os.ReadFile(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 
-	fmt.Println("Goodbye!")
+	d := debugger.New(string(src))
+	d.StopOnEntry = true
+	debugger.NewConsole(d, string(src), os.Stdin, os.Stdout)
+	fmt.Println("Tiger debugger; type help for commands")
+
+	interp := tiger.New()
+	interp.Stderr = os.Stderr
+	interp.Hooks = d.Hooks()
+	if _, err := interp.Run(string(src)); err != nil {
+		os.Exit(1)
+	}
 }
 
-func executeCode(code string) string {
-	l := lexer.New(code)
-	p := parser.New(l)
-	program := p.ParseProgram()
-	env := eval.NewEnvironment()
-	return eval.Eval(program, env)
-}
\ No newline at end of file
+// profileCommand runs a file under the profiler, then prints where its
+// time went and, if asked, writes a pprof profile
+func profileCommand(args []string) {
+	flags := flag.NewFlagSet("profile", flag.ExitOnError)
+	order := flags.String("sort", profile.ByExclusive, "order functions by exclusive or inclusive time, or by calls")
+	top := flags.Int("top", 20, "show at most this many functions and lines; 0 shows all")
+	pprofFile := flags.String("pprof", "", "also write a profile for `go tool pprof` to this file")
+	flags.Parse(args)
+
+	if flags.NArg() < 1 {
+		fmt.Println("Error: Please specify a file to profile")
+		fmt.Println("Usage: tiger profile [--sort exclusive|inclusive|calls] [--top N] [--pprof FILE] <file.tg>")
+		return
+	}
+	switch *order {
+	case profile.ByExclusive, profile.ByInclusive, profile.ByCalls:
+	default:
+		fmt.Fprintf(os.Stderr, "Error: --sort must be exclusive, inclusive or calls, not %q\n", *order)
+		os.Exit(2)
+	}
+	file := flags.Arg(0)
+	src, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	interp := tiger.New()
+	interp.Stderr = os.Stderr
+	prof := profile.New()
+	interp.Hooks = prof.Hooks()
+	_, runErr := interp.Run(string(src))
+	prof.Stop()
+
+	fmt.Println()
+	prof.WriteReport(os.Stdout, string(src), *order, *top)
+	if *pprofFile != "" {
+		err := writeFile(*pprofFile, func(w io.Writer) error {
+			return prof.WritePprof(w, file)
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if runErr != nil {
+		os.Exit(1)
+	}
+}
+
+// testCommand runs the tests found under the given paths, or the current
+// directory, exiting with status 1 if any fails
+func testCommand(args []string) {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	verbose := flags.Bool("v", false, "also list the tests that pass, with their output")
+	run := flags.String("run", "", "run only the tests whose names match this regular expression")
+	timeout := flags.Duration("timeout", 0, "fail a test that runs longer than this, e.g. 5s")
+	tap := flags.Bool("tap", false, "report in the Test Anything Protocol")
+	junit := flags.String("junit", "", "also write a JUnit XML report to this file")
+	flags.Parse(args)
+
+	runner := &testrunner.Runner{Timeout: *timeout}
+	if *run != "" {
+		match, err := regexp.Compile(*run)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error: --run:", err)
+			os.Exit(2)
+		}
+		runner.Match = match
+	}
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := testrunner.Find(paths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if len(files) == 0 {
+		fmt.Println("no test files")
+		return
+	}
+
+	var results []*testrunner.Result
+	failed := false
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err == nil {
+			var found []*testrunner.Result
+			found, err = runner.RunFile(file, string(src))
+			results = append(results, found...)
+		}
+		if err != nil {
+			reportFileError(file, err)
+			failed = true
+		}
+	}
+
+	if *tap {
+		testrunner.WriteTAP(os.Stdout, results)
+	} else {
+		testrunner.WriteText(os.Stdout, results, *verbose)
+	}
+	if *junit != "" {
+		err := writeFile(*junit, func(w io.Writer) error {
+			return testrunner.WriteJUnit(w, results)
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if failed || testrunner.Failed(results) > 0 {
+		os.Exit(1)
+	}
+}
+
+// coverCommand runs the given files, and those in the given
+// directories, counting the statements that run, then writes a coverage
+// profile and prints a summary for each file. Test files have their
+// tests run; other files run as scripts.
+func coverCommand(args []string) {
+	flags := flag.NewFlagSet("cover", flag.ExitOnError)
+	out := flags.String("o", "cover.out", "write the coverage profile to this file")
+	htmlFile := flags.String("html", "", "also write an HTML report to this file")
+	text := flags.Bool("text", false, "print each file's source with the runs of every line")
+	flags.Parse(args)
+
+	if flags.NArg() < 1 {
+		fmt.Println("Error: Please specify files or directories to cover")
+		fmt.Println("Usage: tiger cover [-o FILE] [--html FILE] [--text] <path ...>")
+		return
+	}
+
+	profile := cover.New()
+	failed := false
+	err := walkSources(flags.Args(), func(file string, src []byte) {
+		f, err := profile.Add(file, string(src))
+		if err != nil {
+			reportFileError(file, err)
+			failed = true
+			return
+		}
+		if strings.HasSuffix(file, testrunner.FileSuffix) {
+			runner := &testrunner.Runner{Hooks: f.Hooks()}
+			results, err := runner.RunFile(file, string(src))
+			if err != nil {
+				reportFileError(file, err)
+				failed = true
+			} else if testrunner.Failed(results) > 0 {
+				testrunner.WriteText(os.Stdout, results, false)
+				failed = true
+			}
+			return
+		}
+		interp := tiger.New()
+		interp.Stderr = os.Stderr
+		interp.Hooks = f.Hooks()
+		if _, err := interp.Run(string(src)); err != nil {
+			failed = true
+		}
+	})
+
+	if err := writeFile(*out, profile.WriteProfile); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *htmlFile != "" {
+		if err := writeFile(*htmlFile, profile.WriteHTML); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	for _, f := range profile.Files() {
+		if *text {
+			fmt.Println()
+			f.WriteText(os.Stdout)
+		} else {
+			fmt.Println(f.Summary())
+		}
+	}
+	if files := profile.Files(); len(files) > 1 {
+		covered, total := profile.Covered()
+		fmt.Printf("total: %.1f%% of %d statements\n", cover.Percent(covered, total), total)
+	}
+	if err != nil || failed {
+		os.Exit(1)
+	}
+}
+
+// writeFile creates path and fills it with write
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func runRepl() {
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+	repl.New(os.Stdout).Run(os.Stdin, interrupts)
+}
@@ -4,22 +4,34 @@ package main
 
 import (
 	"fmt"
+	"strings"
 	"syscall/js"
-	"tiger/go/eval"
-	"tiger/go/lexer"
-	"tiger/go/parser"
+	"tiger/go/tiger"
+	"time"
 )
 
+// defaultTimeout keeps a runaway loop from freezing the browser tab
+const defaultTimeout = 5 * time.Second
+
+// maxMemory keeps a script from exhausting the tab's memory
+const maxMemory = 64 << 20
+
+// evalTiger(code, timeoutMs) runs code and returns its output. The
+// optional timeout defaults to defaultTimeout.
 func evalTiger(this js.Value, args []js.Value) interface{} {
 	code := args[0].String()
-	l := lexer.New(code)
-	p := parser.New(l)
-	program := p.ParseProgram()
-	env := eval.NewEnvironment()
-	result := eval.Eval(program, env)
+	var output strings.Builder
+	interp := tiger.New()
+	interp.Stdout = &output
+	interp.Stderr = &output
+	interp.Timeout = defaultTimeout
+	interp.MaxMemory = maxMemory
+	if len(args) > 1 && args[1].Type() == js.TypeNumber {
+		interp.Timeout = time.Duration(args[1].Float() * float64(time.Millisecond))
+	}
+	interp.Run(code)
 
-	// result is already a string from eval.Eval
-	return js.ValueOf(result)
+	return js.ValueOf(output.String())
 }
 
 func main() {
@@ -1,9 +1,29 @@
 package object
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 type ObjectType string
 
 const (
-	STRING_OBJ = "STRING"
+	STRING_OBJ   = "STRING"
+	INTEGER_OBJ  = "INTEGER"
+	FLOAT_OBJ    = "FLOAT"
+	BOOLEAN_OBJ  = "BOOLEAN"
+	NULL_OBJ     = "NULL"
+	ARRAY_OBJ    = "ARRAY"
+	MAP_OBJ      = "MAP"
+	FUNCTION_OBJ = "FUNCTION"
+	BUILTIN_OBJ  = "BUILTIN"
+	CLASS_OBJ    = "CLASS"
+	INSTANCE_OBJ = "INSTANCE"
+	MODULE_OBJ   = "MODULE"
+	RETURN_OBJ   = "RETURN"
+	ERROR_OBJ    = "ERROR"
+	GO_OBJ       = "GO_OBJECT"
 )
 
 type Object interface {
@@ -11,9 +31,214 @@ type Object interface {
 	Inspect() string
 }
 
+var (
+	NULL  = &Null{}
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{Value: false}
+)
+
 type String struct {
 	Value string
 }
 
 func (s *String) Type() ObjectType { return STRING_OBJ }
 func (s *String) Inspect() string  { return s.Value }
+
+type Integer struct {
+	Value int64
+}
+
+func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
+func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+func (f *Float) Inspect() string  { return fmt.Sprintf("%.6f", f.Value) }
+
+type Boolean struct {
+	Value bool
+}
+
+func (b *Boolean) Type() ObjectType { return BOOLEAN_OBJ }
+func (b *Boolean) Inspect() string  { return fmt.Sprintf("%t", b.Value) }
+
+// NativeBool returns the shared TRUE or FALSE object
+func NativeBool(value bool) *Boolean {
+	if value {
+		return TRUE
+	}
+	return FALSE
+}
+
+type Null struct{}
+
+func (n *Null) Type() ObjectType { return NULL_OBJ }
+func (n *Null) Inspect() string  { return "null" }
+
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType { return ARRAY_OBJ }
+func (a *Array) Inspect() string {
+	elements := make([]string, len(a.Elements))
+	for i, el := range a.Elements {
+		elements[i] = inspectElement(el)
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// Map is a string-keyed map that remembers insertion order
+type Map struct {
+	Keys  []string
+	Pairs map[string]Object
+}
+
+func NewMap() *Map {
+	return &Map{Pairs: make(map[string]Object)}
+}
+
+func (m *Map) Type() ObjectType { return MAP_OBJ }
+func (m *Map) Inspect() string {
+	pairs := make([]string, len(m.Keys))
+	for i, key := range m.Keys {
+		pairs[i] = strconv.Quote(key) + ": " + inspectElement(m.Pairs[key])
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+func (m *Map) Get(key string) (Object, bool) {
+	val, ok := m.Pairs[key]
+	return val, ok
+}
+
+func (m *Map) Set(key string, val Object) {
+	if _, ok := m.Pairs[key]; !ok {
+		m.Keys = append(m.Keys, key)
+	}
+	m.Pairs[key] = val
+}
+
+type BuiltinFunction func(args ...Object) (Object, error)
+
+// Builtin is a Go function callable from Tiger. MaxArgs is -1 for
+// functions taking any number of arguments from MinArgs up.
+type Builtin struct {
+	Name    string
+	MinArgs int
+	MaxArgs int
+	Fn      BuiltinFunction
+}
+
+func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
+func (b *Builtin) Inspect() string  { return "[builtin " + b.Name + "]" }
+
+// Module groups builtins under a name, e.g. json.parse
+type Module struct {
+	Name    string
+	Members map[string]Object
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Inspect() string  { return "[module " + m.Name + "]" }
+
+type ReturnValue struct {
+	Value Object
+}
+
+func (rv *ReturnValue) Type() ObjectType { return RETURN_OBJ }
+func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
+
+// Error is a raised error. It unwinds evaluation until a try statement
+// catches it; Value holds the thrown value for `throw`. Errors with Limit
+// set come from execution limits and cannot be caught. Kind names the
+// error class, such as "StackOverflow", and Stack lists the innermost
+// call frames when they were recorded.
+type Error struct {
+	Message string
+	Value   Object
+	Limit   bool
+	Kind    string
+	Stack   []string
+}
+
+func (e *Error) Type() ObjectType { return ERROR_OBJ }
+func (e *Error) Inspect() string  { return "[" + e.Message + "]" }
+
+// MemberGetter is implemented by host values, such as bridged Go
+// structs, that expose fields and methods to obj.name lookups
+type MemberGetter interface {
+	GetMember(name string) (Object, bool)
+}
+
+// MemberSetter is implemented by host values accepting obj.name = value
+type MemberSetter interface {
+	SetMember(name string, val Object) error
+}
+
+// inspectElement renders values nested inside arrays and maps, where
+// strings are quoted so that ["1"] and [1] can be told apart
+func inspectElement(obj Object) string {
+	if str, ok := obj.(*String); ok {
+		return strconv.Quote(str.Value)
+	}
+	return obj.Inspect()
+}
+
+// Equal reports whether two values are equal. Numbers compare by value
+// across integers and floats; arrays and maps compare element-wise.
+func Equal(a, b Object) bool {
+	switch a := a.(type) {
+	case *Integer:
+		switch b := b.(type) {
+		case *Integer:
+			return a.Value == b.Value
+		case *Float:
+			return float64(a.Value) == b.Value
+		}
+		return false
+	case *Float:
+		switch b := b.(type) {
+		case *Integer:
+			return a.Value == float64(b.Value)
+		case *Float:
+			return a.Value == b.Value
+		}
+		return false
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	case *Null:
+		return b.Type() == NULL_OBJ
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		for i := range a.Elements {
+			if !Equal(a.Elements[i], b.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	case *Map:
+		b, ok := b.(*Map)
+		if !ok || len(a.Keys) != len(b.Keys) {
+			return false
+		}
+		for _, key := range a.Keys {
+			other, ok := b.Pairs[key]
+			if !ok || !Equal(a.Pairs[key], other) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
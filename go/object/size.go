@@ -0,0 +1,38 @@
+package object
+
+// Approximate footprints, in bytes, used to account for memory. They
+// follow the layout of the Go values behind each object on a 64-bit
+// platform without trying to be exact.
+const (
+	// WordSize is a pointer or an int
+	WordSize = 8
+	// ValueSize is an Object interface value held in a slot
+	ValueSize = 2 * WordSize
+	// EntrySize is the overhead of one key in a Map or an environment,
+	// excluding the bytes of the key itself
+	EntrySize = 4 * WordSize
+)
+
+// Size estimates the bytes obj occupies, not counting the objects it
+// holds. Arrays count their slots and maps their keys, so a container
+// that grows is charged for the growth.
+func Size(obj Object) int64 {
+	switch obj := obj.(type) {
+	case *String:
+		return 2*WordSize + int64(len(obj.Value))
+	case *Array:
+		return 3*WordSize + ValueSize*int64(len(obj.Elements))
+	case *Map:
+		size := int64(6 * WordSize)
+		for _, key := range obj.Keys {
+			size += KeySize(key)
+		}
+		return size
+	}
+	return ValueSize
+}
+
+// KeySize is what adding key to a Map or an environment costs
+func KeySize(key string) int64 {
+	return EntrySize + int64(len(key))
+}
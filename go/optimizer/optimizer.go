@@ -0,0 +1,312 @@
+// Package optimizer rewrites a parsed program into an equivalent one that
+// does less work at runtime: constant expressions are folded, constants
+// are replaced by their values and code that can never run is removed.
+package optimizer
+
+import (
+	"tiger/go/ast"
+	"tiger/go/eval"
+	"tiger/go/object"
+)
+
+// scope tracks the constants of a function body, or of the program for
+// globals. Counts holds how often each name is declared in the scope;
+// only names declared once by a const may be propagated.
+type scope struct {
+	counts map[string]int
+	consts map[string]ast.Expression
+	outer  *scope
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{
+		counts: make(map[string]int),
+		consts: make(map[string]ast.Expression),
+		outer:  outer,
+	}
+}
+
+// lookup returns the value of the constant name, if it is known where
+// name is used
+func (s *scope) lookup(name string) (ast.Expression, bool) {
+	for ; s != nil; s = s.outer {
+		if s.counts[name] > 0 {
+			val, ok := s.consts[name]
+			return val, ok
+		}
+	}
+	return nil, false
+}
+
+// count records the names stmt declares in s, without entering nested
+// functions
+func (s *scope) count(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		s.counts[stmt.Name.Value]++
+	case *ast.ConstStatement:
+		s.counts[stmt.Name.Value]++
+	case *ast.ClassStatement:
+		s.counts[stmt.Name.Value]++
+	case *ast.BlockStatement:
+		for _, inner := range stmt.Statements {
+			s.count(inner)
+		}
+	case *ast.IfStatement:
+		s.count(stmt.Consequence)
+		if stmt.Alternative != nil {
+			s.count(stmt.Alternative)
+		}
+	case *ast.WhileStatement:
+		s.count(stmt.Body)
+	case *ast.ForStatement:
+		if stmt.Init != nil {
+			s.count(stmt.Init)
+		}
+		s.count(stmt.Body)
+	case *ast.TryStatement:
+		s.count(stmt.Block)
+		if stmt.Param != nil {
+			s.counts[stmt.Param.Value]++
+		}
+		s.count(stmt.Handler)
+	}
+}
+
+type optimizer struct {
+	scope *scope
+}
+
+// Optimize rewrites program in place. It runs before the resolver, which
+// must see the optimized tree.
+func Optimize(program *ast.Program) {
+	o := &optimizer{scope: newScope(nil)}
+	for _, stmt := range program.Statements {
+		o.scope.count(stmt)
+	}
+	program.Statements = o.statements(program.Statements, true)
+}
+
+// statements optimizes a statement list, splicing in the branches of
+// ifs whose condition is known and dropping what follows a return or
+// throw. Constants are only propagated from the top level of a scope,
+// where their declaration always runs.
+func (o *optimizer) statements(stmts []ast.Statement, top bool) []ast.Statement {
+	out := make([]ast.Statement, 0, len(stmts))
+	for _, stmt := range stmts {
+		if ifStmt, ok := stmt.(*ast.IfStatement); ok {
+			if branch, known := o.branch(ifStmt); known {
+				if branch != nil {
+					out = append(out, o.statements(branch.Statements, top)...)
+				}
+				if terminates(out) {
+					return out
+				}
+				continue
+			}
+		}
+		stmt = o.statement(stmt, top)
+		if stmt == nil {
+			continue
+		}
+		out = append(out, stmt)
+		if terminates(out) {
+			return out
+		}
+	}
+	return out
+}
+
+// terminates reports whether a statement list ends by leaving its
+// function
+func terminates(stmts []ast.Statement) bool {
+	if len(stmts) == 0 {
+		return false
+	}
+	switch stmts[len(stmts)-1].(type) {
+	case *ast.ReturnStatement, *ast.ThrowStatement:
+		return true
+	}
+	return false
+}
+
+// branch picks the branch an if always takes, when its condition folds
+// to a constant
+func (o *optimizer) branch(stmt *ast.IfStatement) (*ast.BlockStatement, bool) {
+	stmt.Condition = o.expression(stmt.Condition)
+	val, ok := value(stmt.Condition)
+	if !ok {
+		return nil, false
+	}
+	if eval.IsTruthy(val) {
+		return stmt.Consequence, true
+	}
+	return stmt.Alternative, true
+}
+
+// statement optimizes one statement, returning nil when it does nothing
+func (o *optimizer) statement(stmt ast.Statement, top bool) ast.Statement {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		stmt.Value = o.expression(stmt.Value)
+	case *ast.ConstStatement:
+		stmt.Value = o.expression(stmt.Value)
+		name := stmt.Name.Value
+		if _, ok := value(stmt.Value); ok && top && o.scope.counts[name] == 1 {
+			o.scope.consts[name] = stmt.Value
+		}
+	case *ast.PrintStatement:
+		stmt.Value = o.expression(stmt.Value)
+	case *ast.ExpressionStatement:
+		stmt.Expression = o.expression(stmt.Expression)
+	case *ast.ReturnStatement:
+		if stmt.Value != nil {
+			stmt.Value = o.expression(stmt.Value)
+		}
+	case *ast.ThrowStatement:
+		stmt.Value = o.expression(stmt.Value)
+	case *ast.BlockStatement:
+		stmt.Statements = o.statements(stmt.Statements, false)
+	case *ast.IfStatement:
+		stmt.Consequence = o.block(stmt.Consequence)
+		if stmt.Alternative != nil {
+			stmt.Alternative = o.block(stmt.Alternative)
+		}
+	case *ast.WhileStatement:
+		stmt.Condition = o.expression(stmt.Condition)
+		if val, ok := value(stmt.Condition); ok && !eval.IsTruthy(val) {
+			return nil
+		}
+		stmt.Body = o.block(stmt.Body)
+	case *ast.ForStatement:
+		if stmt.Init != nil {
+			stmt.Init = o.statement(stmt.Init, false)
+		}
+		if stmt.Condition != nil {
+			stmt.Condition = o.expression(stmt.Condition)
+			if val, ok := value(stmt.Condition); ok && !eval.IsTruthy(val) {
+				return stmt.Init
+			}
+		}
+		if stmt.Update != nil {
+			stmt.Update = o.statement(stmt.Update, false)
+		}
+		stmt.Body = o.block(stmt.Body)
+	case *ast.ClassStatement:
+		for _, method := range stmt.Methods {
+			o.function(method)
+		}
+	case *ast.TryStatement:
+		stmt.Block = o.block(stmt.Block)
+		stmt.Handler = o.block(stmt.Handler)
+	}
+	return stmt
+}
+
+func (o *optimizer) block(block *ast.BlockStatement) *ast.BlockStatement {
+	block.Statements = o.statements(block.Statements, false)
+	return block
+}
+
+func (o *optimizer) expression(expr ast.Expression) ast.Expression {
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		if val, ok := o.scope.lookup(expr.Value); ok {
+			return val
+		}
+	case *ast.PrefixExpression:
+		expr.Right = o.expression(expr.Right)
+		if right, ok := value(expr.Right); ok {
+			if folded, ok := literal(eval.UnaryOp(expr.Operator, right)); ok {
+				return folded
+			}
+		}
+	case *ast.InfixExpression:
+		expr.Left = o.expression(expr.Left)
+		expr.Right = o.expression(expr.Right)
+		left, leftOK := value(expr.Left)
+		right, rightOK := value(expr.Right)
+		if leftOK && rightOK {
+			if folded, ok := literal(eval.BinaryOp(expr.Operator, left, right)); ok {
+				return folded
+			}
+		}
+	case *ast.ArrayLiteral:
+		for i, el := range expr.Elements {
+			expr.Elements[i] = o.expression(el)
+		}
+	case *ast.MapLiteral:
+		for i, key := range expr.Keys {
+			expr.Keys[i] = o.expression(key)
+			expr.Values[i] = o.expression(expr.Values[i])
+		}
+	case *ast.IndexExpression:
+		expr.Left = o.expression(expr.Left)
+		expr.Index = o.expression(expr.Index)
+	case *ast.MemberExpression:
+		expr.Object = o.expression(expr.Object)
+	case *ast.AssignExpression:
+		// The target stays a variable, so assigning to a constant still
+		// fails at runtime
+		if _, ok := expr.Target.(*ast.Identifier); !ok {
+			expr.Target = o.expression(expr.Target)
+		}
+		expr.Value = o.expression(expr.Value)
+	case *ast.CallExpression:
+		expr.Function = o.expression(expr.Function)
+		for i, arg := range expr.Arguments {
+			expr.Arguments[i] = o.expression(arg)
+		}
+	case *ast.FunctionLiteral:
+		o.function(expr)
+	}
+	return expr
+}
+
+// function optimizes fn's body in a scope of its own, where parameters
+// hide constants of the same name
+func (o *optimizer) function(fn *ast.FunctionLiteral) {
+	s := newScope(o.scope)
+	for _, param := range fn.Parameters {
+		s.counts[param.Value]++
+	}
+	s.count(fn.Body)
+	o.scope = s
+	fn.Body.Statements = o.statements(fn.Body.Statements, true)
+	o.scope = s.outer
+}
+
+// value returns the runtime value of a literal
+func value(expr ast.Expression) (object.Object, bool) {
+	switch expr := expr.(type) {
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: expr.Value}, true
+	case *ast.FloatLiteral:
+		return &object.Float{Value: expr.Value}, true
+	case *ast.StringLiteral:
+		return &object.String{Value: expr.Value}, true
+	case *ast.Boolean:
+		return object.NativeBool(expr.Value), true
+	case *ast.Null:
+		return object.NULL, true
+	}
+	return nil, false
+}
+
+// literal turns a folded value back into an expression. Errors are left
+// to happen at runtime.
+func literal(obj object.Object) (ast.Expression, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return &ast.IntegerLiteral{Value: obj.Value}, true
+	case *object.Float:
+		return &ast.FloatLiteral{Value: obj.Value}, true
+	case *object.String:
+		return &ast.StringLiteral{Value: obj.Value}, true
+	case *object.Boolean:
+		return &ast.Boolean{Value: obj.Value}, true
+	case *object.Null:
+		return &ast.Null{}, true
+	}
+	return nil, false
+}
@@ -0,0 +1,57 @@
+package optimizer_test
+
+import (
+	"strings"
+	"testing"
+	"tiger/go/ast"
+	"tiger/go/lexer"
+	"tiger/go/optimizer"
+	"tiger/go/parser"
+)
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string // source parsing to the optimized program
+	}{
+		{"folds constants", "let x = 1 + 2 * 3", "let x = 7"},
+		{"folds strings", `let s = "a" + "b"`, `let s = "ab"`},
+		{"keeps division by zero", "let x = 1 / 0", "let x = 1 / 0"},
+		{"propagates constants", "const n = 2\nlet y = n * 4", "const n = 2\nlet y = 8"},
+		{"splices a known branch", "if (1 < 2) { print 1 } else { print 2 }", "print 1"},
+		{"drops a branch never taken", "if (false) { print 1 }\nprint 2", "print 2"},
+		{"drops code after return", "func f() {\n    return 1\n    print 2\n}", "func f() {\n    return 1\n}"},
+		{"leaves unknown values", "let a = 1\nlet b = a + 1", "let a = 1\nlet b = a + 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program := parse(t, tt.src)
+			optimizer.Optimize(program)
+			if got, want := tree(t, program), tree(t, parse(t, tt.want)); got != want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func parse(t *testing.T, src string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatal(p.Errors())
+	}
+	return program
+}
+
+// tree renders program without positions, so an optimized program can
+// be compared with one parsed from source
+func tree(t *testing.T, program *ast.Program) string {
+	t.Helper()
+	var b strings.Builder
+	if err := ast.Fprint(&b, program); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
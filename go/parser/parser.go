@@ -1,16 +1,80 @@
 package parser
 
 import (
+	"fmt"
 	"strconv"
+	"strings"
 	"tiger/go/ast"
 	"tiger/go/lexer"
 	"tiger/go/token"
 )
 
+const (
+	_ int = iota
+	LOWEST
+	ASSIGN      // =
+	EQUALS      // == or !=
+	LESSGREATER // <, <=, > or >=
+	SUM         // + or -
+	PRODUCT     // * or /
+	PREFIX      // -x or !x
+	POSTFIX     // call(), index[] and member.access
+)
+
+var precedences = map[token.TokenType]int{
+	token.ASSIGN:   ASSIGN,
+	token.EQ:       EQUALS,
+	token.NOT_EQ:   EQUALS,
+	token.LT:       LESSGREATER,
+	token.LTE:      LESSGREATER,
+	token.GT:       LESSGREATER,
+	token.GTE:      LESSGREATER,
+	token.PLUS:     SUM,
+	token.MINUS:    SUM,
+	token.ASTERISK: PRODUCT,
+	token.SLASH:    PRODUCT,
+	token.LPAREN:   POSTFIX,
+	token.LBRACKET: POSTFIX,
+	token.DOT:      POSTFIX,
+}
+
+// OperatorPrecedence returns the precedence of a binary operator such as
+// "+", or LOWEST for anything else
+func OperatorPrecedence(operator string) int {
+	if prec, ok := precedences[token.TokenType(operator)]; ok {
+		return prec
+	}
+	return LOWEST
+}
+
 type Parser struct {
 	l         *lexer.Lexer
 	curToken  token.Token
 	peekToken token.Token
+	errors    []*Error
+}
+
+// Error is a syntax error and the position of the token it was found at
+type Error struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+// ErrorList is the syntax errors of a program, returned as one error by
+// the tools that parse it
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	messages := make([]string, len(l))
+	for i, err := range l {
+		messages[i] = err.Error()
+	}
+	return "parse error: " + strings.Join(messages, "; ")
 }
 
 func New(l *lexer.Lexer) *Parser {
@@ -20,11 +84,54 @@ func New(l *lexer.Lexer) *Parser {
 	return p
 }
 
+// Errors returns the syntax errors found while parsing
+func (p *Parser) Errors() []string {
+	if len(p.errors) == 0 {
+		return nil
+	}
+	messages := make([]string, len(p.errors))
+	for i, err := range p.errors {
+		messages[i] = err.Message
+	}
+	return messages
+}
+
+// ErrorList returns the syntax errors with their positions
+func (p *Parser) ErrorList() ErrorList {
+	return p.errors
+}
+
+func (p *Parser) errorf(tok token.Token, format string, a ...interface{}) {
+	p.errors = append(p.errors, &Error{Line: tok.Line, Column: tok.Column, Message: fmt.Sprintf(format, a...)})
+}
+
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
 }
 
+func (p *Parser) expectPeek(t token.TokenType) bool {
+	if p.peekToken.Type == t {
+		p.nextToken()
+		return true
+	}
+	p.errorf(p.peekToken, "expected %s, got %s", t, describe(p.peekToken))
+	return false
+}
+
+func describe(tok token.Token) string {
+	if tok.Type == token.EOF {
+		return "end of input"
+	}
+	return fmt.Sprintf("%q", tok.Literal)
+}
+
+func (p *Parser) skipSemicolon() {
+	if p.peekToken.Type == token.SEMICOLON {
+		p.nextToken()
+	}
+}
+
 func (p *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{}
 	for p.curToken.Type != token.EOF {
@@ -38,49 +145,98 @@ func (p *Parser) ParseProgram() *ast.Program {
 }
 
 func (p *Parser) parseStatement() ast.Statement {
+	var stmt ast.Statement
 	switch p.curToken.Type {
+	case token.SEMICOLON:
+		return nil
 	case token.LET:
-		return p.parseLetStatement()
+		stmt = nilIfError(p.parseLetStatement())
 	case token.CONST:
-		return p.parseConstStatement()
+		stmt = nilIfError(p.parseConstStatement())
+	case token.PRINT:
+		stmt = nilIfError(p.parsePrintStatement())
 	case token.IF:
-		return p.parseIfStatement()
+		stmt = nilIfError(p.parseIfStatement())
 	case token.WHILE:
-		return p.parseWhileStatement()
+		stmt = nilIfError(p.parseWhileStatement())
 	case token.FOR:
-		return p.parseForStatement()
+		stmt = nilIfError(p.parseForStatement())
 	case token.LBRACE:
-		return p.parseBlockStatement()
+		stmt = p.parseBlockStatement()
 	case token.FUNC:
-		return p.parseFunctionDefinition()
+		if p.peekToken.Type == token.IDENT {
+			stmt = nilIfError(p.parseFunctionDefinition())
+		} else {
+			stmt = nilIfError(p.parseExpressionStatement())
+		}
 	case token.CLASS:
-		return p.parseClassStatement()
+		stmt = nilIfError(p.parseClassStatement())
 	case token.RETURN:
-		return p.parseReturnStatement()
+		stmt = nilIfError(p.parseReturnStatement())
+	case token.TRY:
+		stmt = nilIfError(p.parseTryStatement())
+	case token.THROW:
+		stmt = nilIfError(p.parseThrowStatement())
 	default:
-		return p.parseExpressionStatement()
+		stmt = nilIfError(p.parseExpressionStatement())
+	}
+	return stmt
+}
+
+// nilIfError turns a typed nil statement pointer, returned by the parse
+// functions on a syntax error, into a nil interface
+func nilIfError[T interface {
+	*S
+	ast.Statement
+}, S any](stmt T) ast.Statement {
+	if stmt == nil {
+		return nil
 	}
+	return stmt
 }
 
 func (p *Parser) parseLetStatement() *ast.LetStatement {
-	p.nextToken()
-	name := &ast.Identifier{Value: p.curToken.Literal}
-	p.nextToken() // skip =
+	line := p.curToken.Line
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	name := p.newIdentifier()
+	name.Type = p.parseTypeAnnotation()
+	if !p.expectPeek(token.ASSIGN) {
+		return nil
+	}
 	p.nextToken() // value token
-	value := p.parseExpression()
-	return &ast.LetStatement{Name: name, Value: value}
+	value := p.parseExpression(LOWEST)
+	p.skipSemicolon()
+	return &ast.LetStatement{Name: name, Value: value, Line: line}
+}
+
+func (p *Parser) parsePrintStatement() *ast.PrintStatement {
+	line := p.curToken.Line
+	p.nextToken() // skip 'print'
+	value := p.parseExpression(LOWEST)
+	if value == nil {
+		return nil
+	}
+	p.skipSemicolon()
+	return &ast.PrintStatement{Value: value, Line: line}
 }
 
 func (p *Parser) parseIfStatement() *ast.IfStatement {
+	line := p.curToken.Line
 	p.nextToken() // skip 'if'
-	condition := p.parseExpression()
-	p.nextToken() // skip '{'
+	condition := p.parseExpression(LOWEST)
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
 	consequence := p.parseBlockStatement()
 
 	var alternative *ast.BlockStatement
 	if p.peekToken.Type == token.ELSE {
 		p.nextToken() // skip else
-		p.nextToken() // skip {
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
 		alternative = p.parseBlockStatement()
 	}
 
@@ -88,22 +244,27 @@ func (p *Parser) parseIfStatement() *ast.IfStatement {
 		Condition:   condition,
 		Consequence: consequence,
 		Alternative: alternative,
+		Line:        line,
 	}
 }
 
 func (p *Parser) parseWhileStatement() *ast.WhileStatement {
+	line := p.curToken.Line
 	p.nextToken() // skip 'while'
-	condition := p.parseExpression()
-	p.nextToken() // skip to {
+	condition := p.parseExpression(LOWEST)
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
 	body := p.parseBlockStatement()
 	return &ast.WhileStatement{
 		Condition: condition,
 		Body:      body,
+		Line:      line,
 	}
 }
 
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
-	block := &ast.BlockStatement{}
+	block := &ast.BlockStatement{Line: p.curToken.Line, Column: p.curToken.Column}
 	p.nextToken() // skip {
 	for p.curToken.Type != token.RBRACE && p.curToken.Type != token.EOF {
 		stmt := p.parseStatement()
@@ -112,138 +273,361 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		}
 		p.nextToken()
 	}
+	if p.curToken.Type != token.RBRACE {
+		p.errorf(p.curToken, "expected }, got end of input")
+	}
+	block.EndLine, block.EndColumn = p.curToken.Line, p.curToken.Column
 	return block
 }
 
-func (p *Parser) parseExpression() ast.Expression {
+func (p *Parser) parseExpression(precedence int) ast.Expression {
 	left := p.parsePrimaryExpression()
+	if left == nil {
+		return nil
+	}
 
-	for p.peekToken.Type == token.PLUS || p.peekToken.Type == token.MINUS ||
-		p.peekToken.Type == token.ASTERISK || p.peekToken.Type == token.SLASH ||
-		p.peekToken.Type == token.EQ || p.peekToken.Type == token.NOT_EQ ||
-		p.peekToken.Type == token.LT || p.peekToken.Type == token.GT ||
-		p.peekToken.Type == token.LTE || p.peekToken.Type == token.GTE {
-		p.nextToken()
-		operator := p.curToken.Literal
+	for p.peekToken.Type != token.SEMICOLON && precedence < p.peekPrecedence() {
 		p.nextToken()
-		right := p.parsePrimaryExpression()
-		left = &ast.InfixExpression{Left: left, Operator: operator, Right: right}
+		switch p.curToken.Type {
+		case token.LPAREN:
+			left = p.parseCallExpression(left)
+		case token.LBRACKET:
+			left = p.parseIndexExpression(left)
+		case token.DOT:
+			left = p.parseMemberExpression(left)
+		case token.ASSIGN:
+			left = p.parseAssignExpression(left)
+		default:
+			left = p.parseInfixExpression(left)
+		}
+		if left == nil {
+			return nil
+		}
 	}
 
 	return left
 }
 
+func (p *Parser) peekPrecedence() int {
+	if prec, ok := precedences[p.peekToken.Type]; ok {
+		return prec
+	}
+	return LOWEST
+}
+
+func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
+	expr := &ast.InfixExpression{
+		Left:     left,
+		Operator: p.curToken.Literal,
+		Line:     p.curToken.Line,
+		Column:   p.curToken.Column,
+	}
+	precedence := precedences[p.curToken.Type]
+	p.nextToken()
+	expr.Right = p.parseExpression(precedence)
+	return expr
+}
+
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression, *ast.MemberExpression:
+	default:
+		p.errorf(p.curToken, "invalid assignment target")
+		return nil
+	}
+	p.nextToken()
+	// Assignment is right-associative: a = b = c
+	value := p.parseExpression(ASSIGN - 1)
+	return &ast.AssignExpression{Target: target, Value: value}
+}
+
 func (p *Parser) parsePrimaryExpression() ast.Expression {
+	line, column := p.curToken.Line, p.curToken.Column
 	switch p.curToken.Type {
 	case token.STRING:
-		return &ast.StringLiteral{Value: p.curToken.Literal}
+		return &ast.StringLiteral{Value: p.curToken.Literal, Line: line, Column: column}
 	case token.IDENT:
-		ident := &ast.Identifier{Value: p.curToken.Literal}
-		if p.peekToken.Type == token.LPAREN {
-			return p.parseCallExpression(ident)
-		}
-		return ident
+		return p.newIdentifier()
 	case token.INT:
-		val, _ := strconv.ParseInt(p.curToken.Literal, 0, 64)
-		return &ast.IntegerLiteral{Value: val}
+		val, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+		if err != nil {
+			p.errorf(p.curToken, "invalid integer %s", p.curToken.Literal)
+			return nil
+		}
+		return &ast.IntegerLiteral{Value: val, Line: line, Column: column}
 	case token.FLOAT:
 		val, _ := strconv.ParseFloat(p.curToken.Literal, 64)
-		return &ast.FloatLiteral{Value: val}
+		return &ast.FloatLiteral{Value: val, Line: line, Column: column}
 	case token.TRUE:
-		return &ast.Boolean{Value: true}
+		return &ast.Boolean{Value: true, Line: line, Column: column}
 	case token.FALSE:
-		return &ast.Boolean{Value: false}
+		return &ast.Boolean{Value: false, Line: line, Column: column}
+	case token.NULL:
+		return &ast.Null{Line: line, Column: column}
+	case token.MINUS, token.BANG:
+		operator := p.curToken.Literal
+		p.nextToken()
+		return &ast.PrefixExpression{Operator: operator, Right: p.parseExpression(PREFIX), Line: line, Column: column}
 	case token.LPAREN:
 		p.nextToken()
-		exp := p.parseExpression()
-		p.nextToken() // skip RPAREN
+		exp := p.parseExpression(LOWEST)
+		if !p.expectPeek(token.RPAREN) {
+			return nil
+		}
 		return exp
+	case token.LBRACKET:
+		elements := p.parseExpressionList(token.RBRACKET)
+		return &ast.ArrayLiteral{
+			Elements:  elements,
+			Line:      line,
+			Column:    column,
+			EndLine:   p.curToken.Line,
+			EndColumn: p.curToken.Column,
+		}
+	case token.LBRACE:
+		return p.parseMapLiteral()
+	case token.FUNC:
+		if fn := p.parseFunctionLiteral(""); fn != nil {
+			return fn
+		}
+		return nil
 	default:
+		p.errorf(p.curToken, "unexpected %s", describe(p.curToken))
 		return nil
 	}
 }
 
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	list := []ast.Expression{}
+	if p.peekToken.Type == end {
+		p.nextToken()
+		return list
+	}
+	p.nextToken()
+	list = append(list, p.parseExpression(LOWEST))
+	for p.peekToken.Type == token.COMMA {
+		p.nextToken() // skip value
+		if p.peekToken.Type == end {
+			break // trailing comma
+		}
+		p.nextToken()
+		list = append(list, p.parseExpression(LOWEST))
+	}
+	if !p.expectPeek(end) {
+		return nil
+	}
+	return list
+}
+
+func (p *Parser) parseMapLiteral() ast.Expression {
+	m := &ast.MapLiteral{Line: p.curToken.Line, Column: p.curToken.Column}
+	for p.peekToken.Type != token.RBRACE {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+		m.Keys = append(m.Keys, key)
+		m.Values = append(m.Values, value)
+		if p.peekToken.Type != token.RBRACE && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken() // skip }
+	m.EndLine, m.EndColumn = p.curToken.Line, p.curToken.Column
+	return m
+}
+
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	p.nextToken() // skip [
+	index := p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	return &ast.IndexExpression{Left: left, Index: index}
+}
+
+func (p *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	property := p.newIdentifier()
+	return &ast.MemberExpression{Object: object, Property: property}
+}
+
 func (p *Parser) parseFunctionDefinition() *ast.LetStatement {
+	line, column := p.curToken.Line, p.curToken.Column
 	p.nextToken() // skip 'func'
-	name := p.curToken.Literal
-	p.nextToken() // skip name
-	p.nextToken() // skip LPAREN
+	name := p.newIdentifier()
+	fn := p.parseFunctionLiteral(name.Value)
+	if fn == nil {
+		return nil
+	}
+	fn.Line, fn.Column = line, column
+	return &ast.LetStatement{Name: name, Value: fn, Line: line}
+}
+
+// newIdentifier makes an identifier from the current token
+func (p *Parser) newIdentifier() *ast.Identifier {
+	return &ast.Identifier{
+		TokenLiteralValue: p.curToken.Literal,
+		Value:             p.curToken.Literal,
+		Line:              p.curToken.Line,
+		Column:            p.curToken.Column,
+	}
+}
+
+// parseFunctionLiteral parses `(params) { body }` for both named
+// definitions and anonymous `func(...) { ... }` expressions
+func (p *Parser) parseFunctionLiteral(name string) *ast.FunctionLiteral {
+	line, column := p.curToken.Line, p.curToken.Column
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
 
 	params := []*ast.Identifier{}
-	for p.curToken.Type != token.RPAREN && p.curToken.Type != token.EOF {
-		params = append(params, &ast.Identifier{Value: p.curToken.Literal})
-		p.nextToken()
-		if p.curToken.Type == token.COMMA {
+	for p.peekToken.Type != token.RPAREN {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		param := p.newIdentifier()
+		param.Type = p.parseTypeAnnotation()
+		params = append(params, param)
+		if p.peekToken.Type == token.COMMA {
 			p.nextToken() // skip comma
 		}
 	}
 	p.nextToken() // skip RPAREN
+	returnType := p.parseTypeAnnotation()
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
 	body := p.parseBlockStatement()
-	return &ast.LetStatement{
-		Name: &ast.Identifier{Value: name},
-		Value: &ast.FunctionLiteral{
-			Name:       name,
-			Parameters: params,
-			Body:       body,
-		},
+	return &ast.FunctionLiteral{
+		Name:       name,
+		Parameters: params,
+		Body:       body,
+		ReturnType: returnType,
+		Line:       line,
+		Column:     column,
+	}
+}
+
+// parseTypeAnnotation parses the optional `: type` after a declared name
+// or a parameter list. Type names are identifiers, or the keywords null
+// and func.
+func (p *Parser) parseTypeAnnotation() *ast.TypeAnnotation {
+	if p.peekToken.Type != token.COLON {
+		return nil
+	}
+	p.nextToken() // skip name or )
+	p.nextToken() // skip :
+	switch p.curToken.Type {
+	case token.IDENT, token.NULL, token.FUNC:
+	default:
+		p.errorf(p.curToken, "expected a type, got %s", describe(p.curToken))
+		return nil
+	}
+	return &ast.TypeAnnotation{
+		Name:   p.curToken.Literal,
+		Line:   p.curToken.Line,
+		Column: p.curToken.Column,
 	}
 }
 
 func (p *Parser) parseCallExpression(fn ast.Expression) ast.Expression {
-	p.nextToken() // skip LPAREN
-	args := []ast.Expression{}
-	for p.curToken.Type != token.RPAREN && p.curToken.Type != token.EOF {
-		arg := p.parseExpression()
-		args = append(args, arg)
-		p.nextToken()
-		if p.curToken.Type == token.COMMA {
-			p.nextToken()
-		}
+	line := p.curToken.Line
+	args := p.parseExpressionList(token.RPAREN)
+	if args == nil {
+		return nil
 	}
 	return &ast.CallExpression{
 		Function:  fn,
 		Arguments: args,
+		Line:      line,
+		EndLine:   p.curToken.Line,
+		EndColumn: p.curToken.Column,
 	}
 }
 
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
-	expr := p.parseExpression()
-	return &ast.ExpressionStatement{Expression: expr}
+	line := p.curToken.Line
+	expr := p.parseExpression(LOWEST)
+	if expr == nil {
+		return nil
+	}
+	p.skipSemicolon()
+	return &ast.ExpressionStatement{Expression: expr, Line: line}
 }
 
 func (p *Parser) parseConstStatement() *ast.ConstStatement {
-	p.nextToken()
-	name := &ast.Identifier{Value: p.curToken.Literal}
-	p.nextToken() // skip =
+	line := p.curToken.Line
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	name := p.newIdentifier()
+	name.Type = p.parseTypeAnnotation()
+	if !p.expectPeek(token.ASSIGN) {
+		return nil
+	}
 	p.nextToken() // value token
-	value := p.parseExpression()
-	return &ast.ConstStatement{Name: name, Value: value}
+	value := p.parseExpression(LOWEST)
+	p.skipSemicolon()
+	return &ast.ConstStatement{Name: name, Value: value, Line: line}
 }
 
 func (p *Parser) parseForStatement() *ast.ForStatement {
-	p.nextToken() // skip 'for'
-	p.nextToken() // skip '('
+	line := p.curToken.Line
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
 
 	// Parse init statement
 	var init ast.Statement
-	if p.curToken.Type == token.LET {
-		init = p.parseLetStatement()
+	if p.curToken.Type != token.SEMICOLON {
+		if p.curToken.Type == token.LET {
+			init = nilIfError(p.parseLetStatement())
+		} else {
+			init = nilIfError(p.parseExpressionStatement())
+		}
+		if init == nil {
+			return nil
+		}
+	}
+	if p.curToken.Type != token.SEMICOLON && !p.expectPeek(token.SEMICOLON) {
+		return nil
 	}
-	p.nextToken() // skip ';'
 	p.nextToken()
 
 	// Parse condition
-	condition := p.parseExpression()
-	p.nextToken() // skip ';'
+	var condition ast.Expression
+	if p.curToken.Type != token.SEMICOLON {
+		condition = p.parseExpression(LOWEST)
+		if !p.expectPeek(token.SEMICOLON) {
+			return nil
+		}
+	}
 	p.nextToken()
 
 	// Parse update statement
 	var update ast.Statement
-	if p.curToken.Type == token.IDENT {
-		update = p.parseExpressionStatement()
+	if p.curToken.Type != token.RPAREN {
+		updateLine := p.curToken.Line
+		expr := p.parseExpression(LOWEST)
+		if expr == nil {
+			return nil
+		}
+		update = &ast.ExpressionStatement{Expression: expr, Line: updateLine}
+		if !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
 	}
-	p.nextToken() // skip ')'
-	p.nextToken() // skip '{'
 
 	// Parse body
 	body := p.parseBlockStatement()
@@ -253,27 +637,86 @@ func (p *Parser) parseForStatement() *ast.ForStatement {
 		Condition: condition,
 		Update:    update,
 		Body:      body,
+		Line:      line,
 	}
 }
 
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
+	line, column := p.curToken.Line, p.curToken.Column
+	if p.peekToken.Type == token.SEMICOLON || p.peekToken.Type == token.RBRACE {
+		p.skipSemicolon()
+		return &ast.ReturnStatement{Line: line, Column: column}
+	}
 	p.nextToken()
-	value := p.parseExpression()
-	return &ast.ReturnStatement{Value: value}
+	value := p.parseExpression(LOWEST)
+	p.skipSemicolon()
+	return &ast.ReturnStatement{Value: value, Line: line, Column: column}
+}
+
+func (p *Parser) parseTryStatement() *ast.TryStatement {
+	line := p.curToken.Line
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	block := p.parseBlockStatement()
+	if !p.expectPeek(token.CATCH) {
+		return nil
+	}
+
+	// The error binding is optional: catch (e) { ... } or catch { ... }
+	var param *ast.Identifier
+	if p.peekToken.Type == token.LPAREN {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		param = p.newIdentifier()
+		if !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	handler := p.parseBlockStatement()
+	return &ast.TryStatement{Block: block, Param: param, Handler: handler, Line: line}
+}
+
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	line := p.curToken.Line
+	p.nextToken() // skip 'throw'
+	value := p.parseExpression(LOWEST)
+	if value == nil {
+		return nil
+	}
+	p.skipSemicolon()
+	return &ast.ThrowStatement{Value: value, Line: line}
 }
 
 func (p *Parser) parseClassStatement() *ast.ClassStatement {
-	p.nextToken()
-	name := &ast.Identifier{Value: p.curToken.Literal}
+	line := p.curToken.Line
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	name := p.newIdentifier()
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
 	p.nextToken() // skip '{'
 
 	methods := []*ast.FunctionLiteral{}
 	for p.curToken.Type != token.RBRACE && p.curToken.Type != token.EOF {
 		if p.curToken.Type == token.FUNC {
 			letStmt := p.parseFunctionDefinition()
+			if letStmt == nil {
+				return nil
+			}
 			if functionLit, ok := letStmt.Value.(*ast.FunctionLiteral); ok {
 				methods = append(methods, functionLit)
 			}
+		} else if p.curToken.Type != token.SEMICOLON {
+			p.errorf(p.curToken, "unexpected %s in class body", describe(p.curToken))
+			return nil
 		}
 		p.nextToken()
 	}
@@ -281,5 +724,7 @@ func (p *Parser) parseClassStatement() *ast.ClassStatement {
 	return &ast.ClassStatement{
 		Name:    name,
 		Methods: methods,
+		Line:    line,
+		EndLine: p.curToken.Line,
 	}
 }
@@ -0,0 +1,231 @@
+package profile
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// WritePprof writes the profile in the gzipped protocol buffer format of
+// pprof, so that `go tool pprof` can show it. Each sample is a call path
+// with the statements run and nanoseconds spent on its innermost line;
+// filename names the program in the profile.
+func (p *Profiler) WritePprof(w io.Writer, filename string) error {
+	b := &pprofBuilder{
+		strings:   map[string]int64{"": 0},
+		table:     []string{""},
+		functions: map[*Function]uint64{},
+		locations: map[location]uint64{},
+		filename:  filename,
+	}
+
+	prof := &encoder{}
+	prof.message(1, b.valueType("statements", "count"))
+	prof.message(1, b.valueType("time", "nanoseconds"))
+	b.samples(prof, p.root)
+	for _, loc := range b.locationList {
+		prof.message(4, func(e *encoder) {
+			e.uint64(1, b.locations[loc])
+			e.message(4, func(e *encoder) {
+				e.uint64(1, b.function(loc.fn))
+				e.int64(2, int64(loc.line))
+			})
+		})
+	}
+	for _, fn := range b.functionList {
+		name := b.str(pprofName(fn))
+		file := b.str(b.filename)
+		prof.message(5, func(e *encoder) {
+			e.uint64(1, b.functions[fn])
+			e.int64(2, name)
+			e.int64(3, name)
+			e.int64(4, file)
+			e.int64(5, int64(fn.Line))
+		})
+	}
+	timeType := b.str("time")
+	nanoseconds := b.str("nanoseconds")
+	for _, s := range b.table {
+		prof.string(6, s)
+	}
+	prof.int64(9, p.start.UnixNano())
+	prof.int64(10, int64(p.total))
+	prof.message(11, func(e *encoder) {
+		e.int64(1, timeType)
+		e.int64(2, nanoseconds)
+	})
+	prof.int64(12, 1)
+	prof.int64(14, timeType)
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(prof.buf); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// pprofName is how a function is named in a pprof profile. Pprof takes
+// text in angle brackets for C++ template arguments and drops it, so
+// the top level becomes main and anonymous functions are told apart by
+// their line.
+func pprofName(fn *Function) string {
+	name := fn.Name
+	if strings.HasSuffix(name, "<anonymous>") {
+		name = fmt.Sprintf("%sanonymous@%d", strings.TrimSuffix(name, "<anonymous>"), fn.Line)
+	}
+	if fn.Name == MainFunction {
+		name = "main"
+	}
+	return name
+}
+
+// location is a line of a function, as pprof identifies code
+type location struct {
+	fn   *Function
+	line int
+}
+
+type pprofBuilder struct {
+	strings      map[string]int64
+	table        []string
+	functions    map[*Function]uint64
+	functionList []*Function
+	locations    map[location]uint64
+	locationList []location
+	filename     string
+}
+
+// str returns the index of s in the string table
+func (b *pprofBuilder) str(s string) int64 {
+	i, ok := b.strings[s]
+	if !ok {
+		i = int64(len(b.table))
+		b.strings[s] = i
+		b.table = append(b.table, s)
+	}
+	return i
+}
+
+func (b *pprofBuilder) function(fn *Function) uint64 {
+	id, ok := b.functions[fn]
+	if !ok {
+		id = uint64(len(b.functionList) + 1)
+		b.functions[fn] = id
+		b.functionList = append(b.functionList, fn)
+	}
+	return id
+}
+
+func (b *pprofBuilder) location(fn *Function, line int) uint64 {
+	loc := location{fn, line}
+	id, ok := b.locations[loc]
+	if !ok {
+		id = uint64(len(b.locationList) + 1)
+		b.locations[loc] = id
+		b.locationList = append(b.locationList, loc)
+		b.function(fn)
+	}
+	return id
+}
+
+func (b *pprofBuilder) valueType(typ, unit string) func(e *encoder) {
+	t, u := b.str(typ), b.str(unit)
+	return func(e *encoder) {
+		e.int64(1, t)
+		e.int64(2, u)
+	}
+}
+
+// samples adds a sample for each line of n that cost anything, then for
+// the nodes it called
+func (b *pprofBuilder) samples(prof *encoder, n *node) {
+	lines := make([]int, 0, len(n.costs))
+	for line := range n.costs {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	for _, line := range lines {
+		c := n.costs[line]
+		if c.hits == 0 && c.time == 0 {
+			continue
+		}
+		stack := []uint64{b.location(n.fn, line)}
+		for at := n; at.parent != nil; at = at.parent {
+			stack = append(stack, b.location(at.parent.fn, at.callLine))
+		}
+		prof.message(2, func(e *encoder) {
+			e.packed(1, stack)
+			e.packed(2, []uint64{uint64(c.hits), uint64(c.time)})
+		})
+	}
+	children := make([]*node, 0, len(n.children))
+	for _, child := range n.children {
+		children = append(children, child)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		x, y := children[i], children[j]
+		if x.callLine != y.callLine {
+			return x.callLine < y.callLine
+		}
+		return x.fn.Line < y.fn.Line
+	})
+	for _, child := range children {
+		b.samples(prof, child)
+	}
+}
+
+// encoder writes protocol buffer fields, leaving out zero values
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) varint(x uint64) {
+	for x >= 0x80 {
+		e.buf = append(e.buf, byte(x)|0x80)
+		x >>= 7
+	}
+	e.buf = append(e.buf, byte(x))
+}
+
+func (e *encoder) tag(field, wireType int) {
+	e.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (e *encoder) uint64(field int, x uint64) {
+	if x == 0 {
+		return
+	}
+	e.tag(field, 0)
+	e.varint(x)
+}
+
+func (e *encoder) int64(field int, x int64) {
+	e.uint64(field, uint64(x))
+}
+
+func (e *encoder) bytes(field int, b []byte) {
+	e.tag(field, 2)
+	e.varint(uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// string writes s even when empty, as string table entries must be
+func (e *encoder) string(field int, s string) {
+	e.bytes(field, []byte(s))
+}
+
+func (e *encoder) packed(field int, xs []uint64) {
+	inner := &encoder{}
+	for _, x := range xs {
+		inner.varint(x)
+	}
+	e.bytes(field, inner.buf)
+}
+
+func (e *encoder) message(field int, fill func(e *encoder)) {
+	inner := &encoder{}
+	fill(inner)
+	e.bytes(field, inner.buf)
+}
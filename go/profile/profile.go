@@ -0,0 +1,265 @@
+// Package profile measures where Tiger programs spend their time. A
+// Profiler watches the tree-walker through eval.Hooks, counting calls and
+// statements and timing them, and reports the result as text or as a
+// profile `go tool pprof` can read.
+package profile
+
+import (
+	"sort"
+	"tiger/go/ast"
+	"tiger/go/eval"
+	"tiger/go/object"
+	"time"
+)
+
+// MainFunction names the top level of the script in reports
+const MainFunction = "<main>"
+
+// Function is what a Tiger function cost. Inclusive time counts the
+// functions it called; Exclusive time does not.
+type Function struct {
+	Name      string
+	Line      int // line of its func keyword, 0 for the top level
+	Calls     int
+	Inclusive time.Duration
+	Exclusive time.Duration
+}
+
+// Line is what the statements starting on a line cost
+type Line struct {
+	Line int
+	Hits int // statements run
+	Time time.Duration
+}
+
+// Orders for Functions
+const (
+	ByExclusive = "exclusive"
+	ByInclusive = "inclusive"
+	ByCalls     = "calls"
+)
+
+// Profiler records one run of a program. Time between two events, a
+// statement starting or a call starting or ending, is charged to the
+// statement that was running. Until the first statement of a call runs,
+// that is its caller's statement: setting up a call is a cost of the
+// line making it.
+type Profiler struct {
+	now   func() time.Time
+	start time.Time
+	last  time.Time
+	total time.Duration
+
+	main      *Function
+	functions map[functionKey]*Function
+	lines     map[int]*Line
+
+	root  *node
+	stack []*activation // active calls, the top level first
+}
+
+type functionKey struct {
+	name string
+	line int
+}
+
+// node is a distinct call path: a function called from a line of its
+// caller's node. Costs are kept per line of the function.
+type node struct {
+	fn       *Function
+	parent   *node
+	callLine int // line of the parent the call was made on
+	children map[childKey]*node
+	costs    map[int]*cost
+}
+
+type childKey struct {
+	fn   *Function
+	line int
+}
+
+type cost struct {
+	hits int64
+	time time.Duration
+}
+
+// activation is a call in progress
+type activation struct {
+	node  *node
+	line  int       // line of the statement running, 0 before the first
+	start time.Time // when the first statement started
+}
+
+// New returns a profiler whose clock starts now
+func New() *Profiler {
+	p := &Profiler{
+		now:       time.Now,
+		main:      &Function{Name: MainFunction, Calls: 1},
+		functions: map[functionKey]*Function{},
+		lines:     map[int]*Line{},
+	}
+	p.root = newNode(p.main, nil, 0)
+	p.start = p.now()
+	p.last = p.start
+	p.stack = []*activation{{node: p.root, start: p.start}}
+	return p
+}
+
+func newNode(fn *Function, parent *node, callLine int) *node {
+	return &node{fn: fn, parent: parent, callLine: callLine, children: map[childKey]*node{}, costs: map[int]*cost{}}
+}
+
+// Hooks returns the evaluator hooks to run the program with
+func (p *Profiler) Hooks() *eval.Hooks {
+	return &eval.Hooks{Statement: p.statement, Call: p.call, Return: p.ret}
+}
+
+// charge gives the time since the last event to the running statement
+func (p *Profiler) charge() time.Time {
+	now := p.now()
+	elapsed := now.Sub(p.last)
+	p.last = now
+	top := p.running()
+	top.node.fn.Exclusive += elapsed
+	top.node.cost(top.line).time += elapsed
+	if top.line > 0 {
+		p.line(top.line).Time += elapsed
+	}
+	return now
+}
+
+// running returns the activation whose statement is running: the
+// innermost call, or its caller while it is being set up
+func (p *Profiler) running() *activation {
+	top := p.stack[len(p.stack)-1]
+	if top.line == 0 && len(p.stack) > 1 {
+		return p.stack[len(p.stack)-2]
+	}
+	return top
+}
+
+func (n *node) cost(line int) *cost {
+	c, ok := n.costs[line]
+	if !ok {
+		c = &cost{}
+		n.costs[line] = c
+	}
+	return c
+}
+
+func (p *Profiler) line(line int) *Line {
+	l, ok := p.lines[line]
+	if !ok {
+		l = &Line{Line: line}
+		p.lines[line] = l
+	}
+	return l
+}
+
+func (p *Profiler) statement(stmt ast.Statement, env *eval.Environment) *object.Error {
+	now := p.charge()
+	top := p.stack[len(p.stack)-1]
+	if top.line == 0 {
+		top.start = now
+	}
+	top.line = stmt.StartLine()
+	top.node.cost(top.line).hits++
+	p.line(top.line).Hits++
+	return nil
+}
+
+func (p *Profiler) call(frame eval.Frame) {
+	now := p.charge()
+	key := functionKey{frame.Function, frame.Defined}
+	fn, ok := p.functions[key]
+	if !ok {
+		fn = &Function{Name: frame.Function, Line: frame.Defined}
+		p.functions[key] = fn
+	}
+	fn.Calls++
+
+	caller := p.stack[len(p.stack)-1]
+	child, ok := caller.node.children[childKey{fn, caller.line}]
+	if !ok {
+		child = newNode(fn, caller.node, caller.line)
+		caller.node.children[childKey{fn, caller.line}] = child
+	}
+	p.stack = append(p.stack, &activation{node: child, start: now})
+}
+
+func (p *Profiler) ret(frame eval.Frame) {
+	now := p.charge()
+	top := p.stack[len(p.stack)-1]
+	p.stack = p.stack[:len(p.stack)-1]
+	// A call that ran no statement charged all its time to the caller
+	if top.line == 0 {
+		return
+	}
+	// A recursive call's time is already inside its outermost call's
+	for _, a := range p.stack {
+		if a.node.fn == top.node.fn {
+			return
+		}
+	}
+	top.node.fn.Inclusive += now.Sub(top.start)
+}
+
+// Stop ends the profile once the program has finished
+func (p *Profiler) Stop() {
+	now := p.charge()
+	p.total = now.Sub(p.start)
+	p.main.Inclusive = p.total
+}
+
+// Total returns how long the program ran
+func (p *Profiler) Total() time.Duration {
+	return p.total
+}
+
+// Functions returns the top level and every function called, costliest
+// first in the given order
+func (p *Profiler) Functions(order string) []*Function {
+	fns := []*Function{p.main}
+	for _, fn := range p.functions {
+		fns = append(fns, fn)
+	}
+	key := func(fn *Function) int64 {
+		switch order {
+		case ByInclusive:
+			return int64(fn.Inclusive)
+		case ByCalls:
+			return int64(fn.Calls)
+		}
+		return int64(fn.Exclusive)
+	}
+	sort.Slice(fns, func(i, j int) bool {
+		a, b := fns[i], fns[j]
+		if key(a) != key(b) {
+			return key(a) > key(b)
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Line < b.Line
+	})
+	return fns
+}
+
+// Lines returns every line a statement ran on, most hit first
+func (p *Profiler) Lines() []*Line {
+	lines := make([]*Line, 0, len(p.lines))
+	for _, l := range p.lines {
+		lines = append(lines, l)
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		a, b := lines[i], lines[j]
+		if a.Hits != b.Hits {
+			return a.Hits > b.Hits
+		}
+		if a.Time != b.Time {
+			return a.Time > b.Time
+		}
+		return a.Line < b.Line
+	})
+	return lines
+}
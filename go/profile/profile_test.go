@@ -0,0 +1,136 @@
+package profile
+
+import (
+	"io"
+	"testing"
+	"tiger/go/tiger"
+	"time"
+)
+
+// run profiles src on a clock that moves 1ms each time it is read, so
+// that every event costs 1ms to whatever was running before it
+func run(t *testing.T, src string) *Profiler {
+	t.Helper()
+	p := New()
+	clock := p.start
+	p.now = func() time.Time {
+		clock = clock.Add(time.Millisecond)
+		return clock
+	}
+	interp := tiger.New()
+	interp.Stdout = io.Discard
+	interp.Hooks = p.Hooks()
+	if _, err := interp.Run(src); err != nil {
+		t.Fatal(err)
+	}
+	p.Stop()
+	return p
+}
+
+func TestProfile(t *testing.T) {
+	type fn struct {
+		calls                int
+		inclusive, exclusive int // ms
+	}
+	type line struct {
+		hits int
+		time int // ms
+	}
+	tests := []struct {
+		name      string
+		src       string
+		total     int // ms
+		functions map[string]fn
+		lines     map[int]line
+	}{
+		{
+			name: "setting up a call is charged to the call site",
+			src:  "func f(n) {\n    return n + 1\n}\nlet x = f(1)\nprint x\n",
+			// The call to f and entering its body cost line 4, not
+			// line 1 where f is declared
+			total: 7,
+			functions: map[string]fn{
+				MainFunction: {1, 7, 6},
+				"f":          {1, 1, 1},
+			},
+			lines: map[int]line{1: {1, 1}, 2: {1, 1}, 4: {1, 3}, 5: {1, 1}},
+		},
+		{
+			name:  "a call running no statement is charged to its caller",
+			src:   "func g() {}\ng()\n",
+			total: 5,
+			functions: map[string]fn{
+				MainFunction: {1, 5, 5},
+				"g":          {1, 0, 0},
+			},
+			lines: map[int]line{1: {1, 1}, 2: {1, 3}},
+		},
+		{
+			name: "recursion counts inclusive time once",
+			src:  "func r(n) {\n    if (n > 0) {\n        r(n - 1)\n    }\n}\nr(1)\n",
+			// Setting up r(0) is charged to line 3 of r(1), so r's
+			// inclusive time is that of r(1) alone
+			total: 10,
+			functions: map[string]fn{
+				MainFunction: {1, 10, 5},
+				"r":          {2, 5, 5},
+			},
+			lines: map[int]line{1: {1, 1}, 2: {2, 2}, 3: {1, 3}, 6: {1, 3}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := run(t, tt.src)
+			if p.Total() != time.Duration(tt.total)*time.Millisecond {
+				t.Errorf("total %v, want %dms", p.Total(), tt.total)
+			}
+			got := map[string]fn{}
+			for _, f := range p.Functions(ByExclusive) {
+				got[f.Name] = fn{f.Calls, int(f.Inclusive / time.Millisecond), int(f.Exclusive / time.Millisecond)}
+			}
+			for name, want := range tt.functions {
+				if got[name] != want {
+					t.Errorf("%s: %+v, want %+v", name, got[name], want)
+				}
+			}
+			lines := map[int]line{}
+			for _, l := range p.Lines() {
+				lines[l.Line] = line{l.Hits, int(l.Time / time.Millisecond)}
+			}
+			if len(lines) != len(tt.lines) {
+				t.Errorf("lines %v, want %v", lines, tt.lines)
+			}
+			for n, want := range tt.lines {
+				if lines[n] != want {
+					t.Errorf("line %d: %+v, want %+v", n, lines[n], want)
+				}
+			}
+		})
+	}
+}
+
+func TestFunctionsOrder(t *testing.T) {
+	p := run(t, "func a() { let x = 1 }\nfunc b() { let y = 2 }\na()\nb()\nb()\n")
+	tests := []struct {
+		order string
+		want  []string
+	}{
+		{ByCalls, []string{"b", MainFunction, "a"}},
+		{ByInclusive, []string{MainFunction, "b", "a"}},
+	}
+	for _, tt := range tests {
+		var names []string
+		for _, f := range p.Functions(tt.order) {
+			names = append(names, f.Name)
+		}
+		if len(names) != len(tt.want) {
+			t.Fatalf("%s: %v, want %v", tt.order, names, tt.want)
+		}
+		for i := range names {
+			if names[i] != tt.want[i] {
+				t.Errorf("%s: %v, want %v", tt.order, names, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package profile
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// WriteReport prints the functions, costliest first in the given order,
+// then the lines most hit, showing at most top of each; 0 shows all. Src
+// is the program, quoted beside its lines.
+func (p *Profiler) WriteReport(w io.Writer, src string, order string, top int) {
+	fmt.Fprintf(w, "Total time: %s\n\n", ms(p.total))
+
+	fmt.Fprintf(w, "%10s  %12s  %6s  %12s  %6s  %s\n", "Calls", "Exclusive", "%", "Inclusive", "%", "Function")
+	for _, fn := range limit(p.Functions(order), top) {
+		name := fn.Name
+		if fn.Line > 0 {
+			name = fmt.Sprintf("%s (line %d)", fn.Name, fn.Line)
+		}
+		fmt.Fprintf(w, "%10d  %12s  %6s  %12s  %6s  %s\n",
+			fn.Calls, ms(fn.Exclusive), p.percent(fn.Exclusive), ms(fn.Inclusive), p.percent(fn.Inclusive), name)
+	}
+
+	source := strings.Split(src, "\n")
+	fmt.Fprintf(w, "\n%10s  %12s  %6s  %5s  %s\n", "Hits", "Time", "%", "Line", "Source")
+	for _, l := range limit(p.Lines(), top) {
+		text := ""
+		if l.Line <= len(source) {
+			text = strings.TrimSpace(source[l.Line-1])
+		}
+		fmt.Fprintf(w, "%10d  %12s  %6s  %5d  %s\n", l.Hits, ms(l.Time), p.percent(l.Time), l.Line, text)
+	}
+}
+
+func limit[T any](items []T, top int) []T {
+	if top > 0 && len(items) > top {
+		return items[:top]
+	}
+	return items
+}
+
+func ms(d time.Duration) string {
+	return fmt.Sprintf("%.3fms", float64(d)/float64(time.Millisecond))
+}
+
+func (p *Profiler) percent(d time.Duration) string {
+	if p.total <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(d)/float64(p.total))
+}
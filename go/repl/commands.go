@@ -0,0 +1,185 @@
+package repl
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"tiger/go/ast"
+	"tiger/go/lexer"
+	"tiger/go/parser"
+	"tiger/go/tiger"
+	"tiger/go/token"
+	"tiger/go/types"
+)
+
+// command is a REPL command, typed as :name followed by its argument
+type command struct {
+	name string
+	arg  string // how the argument is written in help, "" for none
+	help string
+	run  func(r *REPL, arg string)
+}
+
+// commands lists the REPL commands, in the order help shows them. It is
+// filled in by init, since :help refers back to it.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"env", "", "list the variables of the session", (*REPL).env},
+		{"tokens", "CODE", "show the tokens of CODE", (*REPL).tokens},
+		{"ast", "CODE", "show the syntax tree of CODE", (*REPL).ast},
+		{"type", "EXPR", "show the type of EXPR without running it", (*REPL).typeOf},
+		{"load", "FILE", "run a Tiger file in the session", (*REPL).load},
+		{"reset", "", "forget every definition of the session", (*REPL).reset},
+		{"save", "FILE", "write the inputs that ran without errors to FILE", (*REPL).save},
+		{"help", "", "show this help", (*REPL).help},
+	}
+}
+
+// command runs a line starting with a colon
+func (r *REPL) command(line string) {
+	name, arg, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(line), ":"), " ")
+	arg = strings.TrimSpace(arg)
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+		if c.arg != "" && arg == "" {
+			fmt.Fprintf(r.out, "usage: :%s %s\n", c.name, c.arg)
+			return
+		}
+		c.run(r, arg)
+		return
+	}
+	fmt.Fprintf(r.out, "unknown command :%s; type :help for a list\n", name)
+}
+
+func (r *REPL) help(string) {
+	fmt.Fprintln(r.out, "Commands:")
+	for _, c := range commands {
+		usage := ":" + c.name
+		if c.arg != "" {
+			usage += " " + c.arg
+		}
+		fmt.Fprintf(r.out, "  %-14s %s\n", usage, c.help)
+	}
+	fmt.Fprintln(r.out, "Input continues on a ... prompt until brackets and strings are closed.")
+	fmt.Fprintln(r.out, "Ctrl-C discards it or stops running code; exit or Ctrl-D leaves.")
+	fmt.Fprintln(r.out, "Up and Down browse the history, Ctrl-R searches it and Tab completes names.")
+}
+
+func (r *REPL) env(string) {
+	globals := r.interp.Globals()
+	names := make([]string, 0, len(globals))
+	for name := range globals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(r.out, "%s: %s = %s\n", name, types.Of(globals[name]), inspect(globals[name]))
+	}
+}
+
+func (r *REPL) tokens(code string) {
+	l := lexer.New(code)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		fmt.Fprintf(r.out, "%d:%d\t%s\t%q\n", tok.Line, tok.Column, tok.Type, tok.Literal)
+	}
+}
+
+// parse parses code, reporting its syntax errors
+func (r *REPL) parse(code string) (*ast.Program, bool) {
+	p := parser.New(lexer.New(code))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		fmt.Fprintln(r.out, &tiger.ParseError{Errors: p.ErrorList()})
+		return nil, false
+	}
+	return program, true
+}
+
+func (r *REPL) ast(code string) {
+	if program, ok := r.parse(code); ok {
+		ast.Fprint(r.out, program)
+	}
+}
+
+func (r *REPL) typeOf(code string) {
+	program, ok := r.parse(code)
+	if !ok {
+		return
+	}
+	var stmt *ast.ExpressionStatement
+	if len(program.Statements) == 1 {
+		stmt, _ = program.Statements[0].(*ast.ExpressionStatement)
+	}
+	if stmt == nil {
+		fmt.Fprintln(r.out, "usage: :type EXPR")
+		return
+	}
+	globals := map[string]*types.Type{}
+	for name, val := range r.interp.Globals() {
+		globals[name] = types.Of(val)
+	}
+	fmt.Fprintln(r.out, types.Infer(stmt.Expression, globals))
+}
+
+func (r *REPL) load(file string) {
+	src, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Fprintln(r.out, err)
+		return
+	}
+	r.eval(string(src))
+}
+
+func (r *REPL) reset(string) {
+	r.interp = newInterpreter(r.out)
+	r.session = nil
+	fmt.Fprintln(r.out, "Session reset")
+}
+
+// save writes each input as its canonical source, so statements split
+// across inputs stay apart. An echoed value read by a later input as _
+// is bound with let, since _ only exists in the REPL.
+func (r *REPL) save(file string) {
+	var src strings.Builder
+	for i, e := range r.session {
+		stmts := e.program.Statements
+		if e.echoed && readsUnderscore(r.session[i+1:]) {
+			last := stmts[len(stmts)-1].(*ast.ExpressionStatement)
+			stmts = append(stmts[:len(stmts)-1:len(stmts)-1], &ast.LetStatement{
+				Name:  &ast.Identifier{Value: "_"},
+				Value: last.Expression,
+			})
+		}
+		for _, stmt := range stmts {
+			src.WriteString(stmt.String())
+			src.WriteByte('\n')
+		}
+	}
+	if err := os.WriteFile(file, []byte(src.String()), 0o644); err != nil {
+		fmt.Fprintln(r.out, err)
+		return
+	}
+	fmt.Fprintf(r.out, "Saved %d inputs to %s\n", len(r.session), file)
+}
+
+// readsUnderscore reports whether the first of entries to mention _ does
+// so before another echo rebinds it
+func readsUnderscore(entries []ran) bool {
+	for _, e := range entries {
+		l := lexer.New(e.program.String())
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+			if tok.Type == token.IDENT && tok.Literal == "_" {
+				return true
+			}
+		}
+		if e.echoed {
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package repl_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"tiger/go/repl"
+	"tiger/go/tiger"
+)
+
+// session runs lines in a new REPL, returning what it wrote
+func session(t *testing.T, lines ...string) string {
+	t.Helper()
+	var out strings.Builder
+	repl.New(&out).Run(strings.NewReader(strings.Join(lines, "\n")+"\n"), nil)
+	return out.String()
+}
+
+func TestSave(t *testing.T) {
+	tests := []struct {
+		name    string
+		inputs  []string
+		want    string // the saved file
+		printed string // by running the saved file
+	}{
+		{
+			"inputs stay separate statements",
+			[]string{"let a = [5, 6]", "a", "[0]", "print a"},
+			"let a = [5, 6];\na;\n[0];\nprint a;\n",
+			"[5, 6]\n",
+		},
+		{
+			"multi-line input",
+			[]string{"func f(x) {", "    return x * 2", "}", "print f(2)"},
+			"func f(x) { return (x * 2); }\nprint f(2);\n",
+			"4\n",
+		},
+		{
+			"_ read later is bound",
+			[]string{"1 + 1", "print _ * 10", "_"},
+			"let _ = (1 + 1);\nprint (_ * 10);\n_;\n",
+			"20\n",
+		},
+		{
+			"_ rebound before it is read",
+			[]string{"1", "2", "print _"},
+			"1;\nlet _ = 2;\nprint _;\n",
+			"2\n",
+		},
+		{
+			"failed inputs are left out",
+			[]string{"let b = 1", "b = undefinedName", "print b"},
+			"let b = 1;\nprint b;\n",
+			"1\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "session.tg")
+			session(t, append(tt.inputs, ":save "+file)...)
+			src, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(src) != tt.want {
+				t.Errorf("saved:\n%s\nwant:\n%s", src, tt.want)
+			}
+			var out strings.Builder
+			interp := tiger.New()
+			interp.Stdout = &out
+			if _, err := interp.RunFile(file); err != nil {
+				t.Fatalf("running the saved file: %v", err)
+			}
+			if out.String() != tt.printed {
+				t.Errorf("printed %q, want %q", out.String(), tt.printed)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package repl
+
+import (
+	"strings"
+	"tiger/go/eval"
+	"tiger/go/object"
+	"tiger/go/token"
+)
+
+// completions returns the names word could be completed to: commands
+// after a colon, module members after a dot, and otherwise keywords,
+// builtins and the variables of the session
+func (r *REPL) completions(word string) []string {
+	if strings.HasPrefix(word, ":") {
+		var names []string
+		for _, c := range commands {
+			names = append(names, ":"+c.name)
+		}
+		return matching(word, names)
+	}
+
+	if dot := strings.LastIndex(word, "."); dot >= 0 {
+		module, ok := r.module(word[:dot])
+		if !ok {
+			return nil
+		}
+		var names []string
+		for name := range module.Members {
+			names = append(names, word[:dot+1]+name)
+		}
+		return matching(word, names)
+	}
+
+	names := append(token.Keywords(), eval.BuiltinNames()...)
+	for name := range r.interp.Globals() {
+		names = append(names, name)
+	}
+	return matching(word, names)
+}
+
+// module returns the module a dotted path such as json or os.path names,
+// looking in the session before the builtins
+func (r *REPL) module(path string) (*object.Module, bool) {
+	parts := strings.Split(path, ".")
+	obj, ok := r.interp.Globals()[parts[0]]
+	if !ok {
+		obj, ok = eval.LookupBuiltin(parts[0])
+	}
+	for _, part := range parts[1:] {
+		module, isModule := obj.(*object.Module)
+		if !ok || !isModule {
+			return nil, false
+		}
+		obj, ok = module.Members[part]
+	}
+	module, isModule := obj.(*object.Module)
+	return module, ok && isModule
+}
@@ -0,0 +1,321 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// errInterrupted is returned by readLine when Ctrl-C is typed
+var errInterrupted = errors.New("interrupted")
+
+// editor reads lines from a terminal in raw mode, with readline-style
+// editing keys, history, reverse search and tab completion
+type editor struct {
+	in      *bufio.Reader
+	out     io.Writer
+	history []string
+	// complete returns the names that could replace word, a partial
+	// name ending at the cursor
+	complete func(word string) []string
+}
+
+// line is the line being edited
+type line struct {
+	prompt string
+	buf    []rune
+	pos    int
+
+	recall int    // index in history of the line shown, len(history) for a new one
+	draft  []rune // the new line, kept while browsing history
+}
+
+func ctrl(key rune) rune {
+	return key & 0x1f
+}
+
+// readLine reads a line, returning io.EOF when Ctrl-D is typed on an
+// empty line and errInterrupted when Ctrl-C is typed
+func (e *editor) readLine(prompt string) (string, error) {
+	l := &line{prompt: prompt, recall: len(e.history)}
+	e.refresh(l)
+	for {
+		key, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch key {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			return string(l.buf), nil
+		case ctrl('C'):
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupted
+		case ctrl('D'):
+			if len(l.buf) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			l.deleteForward()
+		case ctrl('A'):
+			l.pos = 0
+		case ctrl('E'):
+			l.pos = len(l.buf)
+		case ctrl('B'):
+			l.pos = max(l.pos-1, 0)
+		case ctrl('F'):
+			l.pos = min(l.pos+1, len(l.buf))
+		case ctrl('H'), 127:
+			if l.pos > 0 {
+				l.pos--
+				l.deleteForward()
+			}
+		case ctrl('K'):
+			l.buf = l.buf[:l.pos]
+		case ctrl('U'):
+			l.buf = append([]rune{}, l.buf[l.pos:]...)
+			l.pos = 0
+		case ctrl('W'):
+			start := l.wordStart(l.pos)
+			l.buf = append(l.buf[:start], l.buf[l.pos:]...)
+			l.pos = start
+		case ctrl('L'):
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case ctrl('P'):
+			e.recall(l, l.recall-1)
+		case ctrl('N'):
+			e.recall(l, l.recall+1)
+		case ctrl('R'):
+			if err := e.search(l); err != nil {
+				return "", err
+			}
+		case '\t':
+			e.completeWord(l)
+		case 27:
+			if err := e.escape(l); err != nil {
+				return "", err
+			}
+		default:
+			if unicode.IsPrint(key) {
+				l.insert(key)
+			}
+		}
+		e.refresh(l)
+	}
+}
+
+// refresh redraws the line with the cursor in place
+func (e *editor) refresh(l *line) {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", l.prompt, string(l.buf))
+	if back := len(l.buf) - l.pos; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
+
+func (l *line) insert(runes ...rune) {
+	tail := append(append([]rune{}, runes...), l.buf[l.pos:]...)
+	l.buf = append(l.buf[:l.pos], tail...)
+	l.pos += len(runes)
+}
+
+func (l *line) deleteForward() {
+	if l.pos < len(l.buf) {
+		l.buf = append(l.buf[:l.pos], l.buf[l.pos+1:]...)
+	}
+}
+
+// wordStart returns where the word before pos starts, skipping spaces
+// before it
+func (l *line) wordStart(pos int) int {
+	for pos > 0 && l.buf[pos-1] == ' ' {
+		pos--
+	}
+	for pos > 0 && l.buf[pos-1] != ' ' {
+		pos--
+	}
+	return pos
+}
+
+// wordEnd returns where the word after pos ends
+func (l *line) wordEnd(pos int) int {
+	for pos < len(l.buf) && l.buf[pos] == ' ' {
+		pos++
+	}
+	for pos < len(l.buf) && l.buf[pos] != ' ' {
+		pos++
+	}
+	return pos
+}
+
+// escape handles the keys sent as escape sequences: arrows, Home, End,
+// Delete, and Alt-B and Alt-F to move by words
+func (e *editor) escape(l *line) error {
+	next, _, err := e.in.ReadRune()
+	if err != nil {
+		return err
+	}
+	switch next {
+	case 'b':
+		l.pos = l.wordStart(l.pos)
+		return nil
+	case 'f':
+		l.pos = l.wordEnd(l.pos)
+		return nil
+	case '[', 'O':
+	default:
+		return nil
+	}
+
+	// Parameters, then the final byte naming the key
+	var params strings.Builder
+	for {
+		key, _, err := e.in.ReadRune()
+		if err != nil {
+			return err
+		}
+		if key >= '@' && key <= '~' {
+			switch {
+			case key == 'A':
+				e.recall(l, l.recall-1)
+			case key == 'B':
+				e.recall(l, l.recall+1)
+			case key == 'C':
+				l.pos = min(l.pos+1, len(l.buf))
+			case key == 'D':
+				l.pos = max(l.pos-1, 0)
+			case key == 'H', key == '~' && (params.String() == "1" || params.String() == "7"):
+				l.pos = 0
+			case key == 'F', key == '~' && (params.String() == "4" || params.String() == "8"):
+				l.pos = len(l.buf)
+			case key == '~' && params.String() == "3":
+				l.deleteForward()
+			}
+			return nil
+		}
+		params.WriteRune(key)
+	}
+}
+
+// recall shows the history entry at index, or the new line past the
+// end of the history
+func (e *editor) recall(l *line, index int) {
+	if index < 0 || index > len(e.history) || index == l.recall {
+		return
+	}
+	if l.recall == len(e.history) {
+		l.draft = l.buf
+	}
+	l.recall = index
+	if index == len(e.history) {
+		l.buf = l.draft
+	} else {
+		l.buf = []rune(e.history[index])
+	}
+	l.pos = len(l.buf)
+}
+
+// search runs the reverse incremental search Ctrl-R starts. Typing
+// narrows it, Ctrl-R finds an older match, and Ctrl-G or Ctrl-C gives
+// up; any other key takes the match into the line and is then handled
+// as usual.
+func (e *editor) search(l *line) error {
+	var query []rune
+	match := len(e.history)
+	found, failed := "", false
+	// find looks for the newest entry containing the query, from index
+	// back
+	find := func(index int) {
+		for ; index >= 0; index-- {
+			if index < len(e.history) && strings.Contains(e.history[index], string(query)) {
+				match, found, failed = index, e.history[index], false
+				return
+			}
+		}
+		failed = true
+	}
+	for {
+		label := "reverse-i-search"
+		if failed {
+			label = "failing " + label
+		}
+		fmt.Fprintf(e.out, "\r(%s)`%s': %s\x1b[K", label, string(query), found)
+
+		key, _, err := e.in.ReadRune()
+		if err != nil {
+			return err
+		}
+		switch {
+		case key == ctrl('R'):
+			find(match - 1)
+		case key == ctrl('G'), key == ctrl('C'):
+			return nil
+		case key == ctrl('H'), key == 127:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				find(len(e.history) - 1)
+			}
+		case unicode.IsPrint(key):
+			query = append(query, key)
+			find(match)
+		default:
+			if found != "" {
+				l.buf, l.pos, l.recall = []rune(found), len([]rune(found)), match
+			}
+			return e.in.UnreadRune()
+		}
+	}
+}
+
+// completeWord completes the name before the cursor: fully when one
+// name fits, as far as all the names agree when several do, listing
+// them when they agree no further
+func (e *editor) completeWord(l *line) {
+	start := l.pos
+	for start > 0 && isNameRune(l.buf[start-1]) {
+		start--
+	}
+	word := string(l.buf[start:l.pos])
+	names := e.complete(word)
+	if len(names) == 0 {
+		fmt.Fprint(e.out, "\a")
+		return
+	}
+	common := names[0]
+	for _, name := range names[1:] {
+		for !strings.HasPrefix(name, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	if len(common) > len(word) {
+		l.insert([]rune(common[len(word):])...)
+		return
+	}
+	if len(names) > 1 {
+		fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(names, "  "))
+	}
+}
+
+// isNameRune reports whether r may be part of a completed name: an
+// identifier, a dotted module member or a :command
+func isNameRune(r rune) bool {
+	return r == '_' || r == '.' || r == ':' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// matching returns the names starting with prefix, sorted and without
+// duplicates
+func matching(prefix string, names []string) []string {
+	seen := map[string]bool{}
+	var found []string
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) && !seen[name] {
+			seen[name] = true
+			found = append(found, name)
+		}
+	}
+	sort.Strings(found)
+	return found
+}
@@ -0,0 +1,175 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// readKeys runs the editor over keys, typed as a terminal sends them
+func readKeys(keys string, history []string, names ...string) (string, string, error) {
+	var out strings.Builder
+	e := &editor{
+		in:      bufio.NewReader(strings.NewReader(keys)),
+		out:     &out,
+		history: history,
+		complete: func(word string) []string {
+			return matching(word, names)
+		},
+	}
+	got, err := e.readLine(Prompt)
+	return got, out.String(), err
+}
+
+func TestReadLineEditing(t *testing.T) {
+	tests := []struct {
+		name string
+		keys string
+		want string
+	}{
+		{"typing", "print 1\r", "print 1"},
+		{"newline", "print 1\n", "print 1"},
+		{"Ctrl-B inserts before", "abc\x02\x02X\r", "aXbc"},
+		{"Ctrl-A and Ctrl-E", "abc\x01X\x05Y\r", "XabcY"},
+		{"Ctrl-F", "abc\x01\x06X\r", "aXbc"},
+		{"backspace", "abc\x7f\x08\r", "a"},
+		{"backspace at the start", "ab\x01\x7f\r", "ab"},
+		{"Ctrl-D deletes forward", "ab\x02\x04\r", "a"},
+		{"Ctrl-K", "abc\x01\x06\x0b\r", "a"},
+		{"Ctrl-U", "abc def\x02\x02\x15\r", "ef"},
+		{"Ctrl-W", "let foo  bar\x17\r", "let foo  "},
+		{"Ctrl-W skips spaces", "let foo  \x17\r", "let "},
+		{"arrows", "ab\x1b[DX\x1b[CY\r", "aXbY"},
+		{"Home and End", "abc\x1b[HX\x1b[FY\x1b[1~Z\x1b[4~W\r", "ZXabcYW"},
+		{"Delete", "abc\x1b[H\x1b[3~\r", "bc"},
+		{"Alt-B and Alt-F", "one two\x1bbX\x1bb\x1bb\x1bfY\r", "oneY Xtwo"},
+		{"unknown escape", "ab\x1bxc\r", "abc"},
+		{"control keys are ignored", "a\x00b\r", "ab"},
+		{"unicode", "héllo\x02\x7f\r", "hélo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := readKeys(tt.keys, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadLineEnds(t *testing.T) {
+	tests := []struct {
+		keys string
+		err  error
+	}{
+		{"\x04", io.EOF},
+		{"abc\x03", errInterrupted},
+		{"abc", io.EOF}, // the input ends mid-line
+	}
+	for _, tt := range tests {
+		if got, _, err := readKeys(tt.keys, nil); !errors.Is(err, tt.err) || got != "" {
+			t.Errorf("%q: got %q, %v, want %v", tt.keys, got, err, tt.err)
+		}
+	}
+}
+
+func TestReadLineHistory(t *testing.T) {
+	history := []string{"first", "second"}
+	tests := []struct {
+		name string
+		keys string
+		want string
+	}{
+		{"up", "\x1b[A\r", "second"},
+		{"up twice", "\x1b[A\x1b[A\r", "first"},
+		{"past the oldest", "\x1b[A\x1b[A\x1b[A\r", "first"},
+		{"down restores the draft", "new\x1b[A\x1b[A\x1b[B\x1b[B\r", "new"},
+		{"past the newest", "new\x1b[B\r", "new"},
+		{"Ctrl-P and Ctrl-N", "\x10\x10\x0e\r", "second"},
+		{"recalled lines are editable", "\x1b[A!\r", "second!"},
+		{"application mode arrows", "\x1bOA\r", "second"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := readKeys(tt.keys, history)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadLineSearch(t *testing.T) {
+	history := []string{"print 1", "let x = 2", "print 3"}
+	tests := []struct {
+		name string
+		keys string
+		want string
+	}{
+		{"newest match", "\x12pr\r", "print 3"},
+		{"Ctrl-R finds older", "\x12pr\x12\r", "print 1"},
+		{"no older match keeps the last", "\x12pr\x12\x12\r", "print 1"},
+		{"narrowing", "\x12print 1\r", "print 1"},
+		{"backspace widens", "\x12let\x7f\x7f\x7f\r", "print 3"},
+		{"Ctrl-G gives up", "draft\x12pr\x07\r", "draft"},
+		{"Ctrl-C gives up", "draft\x12pr\x03\r", "draft"},
+		{"other keys edit the match", "\x12let\x01#\r", "#let x = 2"},
+		{"a failed search keeps the line", "draft\x12zz\x1b[D\r", "draft"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := readKeys(tt.keys, history)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	_, out, _ := readKeys("\x12zz\r", history)
+	if !strings.Contains(out, "(failing reverse-i-search)`zz': ") {
+		t.Errorf("a failed search showed %q", out)
+	}
+}
+
+func TestReadLineComplete(t *testing.T) {
+	names := []string{"print", "println", "push", "json.parse", "json.stringify", ":help"}
+	tests := []struct {
+		name string
+		keys string
+		want string
+		out  string // shown besides the line
+	}{
+		{"single match", "pu\t\r", "push", ""},
+		{"common prefix", "pr\t\r", "print", ""},
+		{"in the middle", "x = pu + 1\x01\x06\x06\x06\x06\x06\x06\t\r", "x = push + 1", ""},
+		{"module members", "json.s\t\r", "json.stringify", ""},
+		{"commands", ":h\t\r", ":help", ""},
+		{"lists candidates", "p\t\r", "p", "\r\nprint  println  push\r\n"},
+		{"rings on no match", "zz\t\r", "zz", "\a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, out, err := readKeys(tt.keys, nil, names...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if tt.out != "" && !strings.Contains(out, tt.out) {
+				t.Errorf("output %q lacks %q", out, tt.out)
+			}
+		})
+	}
+}
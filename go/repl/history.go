@@ -0,0 +1,62 @@
+package repl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxHistory is how many lines the history file keeps
+const maxHistory = 1000
+
+// historyFile returns where the history is kept: $TIGER_HISTORY, or
+// .tiger_history in the home directory. It is "" when there is nowhere
+// to keep it.
+func historyFile() string {
+	if path, ok := os.LookupEnv("TIGER_HISTORY"); ok {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".tiger_history")
+}
+
+// loadHistory reads the history file, trimming it to its last maxHistory
+// lines. A missing file is an empty history.
+func loadHistory(path string) []string {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return nil
+	}
+	if len(lines) > maxHistory {
+		lines = lines[len(lines)-maxHistory:]
+		os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600)
+	}
+	return lines
+}
+
+// appendHistory adds a line to the history file, so it survives however
+// the session ends
+func appendHistory(path, line string) error {
+	if path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(line + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,72 @@
+package repl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	t.Setenv("TIGER_HISTORY", path)
+	if got := historyFile(); got != path {
+		t.Errorf("historyFile() = %q, want %q", got, path)
+	}
+	t.Setenv("TIGER_HISTORY", "")
+	if got := historyFile(); got != "" {
+		t.Errorf("historyFile() = %q with TIGER_HISTORY empty", got)
+	}
+	if loadHistory("") != nil || appendHistory("", "x") != nil {
+		t.Error("an empty path should keep no history")
+	}
+}
+
+func TestHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	if got := loadHistory(path); got != nil {
+		t.Errorf("missing file loaded %q", got)
+	}
+	for _, line := range []string{"let a = 1", "print a"} {
+		if err := appendHistory(path, line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := loadHistory(path); strings.Join(got, "|") != "let a = 1|print a" {
+		t.Errorf("loaded %q", got)
+	}
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got := loadHistory(path); got != nil {
+		t.Errorf("empty file loaded %q", got)
+	}
+}
+
+func TestHistoryTrimmed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	for i := 0; i < maxHistory+5; i++ {
+		if err := appendHistory(path, fmt.Sprint("line ", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := loadHistory(path)
+	if len(got) != maxHistory || got[0] != "line 5" || got[len(got)-1] != fmt.Sprint("line ", maxHistory+4) {
+		t.Fatalf("loaded %d lines from %q to %q", len(got), got[0], got[len(got)-1])
+	}
+	// The file itself is trimmed, so it does not grow without bound
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != maxHistory {
+		t.Errorf("the file kept %d lines, want %d", lines, maxHistory)
+	}
+	if err := appendHistory(path, "next"); err != nil {
+		t.Fatal(err)
+	}
+	if got := loadHistory(path); len(got) != maxHistory || got[len(got)-1] != "next" {
+		t.Errorf("after appending, loaded %d lines ending in %q", len(got), got[len(got)-1])
+	}
+}
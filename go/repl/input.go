@@ -0,0 +1,49 @@
+package repl
+
+// Incomplete reports whether src stops partway through a statement: with
+// a bracket, brace or parenthesis left open, inside a string or inside a
+// /* comment. Closing brackets without an opener count as complete, so
+// that the parser reports them.
+func Incomplete(src string) bool {
+	depth := 0
+	for i := 0; i < len(src); i++ {
+		switch src[i] {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case '"':
+			for i++; i < len(src) && src[i] != '"'; i++ {
+				if src[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(src) {
+				return true
+			}
+		case '/':
+			if i+1 >= len(src) {
+				break
+			}
+			switch src[i+1] {
+			case '/':
+				for i < len(src) && src[i] != '\n' {
+					i++
+				}
+			case '*':
+				end := -1
+				for j := i + 2; j+1 < len(src); j++ {
+					if src[j] == '*' && src[j+1] == '/' {
+						end = j + 1
+						break
+					}
+				}
+				if end < 0 {
+					return true
+				}
+				i = end
+			}
+		}
+	}
+	return depth > 0
+}
@@ -0,0 +1,32 @@
+package repl_test
+
+import (
+	"testing"
+	"tiger/go/repl"
+)
+
+func TestIncomplete(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"print 1", false},
+		{"func f() {", true},
+		{"func f() {\n    return [1,\n", true},
+		{"func f() {\n}", false},
+		{"let m = {\"a\": (1", true},
+		{`let s = "abc`, true},
+		{`let s = "a\"b`, true},
+		{`let s = "a\"b"`, false},
+		{`let s = "{"`, false},
+		{"/* open", true},
+		{"/* closed */ print 1", false},
+		{"// {", false},
+		{"print 1 }", false},
+	}
+	for _, tt := range tests {
+		if got := repl.Incomplete(tt.src); got != tt.want {
+			t.Errorf("Incomplete(%q) = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
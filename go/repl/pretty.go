@@ -0,0 +1,147 @@
+package repl
+
+import (
+	"strconv"
+	"strings"
+	"tiger/go/eval"
+	"tiger/go/object"
+	"unicode/utf8"
+)
+
+// lineWidth is how wide an array, map or instance may print on one line
+// before its elements are spread over several
+const lineWidth = 80
+
+// ANSI colors of the kinds of values
+const (
+	colorNumber   = "\x1b[33m"
+	colorString   = "\x1b[32m"
+	colorConstant = "\x1b[35m"
+	colorCallable = "\x1b[36m"
+	colorKey      = "\x1b[34m"
+	colorClass    = "\x1b[1m"
+	colorReset    = "\x1b[0m"
+)
+
+// printer renders values as the REPL echoes them: like inspect, but with
+// containers too wide for a line spread over indented lines, and with
+// colors when they are on
+type printer struct {
+	color bool
+	flat  bool // never spread containers over several lines
+
+	visiting map[object.Object]bool // containers being printed, to cut cycles
+}
+
+// pretty renders obj for the REPL's output
+func (r *REPL) pretty(obj object.Object) string {
+	return (&printer{color: r.color}).print(obj, "", 0)
+}
+
+// entry is an element of a container, with the key written before it
+type entry struct {
+	key   string
+	width int // of the key without colors
+	value object.Object
+}
+
+// print renders obj where indent is the indentation of its line and
+// column where obj starts on it
+func (p *printer) print(obj object.Object, indent string, column int) string {
+	switch obj := obj.(type) {
+	case *object.Integer, *object.Float:
+		return p.paint(colorNumber, obj.Inspect())
+	case *object.String:
+		return p.paint(colorString, strconv.Quote(obj.Value))
+	case *object.Boolean, *object.Null:
+		return p.paint(colorConstant, obj.Inspect())
+	case *object.Builtin, *object.Module, *eval.Function, *eval.Class:
+		return p.paint(colorCallable, obj.Inspect())
+	case *object.Array:
+		entries := make([]entry, len(obj.Elements))
+		for i, el := range obj.Elements {
+			entries[i] = entry{value: el}
+		}
+		return p.container(obj, "[", "]", entries, indent, column)
+	case *object.Map:
+		return p.container(obj, "{", "}", p.fields(obj, true), indent, column)
+	case *eval.Instance:
+		name := obj.Class.Name + " "
+		return p.paint(colorClass, obj.Class.Name) + " " +
+			p.container(obj, "{", "}", p.fields(obj.Fields, false), indent, column+len(name))
+	}
+	return obj.Inspect()
+}
+
+// fields lists the pairs of m, with the keys quoted as in map literals
+// or bare as instance fields are written
+func (p *printer) fields(m *object.Map, quote bool) []entry {
+	entries := make([]entry, len(m.Keys))
+	for i, key := range m.Keys {
+		written := key
+		if quote {
+			written = strconv.Quote(key)
+		}
+		entries[i] = entry{
+			key:   p.paint(colorKey, written) + ": ",
+			width: utf8.RuneCountInString(written) + 2,
+			value: m.Pairs[key],
+		}
+	}
+	return entries
+}
+
+// container renders the entries of obj between open and close, on one
+// line when they fit
+func (p *printer) container(obj object.Object, open, close string, entries []entry, indent string, column int) string {
+	if len(entries) == 0 {
+		return open + close
+	}
+	if p.visiting[obj] {
+		return open + "..." + close
+	}
+	if p.visiting == nil {
+		p.visiting = map[object.Object]bool{}
+	}
+	p.visiting[obj] = true
+	defer delete(p.visiting, obj)
+
+	var b strings.Builder
+	b.WriteString(open)
+	if p.flat || p.fits(obj, column) {
+		for i, e := range entries {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(e.key + p.print(e.value, indent, 0))
+		}
+	} else {
+		inner := indent + "  "
+		for i, e := range entries {
+			b.WriteString("\n" + inner + e.key + p.print(e.value, inner, len(inner)+e.width))
+			if i < len(entries)-1 {
+				b.WriteString(",")
+			}
+		}
+		b.WriteString("\n" + indent)
+	}
+	b.WriteString(close)
+	return b.String()
+}
+
+// fits reports whether obj prints on one line from column on
+func (p *printer) fits(obj object.Object, column int) bool {
+	flat := &printer{flat: true, visiting: map[object.Object]bool{}}
+	for v := range p.visiting {
+		flat.visiting[v] = true
+	}
+	delete(flat.visiting, obj)
+	return column+utf8.RuneCountInString(flat.print(obj, "", 0)) <= lineWidth
+}
+
+func (p *printer) paint(color, s string) string {
+	if !p.color {
+		return s
+	}
+	return color + s + colorReset
+}
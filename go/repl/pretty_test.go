@@ -0,0 +1,193 @@
+package repl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"tiger/go/object"
+	"tiger/go/tiger"
+)
+
+// value runs src and returns the value of its final expression
+func value(t *testing.T, src string) object.Object {
+	t.Helper()
+	obj, err := tiger.New().Run(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
+
+func TestPretty(t *testing.T) {
+	long := strings.Repeat("x", 76) // a string whose array is 80 wide
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"number", "42", "42"},
+		{"string", `"a\tb"`, `"a\tb"`},
+		{"null", "null", "null"},
+		{"empty", "[[], {}]", "[[], {}]"},
+		{"map", `let m = {"a": 1, "b c": [true]}; m`, `{"a": 1, "b c": [true]}`},
+		{"fits the line", `["` + long + `"]`, `["` + long + `"]`},
+		{"one over the line", `["` + long + `x"]`, "[\n  \"" + long + "x\"\n]"},
+		{
+			"wide array",
+			"let a = []; for (let i = 0; i < 20; i = i + 1) { push(a, i * 1000) }; a",
+			"[\n  0,\n  1000,\n  2000,\n  3000,\n  4000,\n  5000,\n  6000,\n  7000,\n  8000,\n  9000,\n  10000,\n  11000,\n  12000,\n  13000,\n  14000,\n  15000,\n  16000,\n  17000,\n  18000,\n  19000\n]",
+		},
+		{
+			"only the wide part is spread",
+			`let m = {"short": [1, 2], "long": ["` + long[:40] + `", "` + long[:40] + `"]}; m`,
+			"{\n  \"short\": [1, 2],\n  \"long\": [\n    \"" + long[:40] + "\",\n    \"" + long[:40] + "\"\n  ]\n}",
+		},
+		{
+			// At its indent and after its key, the inner array no longer fits
+			"keys count toward the width",
+			`let m = {"k": ["` + long[:35] + `", "` + long[:35] + `"], "other": 1}; m`,
+			"{\n  \"k\": [\n    \"" + long[:35] + "\",\n    \"" + long[:35] + "\"\n  ],\n  \"other\": 1\n}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (&printer{}).print(value(t, tt.src), "", 0); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrettyInstance(t *testing.T) {
+	class := "class Point { func init(x, y) { this.x = x; this.y = y } }\nclass Empty {}\n"
+	long := strings.Repeat("y", 60)
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`Point(1, "two")`, `Point {x: 1, y: "two"}`},
+		{"Empty()", "Empty {}"},
+		{"[Point(1, 2), Point(3, 4)]", "[Point {x: 1, y: 2}, Point {x: 3, y: 4}]"},
+		{`Point(1, Point(2, 3))`, `Point {x: 1, y: Point {x: 2, y: 3}}`},
+		// The class name counts toward the width
+		{`Point("` + long + `", 1)`, "Point {\n  x: \"" + long + "\",\n  y: 1\n}"},
+	}
+	for _, tt := range tests {
+		if got := (&printer{}).print(value(t, class+tt.src), "", 0); got != tt.want {
+			t.Errorf("%s:\ngot\n%s\nwant\n%s", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestPrettyCycles(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"array", "let a = [1, 2]; push(a, a); a", "[1, 2, [...]]"},
+		{"map", `let m = {"a": 1}; m["self"] = m; m`, `{"a": 1, "self": {...}}`},
+		{"through another", `let a = []; let m = {"a": a}; push(a, m); a`, `[{"a": [...]}]`},
+		{
+			"instance",
+			"class Node { func init() { this.next = this } }\nNode()",
+			"Node {next: Node {...}}",
+		},
+		{"shared is not a cycle", "let b = [1]; [b, b]", "[[1], [1]]"},
+		{
+			"spread cycle",
+			`let a = ["` + strings.Repeat("z", 70) + `"]; push(a, a); a`,
+			"[\n  \"" + strings.Repeat("z", 70) + "\",\n  [...]\n]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (&printer{}).print(value(t, tt.src), "", 0); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrettyColor(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"1", "\x1b[33m1\x1b[0m"},
+		{`"s"`, "\x1b[32m\"s\"\x1b[0m"},
+		{"true", "\x1b[35mtrue\x1b[0m"},
+		{"len", "\x1b[36m" + value(t, "len").Inspect() + "\x1b[0m"},
+		{`let m = {"k": null}; m`, "{\x1b[34m\"k\"\x1b[0m: \x1b[35mnull\x1b[0m}"},
+		{"class C { func init() { this.n = 2 } }\nC()", "\x1b[1mC\x1b[0m {\x1b[34mn\x1b[0m: \x1b[33m2\x1b[0m}"},
+	}
+	for _, tt := range tests {
+		if got := (&printer{color: true}).print(value(t, tt.src), "", 0); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.src, got, tt.want)
+		}
+	}
+
+	// Colors take no room on the line
+	long := strings.Repeat("w", 76)
+	if got := (&printer{color: true}).print(value(t, `["`+long+`"]`), "", 0); strings.Contains(got, "\n") {
+		t.Errorf("colors made %q wrap", got)
+	}
+}
+
+func TestUseColor(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	if useColor(&strings.Builder{}) {
+		t.Error("colored output that is not a file")
+	}
+	f, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if useColor(f) {
+		t.Error("colored output to a file")
+	}
+
+	term, ok := openPTY(t)
+	if !ok {
+		t.Skip("no terminal to test with")
+	}
+	if !useColor(term) {
+		t.Error("no colors on a terminal")
+	}
+	t.Setenv("NO_COLOR", "1")
+	if useColor(term) {
+		t.Error("colored a terminal with NO_COLOR set")
+	}
+}
+
+func TestEchoSetsUnderscore(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string // _ afterwards
+	}{
+		{"expression", "1 + 1", "2"},
+		{"let", "let z = 5", "0"},
+		{"assignment", "x = 9", "9"},
+		{"print", "print 7", "0"},
+		{"null", "null", "0"},
+		{"function", "func f() { return 3 }", "0"},
+		{"ending in an expression", "let y = 4; y * 2", "8"},
+		{"error", "1 / nothing", "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			r := New(&out)
+			r.feed("let x = 0")
+			r.feed("0")
+			r.feed(tt.input)
+			got, _ := r.interp.Get("_")
+			if got.Inspect() != tt.want {
+				t.Errorf("_ = %s, want %s (output %q)", got.Inspect(), tt.want, out.String())
+			}
+		})
+	}
+}
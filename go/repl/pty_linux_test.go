@@ -0,0 +1,33 @@
+package repl
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+// openPTY opens the terminal end of a new pseudo-terminal
+func openPTY(t *testing.T) (*os.File, bool) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		return nil, false
+	}
+	t.Cleanup(func() { master.Close() })
+	var n, unlock uint32
+	for _, req := range []struct {
+		request uintptr
+		arg     *uint32
+	}{{syscall.TIOCSPTLCK, &unlock}, {syscall.TIOCGPTN, &n}} {
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, master.Fd(), req.request, uintptr(unsafe.Pointer(req.arg))); errno != 0 {
+			return nil, false
+		}
+	}
+	term, err := os.OpenFile(fmt.Sprint("/dev/pts/", n), os.O_RDWR, 0)
+	if err != nil {
+		return nil, false
+	}
+	t.Cleanup(func() { term.Close() })
+	return term, true
+}
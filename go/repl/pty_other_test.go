@@ -0,0 +1,14 @@
+//go:build !linux
+
+package repl
+
+import (
+	"os"
+	"testing"
+)
+
+// openPTY opens the terminal end of a new pseudo-terminal, which these
+// tests only do on Linux
+func openPTY(t *testing.T) (*os.File, bool) {
+	return nil, false
+}
@@ -0,0 +1,244 @@
+// Package repl is Tiger's interactive prompt. Input is collected until it
+// forms complete statements, then run in one interpreter, so definitions
+// carry over from one input to the next.
+package repl
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"tiger/go/ast"
+	"tiger/go/lexer"
+	"tiger/go/object"
+	"tiger/go/parser"
+	"tiger/go/tiger"
+)
+
+// Prompts shown before new input and before the lines continuing it
+const (
+	Prompt         = ">>> "
+	ContinuePrompt = "... "
+)
+
+// REPL holds the interpreter of a session and the input read so far
+type REPL struct {
+	interp     *tiger.Interpreter
+	out        io.Writer
+	interrupts <-chan os.Signal
+	color      bool // whether echoed values are colored
+
+	pending []string // lines of an incomplete input
+	session []ran    // inputs that ran without errors, for :save
+}
+
+// ran is an input of the session. An echoed input bound the value of
+// its final expression to _.
+type ran struct {
+	program *ast.Program
+	echoed  bool
+}
+
+// New returns a session writing output and errors to out
+func New(out io.Writer) *REPL {
+	return &REPL{interp: newInterpreter(out), out: out}
+}
+
+func newInterpreter(out io.Writer) *tiger.Interpreter {
+	interp := tiger.New()
+	interp.Stdout = out
+	return interp
+}
+
+// Run reads input from in until it ends or exit is typed. A signal on
+// interrupts, such as the one Ctrl-C sends, discards the pending input
+// or stops the code running. When in and the output are both a
+// terminal, lines are read with an editor keeping history.
+func (r *REPL) Run(in io.Reader, interrupts <-chan os.Signal) {
+	fmt.Fprintln(r.out, "🐯 Tiger Language REPL")
+	fmt.Fprintln(r.out, "Type :help for commands, 'exit' to quit")
+	r.interrupts = interrupts
+	r.color = useColor(r.out)
+
+	if term, ok := r.terminal(in); ok {
+		r.runEditor(in, term)
+		return
+	}
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	for {
+		if len(r.pending) == 0 {
+			fmt.Fprint(r.out, Prompt)
+		} else {
+			fmt.Fprint(r.out, ContinuePrompt)
+		}
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				fmt.Fprintln(r.out)
+				fmt.Fprintln(r.out, "Goodbye!")
+				return
+			}
+			if len(r.pending) == 0 && strings.TrimSpace(line) == "exit" {
+				fmt.Fprintln(r.out, "Goodbye!")
+				return
+			}
+			r.feed(line)
+		case <-interrupts:
+			fmt.Fprintln(r.out)
+			r.pending = nil
+		}
+	}
+}
+
+// terminal returns the terminal in is, when the output goes to one too
+func (r *REPL) terminal(in io.Reader) (*terminal, bool) {
+	f, ok := in.(*os.File)
+	if !ok {
+		return nil, false
+	}
+	if out, ok := r.out.(*os.File); !ok || !isTerminal(out) {
+		return nil, false
+	}
+	term, err := openTerminal(f)
+	return term, err == nil
+}
+
+// useColor reports whether values echoed to out are colored: only on a
+// terminal, and not when NO_COLOR is set
+func useColor(out io.Writer) bool {
+	f, ok := out.(*os.File)
+	return ok && isTerminal(f) && os.Getenv("NO_COLOR") == ""
+}
+
+func isTerminal(f *os.File) bool {
+	_, err := openTerminal(f)
+	return err == nil
+}
+
+// runEditor reads input with the line editor, which has the terminal in
+// raw mode only while a line is typed, so that Ctrl-C stops running code
+// as usual. Every line entered is added to the history file.
+func (r *REPL) runEditor(in io.Reader, term *terminal) {
+	path := historyFile()
+	e := &editor{in: bufio.NewReader(in), out: r.out, history: loadHistory(path), complete: r.completions}
+	for {
+		prompt := Prompt
+		if len(r.pending) > 0 {
+			prompt = ContinuePrompt
+		}
+		if err := term.raw(); err != nil {
+			fmt.Fprintln(r.out, err)
+			return
+		}
+		line, err := e.readLine(prompt)
+		term.restore()
+		switch {
+		case errors.Is(err, errInterrupted):
+			r.pending = nil
+			continue
+		case err != nil:
+			fmt.Fprintln(r.out, "Goodbye!")
+			return
+		}
+
+		if strings.TrimSpace(line) != "" && (len(e.history) == 0 || e.history[len(e.history)-1] != line) {
+			e.history = append(e.history, line)
+			appendHistory(path, line)
+		}
+		if len(r.pending) == 0 && strings.TrimSpace(line) == "exit" {
+			fmt.Fprintln(r.out, "Goodbye!")
+			return
+		}
+		r.feed(line)
+	}
+}
+
+// feed adds a line of input, running the input once it is complete. A
+// line starting with a colon, outside pending input, is a command.
+func (r *REPL) feed(line string) {
+	if len(r.pending) == 0 {
+		switch trimmed := strings.TrimSpace(line); {
+		case trimmed == "":
+			return
+		case strings.HasPrefix(trimmed, ":"):
+			r.command(trimmed)
+			return
+		}
+	}
+	r.pending = append(r.pending, line)
+	input := strings.Join(r.pending, "\n")
+	if Incomplete(input) {
+		return
+	}
+	r.pending = nil
+	r.eval(input)
+}
+
+// eval runs input until it ends or an interrupt arrives
+func (r *REPL) eval(input string) {
+	// An interrupt from before the input was entered is stale
+	select {
+	case <-r.interrupts:
+	default:
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-r.interrupts:
+			cancel()
+		case <-done:
+		}
+	}()
+	result, err := r.interp.RunContext(ctx, input)
+	interrupted := ctx.Err() != nil
+	close(done)
+	cancel()
+
+	var limit *tiger.LimitError
+	switch {
+	case errors.As(err, &limit) && interrupted:
+		fmt.Fprintln(r.out, "interrupted")
+	case err != nil:
+		fmt.Fprintln(r.out, err)
+	default:
+		program := parser.New(lexer.New(input)).ParseProgram()
+		echoed := result != object.NULL && bareExpression(program)
+		r.session = append(r.session, ran{program, echoed})
+		if echoed {
+			fmt.Fprintln(r.out, r.pretty(result))
+			r.interp.Set("_", result)
+		}
+	}
+}
+
+// bareExpression reports whether program ends with an expression
+// statement, whose value is echoed
+func bareExpression(program *ast.Program) bool {
+	if len(program.Statements) == 0 {
+		return false
+	}
+	_, ok := program.Statements[len(program.Statements)-1].(*ast.ExpressionStatement)
+	return ok
+}
+
+// inspect renders a value as written in code, quoting strings
+func inspect(obj object.Object) string {
+	if s, ok := obj.(*object.String); ok {
+		return strconv.Quote(s.Value)
+	}
+	return obj.Inspect()
+}
@@ -0,0 +1,18 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package repl
+
+import (
+	"errors"
+	"os"
+)
+
+// terminal is not supported here, so input is read line by line
+type terminal struct{}
+
+func openTerminal(*os.File) (*terminal, error) {
+	return nil, errors.New("line editing is not supported on this system")
+}
+
+func (t *terminal) raw() error     { return nil }
+func (t *terminal) restore() error { return nil }
@@ -0,0 +1,47 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package repl
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// terminal switches a terminal between raw mode, where keys arrive one
+// by one without echo, and the settings it had
+type terminal struct {
+	fd    uintptr
+	saved syscall.Termios
+}
+
+// openTerminal fails when f is not a terminal
+func openTerminal(f *os.File) (*terminal, error) {
+	t := &terminal{fd: f.Fd()}
+	if err := ioctl(t.fd, ioctlGetTermios, &t.saved); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *terminal) raw() error {
+	raw := t.saved
+	raw.Iflag &^= syscall.BRKINT | syscall.ICRNL | syscall.INPCK | syscall.ISTRIP | syscall.IXON
+	raw.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.IEXTEN | syscall.ISIG
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	return ioctl(t.fd, ioctlSetTermios, &raw)
+}
+
+func (t *terminal) restore() error {
+	return ioctl(t.fd, ioctlSetTermios, &t.saved)
+}
+
+func ioctl(fd, request uintptr, termios *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(unsafe.Pointer(termios)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,292 @@
+// Package resolver binds the variables of a parsed program ahead of
+// evaluation. Names declared in a function get a slot in that function's
+// frame, recorded on each identifier; names that are used before their
+// declaration or declared nowhere are reported.
+package resolver
+
+import (
+	"fmt"
+	"tiger/go/ast"
+)
+
+// Error is a name that cannot be bound
+type Error struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+// scope is a function body, or the program itself for globals. Slots
+// holds every name declared in it; declared those whose declaration
+// has been passed.
+type scope struct {
+	fn       *ast.FunctionLiteral
+	slots    map[string]int
+	declared map[string]bool
+	outer    *scope
+}
+
+type resolver struct {
+	scope   *scope
+	defined func(name string) bool
+	errors  []*Error
+}
+
+// Resolve annotates the identifiers and function literals of program
+// and returns the problems found. defined reports the names a program
+// may use without declaring them, such as builtins and values set by the
+// host.
+func Resolve(program *ast.Program, defined func(name string) bool) []*Error {
+	r := &resolver{defined: defined}
+	r.scope = newScope(nil, nil)
+	for _, stmt := range program.Statements {
+		declare(stmt, r.scope)
+	}
+	for _, stmt := range program.Statements {
+		r.statement(stmt)
+	}
+	return r.errors
+}
+
+func newScope(fn *ast.FunctionLiteral, outer *scope) *scope {
+	return &scope{
+		fn:       fn,
+		slots:    make(map[string]int),
+		declared: make(map[string]bool),
+		outer:    outer,
+	}
+}
+
+func (s *scope) add(name string) {
+	if _, ok := s.slots[name]; !ok {
+		s.slots[name] = len(s.slots)
+	}
+}
+
+// declare collects the names stmt declares in s, without entering
+// nested functions
+func declare(stmt ast.Statement, s *scope) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		s.add(stmt.Name.Value)
+	case *ast.ConstStatement:
+		s.add(stmt.Name.Value)
+	case *ast.ClassStatement:
+		s.add(stmt.Name.Value)
+	case *ast.BlockStatement:
+		for _, inner := range stmt.Statements {
+			declare(inner, s)
+		}
+	case *ast.IfStatement:
+		declare(stmt.Consequence, s)
+		if stmt.Alternative != nil {
+			declare(stmt.Alternative, s)
+		}
+	case *ast.WhileStatement:
+		declare(stmt.Body, s)
+	case *ast.ForStatement:
+		if stmt.Init != nil {
+			declare(stmt.Init, s)
+		}
+		declare(stmt.Body, s)
+	case *ast.TryStatement:
+		declare(stmt.Block, s)
+		if stmt.Param != nil {
+			s.add(stmt.Param.Value)
+		}
+		declare(stmt.Handler, s)
+	}
+}
+
+func (r *resolver) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		r.definition(stmt.Name, stmt.Value)
+	case *ast.ConstStatement:
+		r.definition(stmt.Name, stmt.Value)
+	case *ast.PrintStatement:
+		r.expression(stmt.Value)
+	case *ast.ExpressionStatement:
+		r.expression(stmt.Expression)
+	case *ast.ReturnStatement:
+		if stmt.Value != nil {
+			r.expression(stmt.Value)
+		}
+	case *ast.ThrowStatement:
+		r.expression(stmt.Value)
+	case *ast.BlockStatement:
+		for _, inner := range stmt.Statements {
+			r.statement(inner)
+		}
+	case *ast.IfStatement:
+		r.expression(stmt.Condition)
+		r.statement(stmt.Consequence)
+		if stmt.Alternative != nil {
+			r.statement(stmt.Alternative)
+		}
+	case *ast.WhileStatement:
+		r.expression(stmt.Condition)
+		r.statement(stmt.Body)
+	case *ast.ForStatement:
+		if stmt.Init != nil {
+			r.statement(stmt.Init)
+		}
+		if stmt.Condition != nil {
+			r.expression(stmt.Condition)
+		}
+		if stmt.Update != nil {
+			r.statement(stmt.Update)
+		}
+		r.statement(stmt.Body)
+	case *ast.ClassStatement:
+		r.bind(stmt.Name)
+		for _, method := range stmt.Methods {
+			r.function(method, true)
+		}
+	case *ast.TryStatement:
+		r.statement(stmt.Block)
+		if stmt.Param != nil {
+			r.bind(stmt.Param)
+		}
+		r.statement(stmt.Handler)
+	}
+}
+
+// definition resolves a let or const. A function may refer to itself,
+// but other values cannot read the variable they initialize.
+func (r *resolver) definition(name *ast.Identifier, value ast.Expression) {
+	if _, ok := value.(*ast.FunctionLiteral); ok {
+		r.bind(name)
+		r.expression(value)
+		return
+	}
+	r.expression(value)
+	r.bind(name)
+}
+
+// bind marks the declaration of ident as passed and records its slot
+func (r *resolver) bind(ident *ast.Identifier) {
+	r.scope.declared[ident.Value] = true
+	r.annotate(ident, r.scope, 0)
+}
+
+func (r *resolver) expression(expr ast.Expression) {
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		r.use(expr, false)
+	case *ast.PrefixExpression:
+		r.expression(expr.Right)
+	case *ast.InfixExpression:
+		r.expression(expr.Left)
+		r.expression(expr.Right)
+	case *ast.ArrayLiteral:
+		for _, el := range expr.Elements {
+			r.expression(el)
+		}
+	case *ast.MapLiteral:
+		for i, key := range expr.Keys {
+			r.expression(key)
+			r.expression(expr.Values[i])
+		}
+	case *ast.IndexExpression:
+		r.expression(expr.Left)
+		r.expression(expr.Index)
+	case *ast.MemberExpression:
+		r.expression(expr.Object)
+	case *ast.AssignExpression:
+		r.expression(expr.Value)
+		r.expression(expr.Target)
+	case *ast.CallExpression:
+		if ident, ok := expr.Function.(*ast.Identifier); ok {
+			r.use(ident, true)
+		} else {
+			r.expression(expr.Function)
+		}
+		for _, arg := range expr.Arguments {
+			r.expression(arg)
+		}
+	case *ast.FunctionLiteral:
+		r.function(expr, false)
+	}
+}
+
+// function resolves fn in a scope of its own. Methods get this in
+// slot 0, ahead of the parameters.
+func (r *resolver) function(fn *ast.FunctionLiteral, method bool) {
+	s := newScope(fn, r.scope)
+	if method {
+		s.add("this")
+		s.declared["this"] = true
+	}
+	for _, param := range fn.Parameters {
+		s.add(param.Value)
+	}
+	declare(fn.Body, s)
+
+	fn.Locals = make([]string, len(s.slots))
+	for name, slot := range s.slots {
+		fn.Locals[slot] = name
+	}
+
+	r.scope = s
+	for _, param := range fn.Parameters {
+		r.bind(param)
+	}
+	r.statement(fn.Body)
+	r.scope = s.outer
+}
+
+// use resolves a variable read or assigned at ident. Declarations later
+// in the same scope are errors; later declarations in enclosing scopes
+// are fine, since a function usually runs after its surroundings.
+func (r *resolver) use(ident *ast.Identifier, called bool) {
+	depth := 0
+	for s := r.scope; s != nil; s = s.outer {
+		if _, ok := s.slots[ident.Value]; ok {
+			if s == r.scope && !s.declared[ident.Value] && !r.predefined(s, ident.Value) {
+				r.errorf(ident, "%s used before its declaration", ident.Value)
+			}
+			r.annotate(ident, s, depth)
+			return
+		}
+		depth++
+	}
+
+	r.annotate(ident, nil, 0)
+	if r.defined == nil || !r.defined(ident.Value) {
+		if called {
+			r.errorf(ident, "undefined function: %s", ident.Value)
+		} else {
+			r.errorf(ident, "undefined variable: %s", ident.Value)
+		}
+	}
+}
+
+// predefined reports whether a global already exists before the program
+// runs, as definitions from earlier REPL input do
+func (r *resolver) predefined(s *scope, name string) bool {
+	return s.fn == nil && r.defined != nil && r.defined(name)
+}
+
+// annotate records where ident's variable lives. Globals, held in s with
+// no function, and unknown names are looked up by name.
+func (r *resolver) annotate(ident *ast.Identifier, s *scope, depth int) {
+	ident.Local = s != nil && s.fn != nil
+	ident.Depth, ident.Index = 0, 0
+	if ident.Local {
+		ident.Depth = depth
+		ident.Index = s.slots[ident.Value]
+	}
+}
+
+func (r *resolver) errorf(ident *ast.Identifier, format string, a ...interface{}) {
+	r.errors = append(r.errors, &Error{
+		Line:    ident.Line,
+		Column:  ident.Column,
+		Message: fmt.Sprintf(format, a...),
+	})
+}
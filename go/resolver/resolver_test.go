@@ -0,0 +1,47 @@
+package resolver_test
+
+import (
+	"reflect"
+	"testing"
+	"tiger/go/lexer"
+	"tiger/go/parser"
+	"tiger/go/resolver"
+)
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{"declared", "let x = 1\nfunc f(a) { return a + x }\nprint f(x)\n", nil},
+		{"functions call later functions", "func a() { return b() }\nfunc b() { return 1 }\nprint a()\n", nil},
+		{"called before its declaration", "print f()\nfunc f() { return 1 }\n", []string{"line 1, column 7: f used before its declaration"}},
+		{"host names", "print len(host)\n", nil},
+		{"undefined variable", "print y\n", []string{"line 1, column 7: undefined variable: y"}},
+		{"undefined function", "nope(1)\n", []string{"line 1, column 1: undefined function: nope"}},
+		{
+			name: "local used before its declaration",
+			src:  "func f() {\n    print v\n    let v = 1\n}\n",
+			want: []string{"line 2, column 11: v used before its declaration"},
+		},
+		{"scopes end with their function", "func f() { let v = 1 }\nprint v\n", []string{"line 2, column 7: undefined variable: v"}},
+	}
+	defined := func(name string) bool { return name == "len" || name == "host" }
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parser.New(lexer.New(tt.src))
+			program := p.ParseProgram()
+			if len(p.Errors()) > 0 {
+				t.Fatal(p.Errors())
+			}
+			var got []string
+			for _, err := range resolver.Resolve(program, defined) {
+				got = append(got, err.Error())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("errors %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
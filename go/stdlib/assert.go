@@ -0,0 +1,182 @@
+package stdlib
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"tiger/go/eval"
+	"tiger/go/object"
+)
+
+// AssertionKind is the Kind of the errors failed assertions raise
+const AssertionKind = "AssertionError"
+
+func init() {
+	eval.RegisterBuiltin("assert", 1, 2, builtinAssert)
+	eval.RegisterBuiltin("assertEqual", 2, 3, builtinAssertEqual)
+	eval.RegisterBuiltin("assertThrows", 1, 2, builtinAssertThrows)
+}
+
+// assertionError makes the catchable error of a failed assertion. The
+// optional message given to the assertion leads the report.
+func assertionError(args []object.Object, at int, report string) *object.Error {
+	message := AssertionKind + ": "
+	if len(args) > at {
+		message += inspectMessage(args[at]) + ": "
+	}
+	return &object.Error{Message: message + report, Kind: AssertionKind}
+}
+
+func inspectMessage(obj object.Object) string {
+	if s, ok := obj.(*object.String); ok {
+		return s.Value
+	}
+	return obj.Inspect()
+}
+
+// builtinAssert fails unless its first argument is truthy
+func builtinAssert(args ...object.Object) (object.Object, error) {
+	if eval.IsTruthy(args[0]) {
+		return object.NULL, nil
+	}
+	return assertionError(args, 1, "assertion failed, got "+inspectValue(args[0])), nil
+}
+
+// builtinAssertEqual fails unless actual == expected, describing where
+// the two differ
+func builtinAssertEqual(args ...object.Object) (object.Object, error) {
+	actual, expected := args[0], args[1]
+	if object.Equal(actual, expected) {
+		return object.NULL, nil
+	}
+	var report strings.Builder
+	report.WriteString("values are not equal")
+	if a, ok := actual.(*object.String); ok {
+		if e, ok := expected.(*object.String); ok && (strings.Contains(a.Value, "\n") || strings.Contains(e.Value, "\n")) {
+			report.WriteString("\n  diff (- expected, + actual):")
+			for _, line := range diffLines(strings.Split(e.Value, "\n"), strings.Split(a.Value, "\n")) {
+				report.WriteString("\n    " + line)
+			}
+			return assertionError(args, 2, report.String()), nil
+		}
+	}
+	fmt.Fprintf(&report, "\n  expected: %s\n  actual:   %s", inspectValue(expected), inspectValue(actual))
+	if where := difference("", expected, actual); where != "" {
+		report.WriteString("\n  " + where)
+	}
+	return assertionError(args, 2, report.String()), nil
+}
+
+// builtinAssertThrows calls a function with no arguments and fails unless
+// it throws. When a second argument is given, the error's message must
+// contain it. The caught value is returned.
+func builtinAssertThrows(args ...object.Object) (object.Object, error) {
+	result := eval.ApplyFunction(args[0], nil)
+	errObj, ok := result.(*object.Error)
+	if !ok {
+		return assertionError(nil, 0, "expected an error, but none was thrown"), nil
+	}
+	if errObj.Limit {
+		return errObj, nil
+	}
+	if len(args) > 1 {
+		want := inspectMessage(args[1])
+		if !strings.Contains(errObj.Message, want) {
+			return assertionError(nil, 0, fmt.Sprintf("expected an error containing %s, got %s", strconv.Quote(want), strconv.Quote(errObj.Message))), nil
+		}
+	}
+	return eval.CaughtValue(errObj), nil
+}
+
+// inspectValue renders a value for reports, quoting strings
+func inspectValue(obj object.Object) string {
+	if s, ok := obj.(*object.String); ok {
+		return strconv.Quote(s.Value)
+	}
+	return obj.Inspect()
+}
+
+// difference describes the first place, below path, where expected and
+// actual differ, or returns "" if they differ as a whole
+func difference(path string, expected, actual object.Object) string {
+	at := func(report string) string {
+		if path == "" {
+			return report
+		}
+		return "at " + path + ": " + report
+	}
+	switch e := expected.(type) {
+	case *object.Array:
+		a, ok := actual.(*object.Array)
+		if !ok {
+			break
+		}
+		for i := 0; i < len(e.Elements) && i < len(a.Elements); i++ {
+			if !object.Equal(a.Elements[i], e.Elements[i]) {
+				return difference(fmt.Sprintf("%s[%d]", path, i), e.Elements[i], a.Elements[i])
+			}
+		}
+		return at(fmt.Sprintf("expected %d elements, got %d", len(e.Elements), len(a.Elements)))
+	case *object.Map:
+		a, ok := actual.(*object.Map)
+		if !ok {
+			break
+		}
+		for _, key := range e.Keys {
+			other, ok := a.Pairs[key]
+			if !ok {
+				return at("missing key " + strconv.Quote(key))
+			}
+			if !object.Equal(other, e.Pairs[key]) {
+				return difference(path+"["+strconv.Quote(key)+"]", e.Pairs[key], other)
+			}
+		}
+		for _, key := range a.Keys {
+			if _, ok := e.Pairs[key]; !ok {
+				return at("unexpected key " + strconv.Quote(key))
+			}
+		}
+	}
+	if path == "" {
+		return ""
+	}
+	return at(fmt.Sprintf("expected %s, got %s", inspectValue(expected), inspectValue(actual)))
+}
+
+// diffLines compares two texts line by line, marking lines only in
+// expected with "- " and lines only in actual with "+ "
+func diffLines(expected, actual []string) []string {
+	// common[i][j] is the length of the longest common subsequence of
+	// expected[i:] and actual[j:]
+	common := make([][]int, len(expected)+1)
+	for i := range common {
+		common[i] = make([]int, len(actual)+1)
+	}
+	for i := len(expected) - 1; i >= 0; i-- {
+		for j := len(actual) - 1; j >= 0; j-- {
+			if expected[i] == actual[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	var lines []string
+	i, j := 0, 0
+	for i < len(expected) || j < len(actual) {
+		switch {
+		case i < len(expected) && j < len(actual) && expected[i] == actual[j]:
+			lines = append(lines, "  "+expected[i])
+			i++
+			j++
+		case i < len(expected) && (j == len(actual) || common[i+1][j] >= common[i][j+1]):
+			lines = append(lines, "- "+expected[i])
+			i++
+		default:
+			lines = append(lines, "+ "+actual[j])
+			j++
+		}
+	}
+	return lines
+}
@@ -0,0 +1,60 @@
+// Package stdlib registers Tiger's standard builtins with the evaluator.
+// Importing it, usually for side effects, makes them available.
+package stdlib
+
+import (
+	"fmt"
+	"tiger/go/eval"
+	"tiger/go/object"
+	"unicode/utf8"
+)
+
+func init() {
+	eval.RegisterBuiltin("len", 1, 1, builtinLen)
+	eval.RegisterBuiltin("type", 1, 1, builtinType)
+	eval.RegisterBuiltin("str", 1, 1, builtinStr)
+	eval.RegisterBuiltin("keys", 1, 1, builtinKeys)
+	eval.RegisterBuiltin("push", 2, eval.Variadic, builtinPush)
+}
+
+func builtinLen(args ...object.Object) (object.Object, error) {
+	switch arg := args[0].(type) {
+	case *object.String:
+		return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}, nil
+	case *object.Array:
+		return &object.Integer{Value: int64(len(arg.Elements))}, nil
+	case *object.Map:
+		return &object.Integer{Value: int64(len(arg.Keys))}, nil
+	}
+	return nil, fmt.Errorf("unsupported argument %s", args[0].Type())
+}
+
+func builtinType(args ...object.Object) (object.Object, error) {
+	return &object.String{Value: string(args[0].Type())}, nil
+}
+
+func builtinStr(args ...object.Object) (object.Object, error) {
+	return &object.String{Value: args[0].Inspect()}, nil
+}
+
+func builtinKeys(args ...object.Object) (object.Object, error) {
+	m, ok := args[0].(*object.Map)
+	if !ok {
+		return nil, fmt.Errorf("expected a map, got %s", args[0].Type())
+	}
+	keys := make([]object.Object, len(m.Keys))
+	for i, key := range m.Keys {
+		keys[i] = &object.String{Value: key}
+	}
+	return &object.Array{Elements: keys}, nil
+}
+
+// builtinPush appends to an array in place and returns it
+func builtinPush(args ...object.Object) (object.Object, error) {
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return nil, fmt.Errorf("expected an array, got %s", args[0].Type())
+	}
+	arr.Elements = append(arr.Elements, args[1:]...)
+	return arr, nil
+}
@@ -0,0 +1,532 @@
+package stdlib
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"tiger/go/eval"
+	"tiger/go/object"
+	"unicode/utf8"
+)
+
+// maxJSONDepth bounds how deeply arrays and maps may nest, in documents
+// parsed and values stringified alike, so that deep input is an error
+// rather than a Go stack overflow
+const maxJSONDepth = 10000
+
+func init() {
+	eval.RegisterBuiltin("json.parse", 1, 1, jsonParse)
+	eval.RegisterBuiltin("json.stringify", 1, 2, jsonStringify)
+}
+
+func jsonParse(args ...object.Object) (object.Object, error) {
+	str, ok := args[0].(*object.String)
+	if !ok {
+		return nil, fmt.Errorf("expected a string, got %s", args[0].Type())
+	}
+	return ParseJSON(str.Value)
+}
+
+func jsonStringify(args ...object.Object) (object.Object, error) {
+	indent := ""
+	if len(args) == 2 {
+		switch arg := args[1].(type) {
+		case *object.Integer:
+			indent = strings.Repeat(" ", int(min(max(arg.Value, 0), 10)))
+		case *object.String:
+			indent = arg.Value
+		case *object.Null:
+		default:
+			return nil, fmt.Errorf("indent must be an integer or string, got %s", arg.Type())
+		}
+	}
+	out, err := StringifyJSON(args[0], indent)
+	var raised *raisedError
+	if errors.As(err, &raised) {
+		return raised.err, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &object.String{Value: out}, nil
+}
+
+// JSONSyntaxError reports malformed JSON with a 1-based line and column
+type JSONSyntaxError struct {
+	Msg    string
+	Line   int
+	Column int
+}
+
+func (e *JSONSyntaxError) Error() string {
+	return fmt.Sprintf("%s at line %d, column %d", e.Msg, e.Line, e.Column)
+}
+
+// ParseJSON decodes a JSON document into Tiger values: objects become
+// maps (keeping key order), numbers without a fraction or exponent
+// become integers.
+func ParseJSON(input string) (object.Object, error) {
+	d := &jsonDecoder{input: input}
+	d.skipWhitespace()
+	val, err := d.value()
+	if err != nil {
+		return nil, err
+	}
+	d.skipWhitespace()
+	if d.pos < len(d.input) {
+		return nil, d.errorf("unexpected %s after value", d.describe())
+	}
+	return val, nil
+}
+
+type jsonDecoder struct {
+	input string
+	pos   int
+	depth int // of the arrays and objects being decoded
+}
+
+func (d *jsonDecoder) errorf(format string, a ...interface{}) error {
+	line, column := 1, 1
+	for _, ch := range d.input[:d.pos] {
+		if ch == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return &JSONSyntaxError{Msg: fmt.Sprintf(format, a...), Line: line, Column: column}
+}
+
+func (d *jsonDecoder) describe() string {
+	if d.pos >= len(d.input) {
+		return "end of input"
+	}
+	ch, _ := utf8.DecodeRuneInString(d.input[d.pos:])
+	return fmt.Sprintf("character %q", ch)
+}
+
+func (d *jsonDecoder) skipWhitespace() {
+	for d.pos < len(d.input) {
+		switch d.input[d.pos] {
+		case ' ', '\t', '\n', '\r':
+			d.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (d *jsonDecoder) value() (object.Object, error) {
+	if d.pos >= len(d.input) {
+		return nil, d.errorf("unexpected end of input")
+	}
+	switch ch := d.input[d.pos]; {
+	case ch == '{':
+		return d.object()
+	case ch == '[':
+		return d.array()
+	case ch == '"':
+		str, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		return &object.String{Value: str}, nil
+	case ch == '-' || ('0' <= ch && ch <= '9'):
+		return d.number()
+	case strings.HasPrefix(d.input[d.pos:], "true"):
+		d.pos += 4
+		return object.TRUE, nil
+	case strings.HasPrefix(d.input[d.pos:], "false"):
+		d.pos += 5
+		return object.FALSE, nil
+	case strings.HasPrefix(d.input[d.pos:], "null"):
+		d.pos += 4
+		return object.NULL, nil
+	}
+	return nil, d.errorf("unexpected %s", d.describe())
+}
+
+// nest enters an array or object, failing when that nests too deeply
+func (d *jsonDecoder) nest() error {
+	if d.depth++; d.depth > maxJSONDepth {
+		return d.errorf("arrays and objects nested deeper than %d", maxJSONDepth)
+	}
+	return nil
+}
+
+func (d *jsonDecoder) object() (object.Object, error) {
+	if err := d.nest(); err != nil {
+		return nil, err
+	}
+	defer func() { d.depth-- }()
+	m := object.NewMap()
+	d.pos++ // skip {
+	d.skipWhitespace()
+	if d.pos < len(d.input) && d.input[d.pos] == '}' {
+		d.pos++
+		return m, nil
+	}
+	for {
+		d.skipWhitespace()
+		if d.pos >= len(d.input) || d.input[d.pos] != '"' {
+			return nil, d.errorf("expected string key, got %s", d.describe())
+		}
+		key, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		d.skipWhitespace()
+		if d.pos >= len(d.input) || d.input[d.pos] != ':' {
+			return nil, d.errorf("expected ':' after object key, got %s", d.describe())
+		}
+		d.pos++
+		d.skipWhitespace()
+		val, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		m.Set(key, val)
+		d.skipWhitespace()
+		if d.pos < len(d.input) && d.input[d.pos] == ',' {
+			d.pos++
+			continue
+		}
+		if d.pos < len(d.input) && d.input[d.pos] == '}' {
+			d.pos++
+			return m, nil
+		}
+		return nil, d.errorf("expected ',' or '}' in object, got %s", d.describe())
+	}
+}
+
+func (d *jsonDecoder) array() (object.Object, error) {
+	if err := d.nest(); err != nil {
+		return nil, err
+	}
+	defer func() { d.depth-- }()
+	arr := &object.Array{Elements: []object.Object{}}
+	d.pos++ // skip [
+	d.skipWhitespace()
+	if d.pos < len(d.input) && d.input[d.pos] == ']' {
+		d.pos++
+		return arr, nil
+	}
+	for {
+		d.skipWhitespace()
+		val, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		arr.Elements = append(arr.Elements, val)
+		d.skipWhitespace()
+		if d.pos < len(d.input) && d.input[d.pos] == ',' {
+			d.pos++
+			continue
+		}
+		if d.pos < len(d.input) && d.input[d.pos] == ']' {
+			d.pos++
+			return arr, nil
+		}
+		return nil, d.errorf("expected ',' or ']' in array, got %s", d.describe())
+	}
+}
+
+func (d *jsonDecoder) string() (string, error) {
+	var out strings.Builder
+	d.pos++ // skip opening quote
+	for {
+		if d.pos >= len(d.input) {
+			return "", d.errorf("unterminated string")
+		}
+		ch := d.input[d.pos]
+		switch {
+		case ch == '"':
+			d.pos++
+			return out.String(), nil
+		case ch < 0x20:
+			return "", d.errorf("control character in string")
+		case ch == '\\':
+			d.pos++
+			if d.pos >= len(d.input) {
+				return "", d.errorf("unterminated string")
+			}
+			switch esc := d.input[d.pos]; esc {
+			case '"', '\\', '/':
+				out.WriteByte(esc)
+			case 'b':
+				out.WriteByte('\b')
+			case 'f':
+				out.WriteByte('\f')
+			case 'n':
+				out.WriteByte('\n')
+			case 'r':
+				out.WriteByte('\r')
+			case 't':
+				out.WriteByte('\t')
+			case 'u':
+				r, err := d.unicodeEscape()
+				if err != nil {
+					return "", err
+				}
+				out.WriteRune(r)
+				continue
+			default:
+				return "", d.errorf("invalid escape sequence \\%c", esc)
+			}
+			d.pos++
+		default:
+			out.WriteByte(ch)
+			d.pos++
+		}
+	}
+}
+
+// unicodeEscape decodes \uXXXX, including surrogate pairs; d.pos is on
+// the 'u' and is left after the last hex digit
+func (d *jsonDecoder) unicodeEscape() (rune, error) {
+	r, err := d.hex4()
+	if err != nil {
+		return 0, err
+	}
+	if 0xD800 <= r && r < 0xDC00 && strings.HasPrefix(d.input[d.pos:], "\\u") {
+		d.pos++
+		low, err := d.hex4()
+		if err != nil {
+			return 0, err
+		}
+		if 0xDC00 <= low && low < 0xE000 {
+			return (r-0xD800)<<10 + (low - 0xDC00) + 0x10000, nil
+		}
+		return utf8.RuneError, nil
+	}
+	return r, nil
+}
+
+func (d *jsonDecoder) hex4() (rune, error) {
+	d.pos++ // skip 'u'
+	if d.pos+4 > len(d.input) {
+		return 0, d.errorf("invalid unicode escape")
+	}
+	n, err := strconv.ParseUint(d.input[d.pos:d.pos+4], 16, 32)
+	if err != nil {
+		return 0, d.errorf("invalid unicode escape")
+	}
+	d.pos += 4
+	return rune(n), nil
+}
+
+func (d *jsonDecoder) number() (object.Object, error) {
+	start := d.pos
+	isFloat := false
+	if d.input[d.pos] == '-' {
+		d.pos++
+	}
+	intStart := d.pos
+	if !d.digits() {
+		return nil, d.errorf("invalid number")
+	}
+	if d.input[intStart] == '0' && d.pos-intStart > 1 {
+		d.pos = start
+		return nil, d.errorf("invalid number: leading zero")
+	}
+	if d.pos < len(d.input) && d.input[d.pos] == '.' {
+		isFloat = true
+		d.pos++
+		if !d.digits() {
+			return nil, d.errorf("invalid number")
+		}
+	}
+	if d.pos < len(d.input) && (d.input[d.pos] == 'e' || d.input[d.pos] == 'E') {
+		isFloat = true
+		d.pos++
+		if d.pos < len(d.input) && (d.input[d.pos] == '+' || d.input[d.pos] == '-') {
+			d.pos++
+		}
+		if !d.digits() {
+			return nil, d.errorf("invalid number")
+		}
+	}
+
+	literal := d.input[start:d.pos]
+	if !isFloat {
+		if n, err := strconv.ParseInt(literal, 10, 64); err == nil {
+			return &object.Integer{Value: n}, nil
+		}
+	}
+	f, err := strconv.ParseFloat(literal, 64)
+	if err != nil {
+		d.pos = start
+		return nil, d.errorf("number %s out of range", literal)
+	}
+	return &object.Float{Value: f}, nil
+}
+
+func (d *jsonDecoder) digits() bool {
+	start := d.pos
+	for d.pos < len(d.input) && '0' <= d.input[d.pos] && d.input[d.pos] <= '9' {
+		d.pos++
+	}
+	return d.pos > start
+}
+
+// StringifyJSON encodes a Tiger value as JSON. A non-empty indent puts
+// each array element and map entry on its own line. Instances are
+// encoded through their toJSON method.
+func StringifyJSON(val object.Object, indent string) (string, error) {
+	e := &jsonEncoder{indent: indent, seen: make(map[object.Object]bool)}
+	if err := e.encode(val, 0); err != nil {
+		return "", err
+	}
+	return e.out.String(), nil
+}
+
+// raisedError carries an error a toJSON method raised out of the
+// encoder, keeping its kind and whether it is a limit error
+type raisedError struct {
+	err *object.Error
+}
+
+func (e *raisedError) Error() string { return e.err.Message }
+
+type jsonEncoder struct {
+	out    strings.Builder
+	indent string
+	seen   map[object.Object]bool
+}
+
+func (e *jsonEncoder) newline(depth int) {
+	if e.indent == "" {
+		return
+	}
+	e.out.WriteByte('\n')
+	for i := 0; i < depth; i++ {
+		e.out.WriteString(e.indent)
+	}
+}
+
+func (e *jsonEncoder) encode(val object.Object, depth int) error {
+	// seen holds the arrays, maps and instances val is nested in
+	if len(e.seen) >= maxJSONDepth {
+		return fmt.Errorf("cannot serialize values nested deeper than %d", maxJSONDepth)
+	}
+	switch val := val.(type) {
+	case *object.Null:
+		e.out.WriteString("null")
+	case *object.Boolean:
+		e.out.WriteString(strconv.FormatBool(val.Value))
+	case *object.Integer:
+		e.out.WriteString(strconv.FormatInt(val.Value, 10))
+	case *object.Float:
+		if math.IsNaN(val.Value) || math.IsInf(val.Value, 0) {
+			return fmt.Errorf("cannot serialize %v", val.Value)
+		}
+		e.out.WriteString(strconv.FormatFloat(val.Value, 'g', -1, 64))
+	case *object.String:
+		writeJSONString(&e.out, val.Value)
+	case *object.Array:
+		if e.seen[val] {
+			return fmt.Errorf("cannot serialize cyclic structure")
+		}
+		e.seen[val] = true
+		defer delete(e.seen, val)
+
+		if len(val.Elements) == 0 {
+			e.out.WriteString("[]")
+			return nil
+		}
+		e.out.WriteByte('[')
+		for i, el := range val.Elements {
+			if i > 0 {
+				e.out.WriteByte(',')
+			}
+			e.newline(depth + 1)
+			if err := e.encode(el, depth+1); err != nil {
+				return err
+			}
+		}
+		e.newline(depth)
+		e.out.WriteByte(']')
+	case *object.Map:
+		if e.seen[val] {
+			return fmt.Errorf("cannot serialize cyclic structure")
+		}
+		e.seen[val] = true
+		defer delete(e.seen, val)
+
+		if len(val.Keys) == 0 {
+			e.out.WriteString("{}")
+			return nil
+		}
+		e.out.WriteByte('{')
+		for i, key := range val.Keys {
+			if i > 0 {
+				e.out.WriteByte(',')
+			}
+			e.newline(depth + 1)
+			writeJSONString(&e.out, key)
+			e.out.WriteByte(':')
+			if e.indent != "" {
+				e.out.WriteByte(' ')
+			}
+			if err := e.encode(val.Pairs[key], depth+1); err != nil {
+				return err
+			}
+		}
+		e.newline(depth)
+		e.out.WriteByte('}')
+	case *eval.Instance:
+		method, ok := eval.BindMethod(val, "toJSON")
+		if !ok {
+			return fmt.Errorf("cannot serialize instance of %s without a toJSON method", val.Class.Name)
+		}
+		if e.seen[val] {
+			return fmt.Errorf("cannot serialize cyclic structure")
+		}
+		e.seen[val] = true
+		defer delete(e.seen, val)
+
+		result := eval.ApplyFunction(method, nil)
+		if errObj, ok := result.(*object.Error); ok {
+			return &raisedError{&object.Error{
+				Message: fmt.Sprintf("error: json.stringify: %s.toJSON failed: %s", val.Class.Name, errObj.Message),
+				Limit:   errObj.Limit,
+				Kind:    errObj.Kind,
+				Stack:   errObj.Stack,
+			}}
+		}
+		return e.encode(result, depth)
+	case *eval.Function, *object.Builtin, eval.Callable:
+		return fmt.Errorf("cannot serialize function %s", val.Inspect())
+	default:
+		return fmt.Errorf("cannot serialize %s", val.Inspect())
+	}
+	return nil
+}
+
+func writeJSONString(out *strings.Builder, s string) {
+	out.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			out.WriteString(`\"`)
+		case '\\':
+			out.WriteString(`\\`)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\r':
+			out.WriteString(`\r`)
+		case '\t':
+			out.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(out, `\u%04x`, r)
+			} else {
+				out.WriteRune(r)
+			}
+		}
+	}
+	out.WriteByte('"')
+}
@@ -0,0 +1,182 @@
+package stdlib_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"tiger/go/object"
+	"tiger/go/stdlib"
+	"tiger/go/tiger"
+)
+
+// run evaluates src in a fresh interpreter, returning its value, or the
+// message of its error, and what it printed
+func run(t *testing.T, src string) (object.Object, string) {
+	t.Helper()
+	var out strings.Builder
+	interp := tiger.New()
+	interp.Stdout = &out
+	val, err := interp.Run(src)
+	var runtime *tiger.RuntimeError
+	if errors.As(err, &runtime) {
+		return &object.Error{Message: runtime.Message}, out.String()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return val, out.String()
+}
+
+func TestParseJSON(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string // Inspect of the value, or the error
+	}{
+		{`{"b": 1, "a": [true, null, 2.5, "x"]}`, `{"b": 1, "a": [true, null, 2.500000, "x"]}`},
+		{`0`, `0`},
+		{`-0`, `0`},
+		{`-12`, `-12`},
+		{`1e3`, `1000.000000`},
+		{`0.5`, `0.500000`},
+		{`9223372036854775808`, `9223372036854775808.000000`},
+		{`"é😀\n"`, "é😀\n"},
+		{` [ ] `, `[]`},
+		{`01`, `invalid number: leading zero at line 1, column 1`},
+		{`-01`, `invalid number: leading zero at line 1, column 1`},
+		{`[00]`, `invalid number: leading zero at line 1, column 2`},
+		{`1.`, `invalid number at line 1, column 3`},
+		{`[1,]`, `unexpected character ']' at line 1, column 4`},
+		{"{\n\"a\" 1}", `expected ':' after object key, got character '1' at line 2, column 5`},
+		{`"abc`, `unterminated string at line 1, column 5`},
+		{`"\x"`, `invalid escape sequence \x at line 1, column 3`},
+		{`[1] 2`, `unexpected character '2' after value at line 1, column 5`},
+		{`1e999`, `number 1e999 out of range at line 1, column 1`},
+		{strings.Repeat("[", 10000) + strings.Repeat("]", 10000), strings.Repeat("[", 10000) + strings.Repeat("]", 10000)},
+		{strings.Repeat("[", 10001), `arrays and objects nested deeper than 10000 at line 1, column 10001`},
+		{strings.Repeat(`{"a":`, 3000000), `arrays and objects nested deeper than 10000 at line 1, column 50001`},
+	}
+	for _, tt := range tests {
+		name := tt.input
+		if len(name) > 20 {
+			name = name[:20]
+		}
+		t.Run(name, func(t *testing.T) {
+			val, err := stdlib.ParseJSON(tt.input)
+			got := ""
+			if err != nil {
+				var syntax *stdlib.JSONSyntaxError
+				if !errors.As(err, &syntax) {
+					t.Fatalf("error %v is not a JSONSyntaxError", err)
+				}
+				got = err.Error()
+			} else if s, ok := val.(*object.String); ok {
+				got = s.Value
+			} else {
+				got = val.Inspect()
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStringifyJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string // evaluated to the value to stringify
+		indent string
+		want   string // the JSON, or the error
+	}{
+		{"scalars", `[1, 2.5, "a\"b\n", true, null]`, "", `[1,2.5,"a\"b\n",true,null]`},
+		{"key order", `let m = {"b": 1, "a": {}}; m`, "", `{"b":1,"a":{}}`},
+		{"indent", `let m = {"a": [1, 2], "b": []}; m`, "  ", "{\n  \"a\": [\n    1,\n    2\n  ],\n  \"b\": []\n}"},
+		{"control characters", `json.parse("\"\\u0001\"")`, "", `"\u0001"`},
+		{"toJSON", `class P { func toJSON() { return {"p": 1} } }; [P()]`, " ", "[\n {\n  \"p\": 1\n }\n]"},
+		{"no toJSON", `class P {}; P()`, "", "cannot serialize instance of P without a toJSON method"},
+		{"function", `func f() {}; f`, "", "cannot serialize function"},
+		{"cycle", `let a = []; push(a, a); a`, "", "cannot serialize cyclic structure"},
+		{"deep", `let a = []; let i = 0; while (i < 10000) { a = [a]; i = i + 1 }; a`, "", "cannot serialize values nested deeper than 10000"},
+		{"endless toJSON", `class C { func toJSON() { return C() } }; C()`, "", "cannot serialize values nested deeper than 10000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			val, err := tiger.New().Run(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := stdlib.StringifyJSON(val, tt.indent)
+			if err != nil {
+				got = err.Error()
+			}
+			if !strings.HasPrefix(got, tt.want) {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   string
+		want  string // printed, or the message of the uncaught error
+		limit bool
+	}{
+		{"parse errors are catchable", `try { json.parse("01") } catch (e) { print e["message"] }`,
+			"error: json.parse: invalid number: leading zero at line 1, column 1\n", false},
+		{"deep parse is catchable", `let s = ""; let i = 0; while (i < 20000) { s = s + "["; i = i + 1 }; try { json.parse(s) } catch (e) { print "caught" }`,
+			"caught\n", false},
+		{"toJSON errors are wrapped", `class B { func toJSON() { throw "nope" } }; try { json.stringify(B()) } catch (e) { print e["message"] }`,
+			"error: json.stringify: B.toJSON failed: nope\n", false},
+		{"toJSON limit errors stay limit errors", `class L { func toJSON() { while (true) {} } }; try { json.stringify(L()) } catch (e) { print "caught" }`,
+			"error: json.stringify: L.toJSON failed: execution limit exceeded", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			interp := tiger.New()
+			interp.Stdout = &out
+			interp.MaxSteps = 100000
+			_, err := interp.Run(tt.src)
+			var limit *tiger.LimitError
+			switch {
+			case tt.limit:
+				if !errors.As(err, &limit) || !strings.HasPrefix(limit.Message, tt.want) {
+					t.Errorf("got %v, want a limit error %s", err, tt.want)
+				}
+			case err != nil:
+				t.Fatal(err)
+			case out.String() != tt.want:
+				t.Errorf("printed %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONBuiltins(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string // printed, or the error
+	}{
+		{"round trip", `let d = json.parse("{\"name\": \"Tiger\", \"tags\": [1, 2]}"); d["age"] = 5; print json.stringify(d)`,
+			"{\"name\":\"Tiger\",\"tags\":[1,2],\"age\":5}\n"},
+		{"member access", `let d = json.parse("{\"name\": \"Tiger\"}"); print d.name`, "Tiger\n"},
+		{"indent count", `print json.stringify([1], 2)`, "[\n  1\n]\n"},
+		{"indent string", `print json.stringify([1], "--")`, "[\n--1\n]\n"},
+		{"parse errors", `json.parse("[1,]")`, "error: json.parse: unexpected character ']' at line 1, column 4"},
+		{"cycles", `let a = [1]; a[0] = a; json.stringify(a)`, "error: json.stringify: cannot serialize cyclic structure"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			val, out := run(t, tt.src)
+			if err, ok := val.(*object.Error); ok {
+				out = err.Message
+			}
+			if out != tt.want {
+				t.Errorf("printed %q, want %q", out, tt.want)
+			}
+		})
+	}
+}
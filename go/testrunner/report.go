@@ -0,0 +1,149 @@
+package testrunner
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Failed counts the results of tests that failed
+func Failed(results []*Result) int {
+	failed := 0
+	for _, r := range results {
+		if !r.Passed() {
+			failed++
+		}
+	}
+	return failed
+}
+
+// WriteText reports each failed test with its error and output, then a
+// summary; verbose also lists the tests that passed, with their output
+func WriteText(w io.Writer, results []*Result, verbose bool) {
+	var total time.Duration
+	for _, r := range results {
+		total += r.Duration
+		if r.Passed() && !verbose {
+			continue
+		}
+		status := "PASS"
+		if !r.Passed() {
+			status = "FAIL"
+		}
+		fmt.Fprintf(w, "--- %s: %s (%s:%d, %s)\n", status, r.Name, r.File, r.Line, seconds(r.Duration))
+		if !r.Passed() {
+			fmt.Fprintln(w, indent(r.Failure, "    "))
+		}
+		if r.Output != "" {
+			fmt.Fprintln(w, "    output:")
+			fmt.Fprintln(w, indent(strings.TrimSuffix(r.Output, "\n"), "      "))
+		}
+	}
+	if failed := Failed(results); failed > 0 {
+		fmt.Fprintf(w, "FAIL: %d of %d tests failed (%s)\n", failed, len(results), seconds(total))
+	} else {
+		fmt.Fprintf(w, "PASS: %d tests (%s)\n", len(results), seconds(total))
+	}
+}
+
+// WriteTAP reports the results in the Test Anything Protocol, version 13
+func WriteTAP(w io.Writer, results []*Result) {
+	fmt.Fprintln(w, "TAP version 13")
+	fmt.Fprintf(w, "1..%d\n", len(results))
+	for i, r := range results {
+		status := "ok"
+		if !r.Passed() {
+			status = "not ok"
+		}
+		fmt.Fprintf(w, "%s %d - %s: %s\n", status, i+1, r.File, r.Name)
+		if r.Passed() {
+			continue
+		}
+		fmt.Fprintln(w, "  ---")
+		fmt.Fprintln(w, "  message: |")
+		fmt.Fprintln(w, indent(r.Failure, "    "))
+		fmt.Fprintf(w, "  at: %s:%d\n", r.File, r.Line)
+		fmt.Fprintf(w, "  duration_ms: %.3f\n", float64(r.Duration)/float64(time.Millisecond))
+		fmt.Fprintln(w, "  ...")
+	}
+}
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit reports the results as JUnit XML, a test suite per file
+func WriteJUnit(w io.Writer, results []*Result) error {
+	var doc junitSuites
+	suites := map[string]int{}
+	var totals []time.Duration
+	for _, r := range results {
+		i, ok := suites[r.File]
+		if !ok {
+			i = len(doc.Suites)
+			suites[r.File] = i
+			doc.Suites = append(doc.Suites, junitSuite{Name: r.File})
+			totals = append(totals, 0)
+		}
+		totals[i] += r.Duration
+		suite := &doc.Suites[i]
+		c := junitCase{Name: r.Name, Classname: r.File, Time: junitTime(r.Duration), SystemOut: r.Output}
+		if !r.Passed() {
+			message, _, _ := strings.Cut(r.Failure, "\n")
+			c.Failure = &junitFailure{Message: message, Text: r.Failure}
+			suite.Failures++
+		}
+		suite.Tests++
+		suite.Cases = append(suite.Cases, c)
+	}
+	for i, total := range totals {
+		doc.Suites[i].Time = junitTime(total)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3fs", d.Seconds())
+}
+
+func indent(text, prefix string) string {
+	return prefix + strings.ReplaceAll(text, "\n", "\n"+prefix)
+}
@@ -0,0 +1,145 @@
+// Package testrunner runs tests written in Tiger: the functions named
+// test_* declared at the top level of files named *_test.tg. Each test
+// gets an interpreter of its own, so tests cannot see each other's
+// variables, and fails when it raises an error, typically from one of
+// the assert builtins.
+package testrunner
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"tiger/go/ast"
+	"tiger/go/eval"
+	"tiger/go/lexer"
+	"tiger/go/parser"
+	"tiger/go/tiger"
+	"time"
+)
+
+// FileSuffix ends the names of files holding tests
+const FileSuffix = "_test.tg"
+
+// Prefix starts the names of test functions
+const Prefix = "test_"
+
+// Test is a test function of a file
+type Test struct {
+	Name   string
+	Line   int
+	params int
+}
+
+// Result is the outcome of one test. Failure is empty for a test that
+// passed; Output holds what the test printed. Duration includes running
+// the file's top level.
+type Result struct {
+	File     string
+	Name     string
+	Line     int
+	Failure  string
+	Output   string
+	Duration time.Duration
+}
+
+// Passed reports whether the test passed
+func (r *Result) Passed() bool {
+	return r.Failure == ""
+}
+
+// Find lists the test files among paths: files named directly, and those
+// named *_test.tg in directories, searched recursively
+func Find(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && (file == path || strings.HasSuffix(file, FileSuffix)) {
+				files = append(files, file)
+			}
+			return nil
+		})
+		if err != nil {
+			return files, err
+		}
+	}
+	return files, nil
+}
+
+// Tests lists the test functions declared at the top level of src, in
+// source order
+func Tests(src string) ([]Test, error) {
+	parse := parser.New(lexer.New(src))
+	program := parse.ParseProgram()
+	if len(parse.Errors()) > 0 {
+		return nil, parse.ErrorList()
+	}
+	var tests []Test
+	for _, stmt := range program.Statements {
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok || !strings.HasPrefix(let.Name.Value, Prefix) {
+			continue
+		}
+		if fn, ok := let.Value.(*ast.FunctionLiteral); ok {
+			tests = append(tests, Test{Name: let.Name.Value, Line: fn.Line, params: len(fn.Parameters)})
+		}
+	}
+	return tests, nil
+}
+
+// Runner runs the tests of files
+type Runner struct {
+	// Match, when set, selects the tests whose names it matches
+	Match *regexp.Regexp
+	// Timeout bounds each test; zero means no limit
+	Timeout time.Duration
+	// Hooks, when set, watch every test as it runs
+	Hooks *eval.Hooks
+}
+
+// RunFile runs the tests of the file called name, whose source is src.
+// The file's top level runs again before each test, in a fresh
+// interpreter.
+func (r *Runner) RunFile(name, src string) ([]*Result, error) {
+	tests, err := Tests(src)
+	if err != nil {
+		return nil, err
+	}
+	var results []*Result
+	for _, test := range tests {
+		if r.Match != nil && !r.Match.MatchString(test.Name) {
+			continue
+		}
+		results = append(results, r.run(name, src, test))
+	}
+	return results, nil
+}
+
+func (r *Runner) run(file, src string, test Test) *Result {
+	result := &Result{File: file, Name: test.Name, Line: test.Line}
+	if test.params > 0 {
+		result.Failure = fmt.Sprintf("test functions take no arguments, %s takes %d", test.Name, test.params)
+		return result
+	}
+
+	var out bytes.Buffer
+	interp := tiger.New()
+	interp.Stdout = &out
+	interp.Timeout = r.Timeout
+	interp.Hooks = r.Hooks
+
+	start := time.Now()
+	if _, err := interp.Run(src); err != nil {
+		result.Failure = "setting up the file failed: " + err.Error()
+	} else if _, err := interp.Call(test.Name); err != nil {
+		result.Failure = err.Error()
+	}
+	result.Duration = time.Since(start)
+	result.Output = out.String()
+	return result
+}
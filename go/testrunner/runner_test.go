@@ -0,0 +1,102 @@
+package testrunner_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"tiger/go/testrunner"
+	"time"
+)
+
+const file = `let calls = 0
+
+func test_passes() {
+    calls = calls + 1
+    assertEqual(calls, 1)
+}
+
+func test_fails() {
+    print "debug"
+    assertEqual(1 + 1, 3, "sum")
+}
+
+func test_throws() {
+    throw "boom"
+}
+
+func test_arguments(x) {}
+
+func test_loops() {
+    while (true) {}
+}
+
+func helper() {}
+`
+
+func TestRunFile(t *testing.T) {
+	tests := []struct {
+		name  string
+		match string
+		want  []string // name, then the start of the failure or "ok"
+	}{
+		{
+			name: "all",
+			want: []string{
+				"test_passes ok",
+				"test_fails AssertionError: sum: values are not equal\n  expected: 3\n  actual:   2",
+				"test_throws boom",
+				"test_arguments test functions take no arguments, test_arguments takes 1",
+				"test_loops execution limit exceeded",
+			},
+		},
+		{
+			name:  "matched",
+			match: "pass|throw",
+			want:  []string{"test_passes ok", "test_throws boom"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &testrunner.Runner{Timeout: 50 * time.Millisecond}
+			if tt.match != "" {
+				r.Match = regexp.MustCompile(tt.match)
+			}
+			results, err := r.RunFile("a_test.tg", file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != len(tt.want) {
+				t.Fatalf("%d results, want %d", len(results), len(tt.want))
+			}
+			for i, result := range results {
+				name, failure, _ := strings.Cut(tt.want[i], " ")
+				if result.Name != name {
+					t.Errorf("result %d is %s, want %s", i, result.Name, name)
+				}
+				if failure == "ok" {
+					if !result.Passed() {
+						t.Errorf("%s failed: %s", name, result.Failure)
+					}
+				} else if !strings.Contains(result.Failure, failure) {
+					t.Errorf("%s: failure %q, want %q", name, result.Failure, failure)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	results := []*testrunner.Result{
+		{File: "a_test.tg", Name: "test_ok", Line: 1},
+		{File: "a_test.tg", Name: "test_bad", Line: 5, Failure: "boom\nat line 6", Output: "debug\n"},
+	}
+	var out strings.Builder
+	testrunner.WriteText(&out, results, false)
+	want := "--- FAIL: test_bad (a_test.tg:5, 0.000s)\n" +
+		"    boom\n    at line 6\n" +
+		"    output:\n      debug\n" +
+		"FAIL: 1 of 2 tests failed (0.000s)\n"
+	if out.String() != want {
+		t.Errorf("report:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
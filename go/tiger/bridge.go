@@ -0,0 +1,484 @@
+package tiger
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"tiger/go/eval"
+	"tiger/go/object"
+	"unicode"
+	"unicode/utf8"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// GoObject exposes a Go struct to Tiger. Exported fields are readable and
+// assignable as obj.Field and exported methods callable as obj.Method().
+// Members may also be named by a `tiger:"name"` field tag or with the
+// first letter in lower case, as in obj.name for Name.
+//
+// Structs passed by value are copied; pass a pointer to let scripts
+// modify the original.
+type GoObject struct {
+	value reflect.Value // always a non-nil pointer to a struct
+}
+
+func (g *GoObject) Type() object.ObjectType { return object.GO_OBJ }
+func (g *GoObject) Inspect() string         { return fmt.Sprintf("%+v", g.value.Elem().Interface()) }
+
+// Interface returns the wrapped Go pointer
+func (g *GoObject) Interface() interface{} { return g.value.Interface() }
+
+func (g *GoObject) GetMember(name string) (object.Object, bool) {
+	if field, ok := findField(g.value.Elem().Type(), name); ok {
+		obj, err := fromReflect(g.value.Elem().FieldByIndex(field.Index))
+		if err != nil {
+			return &object.Error{Message: "error: " + err.Error()}, true
+		}
+		return obj, true
+	}
+	if method, ok := findMethod(g.value, name); ok {
+		return wrapFunc(g.value.Elem().Type().Name()+"."+name, method), true
+	}
+	return nil, false
+}
+
+func (g *GoObject) SetMember(name string, val object.Object) error {
+	field, ok := findField(g.value.Elem().Type(), name)
+	if !ok {
+		return fmt.Errorf("%s has no field %s", g.value.Elem().Type(), name)
+	}
+	goVal, err := toGo(val, field.Type)
+	if err != nil {
+		return fmt.Errorf("field %s: %s", field.Name, err)
+	}
+	g.value.Elem().FieldByIndex(field.Index).Set(goVal)
+	return nil
+}
+
+func findField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		if tag := field.Tag.Get("tiger"); tag != "" {
+			if tag == name {
+				return field, true
+			}
+			continue
+		}
+		if field.Name == name || lowerFirst(field.Name) == name {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func findMethod(v reflect.Value, name string) (reflect.Value, bool) {
+	if method := v.MethodByName(name); method.IsValid() {
+		return method, true
+	}
+	if method := v.MethodByName(upperFirst(name)); method.IsValid() {
+		return method, true
+	}
+	return reflect.Value{}, false
+}
+
+func lowerFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[size:]
+}
+
+func upperFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+// wrapFunc turns a Go func into a builtin. Arguments are converted to the
+// parameter types; a non-nil trailing error result is raised as a Tiger
+// error, and several remaining results are returned as an array.
+func wrapFunc(name string, fn reflect.Value) *object.Builtin {
+	t := fn.Type()
+	minArgs, maxArgs := t.NumIn(), t.NumIn()
+	if t.IsVariadic() {
+		minArgs, maxArgs = t.NumIn()-1, eval.Variadic
+	}
+
+	return eval.NewBuiltin(name, minArgs, maxArgs, func(args ...object.Object) (result object.Object, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				result, err = nil, fmt.Errorf("panic: %v", r)
+			}
+		}()
+
+		in := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			paramType := t.In(min(i, t.NumIn()-1))
+			if t.IsVariadic() && i >= t.NumIn()-1 {
+				paramType = paramType.Elem()
+			}
+			val, err := toGo(arg, paramType)
+			if err != nil {
+				return nil, fmt.Errorf("argument %d: %s", i+1, err)
+			}
+			in[i] = val
+		}
+
+		out := fn.Call(in)
+		if len(out) > 0 && t.Out(len(out)-1) == errorType {
+			if errVal := out[len(out)-1]; !errVal.IsNil() {
+				return nil, errVal.Interface().(error)
+			}
+			out = out[:len(out)-1]
+		}
+
+		switch len(out) {
+		case 0:
+			return object.NULL, nil
+		case 1:
+			return fromReflect(out[0])
+		}
+		elements := make([]object.Object, len(out))
+		for i, val := range out {
+			obj, err := fromReflect(val)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = obj
+		}
+		return &object.Array{Elements: elements}, nil
+	})
+}
+
+// fromReflect converts any Go value to a Tiger value
+func fromReflect(v reflect.Value) (object.Object, error) {
+	return convert(v, map[visit]bool{})
+}
+
+// visit identifies a map, slice or pointer being converted, so that a
+// value containing itself is reported rather than converted forever.
+// Slices of one array differ by length.
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+func convert(v reflect.Value, visiting map[visit]bool) (object.Object, error) {
+	if !v.IsValid() {
+		return object.NULL, nil
+	}
+	if v.CanInterface() && !isNil(v) {
+		switch val := v.Interface().(type) {
+		case object.Object:
+			return val, nil
+		case error:
+			// A Go error held as a value takes the shape catch gives a
+			// raised one, so both read as e.message
+			m := object.NewMap()
+			m.Set("message", &object.String{Value: val.Error()})
+			return m, nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Pointer:
+		if !v.IsNil() && (v.Kind() != reflect.Slice || v.Len() > 0) {
+			key := visit{v.Pointer(), v.Type(), 0}
+			if v.Kind() == reflect.Slice {
+				key.len = v.Len()
+			}
+			if visiting[key] {
+				return nil, fmt.Errorf("tiger: cannot convert cyclic %s to a Tiger value", v.Type())
+			}
+			visiting[key] = true
+			defer delete(visiting, key)
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return object.NativeBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("tiger: %s value %d overflows a Tiger integer", v.Type(), v.Uint())
+		}
+		return &object.Integer{Value: int64(v.Uint())}, nil
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return object.NULL, nil
+		}
+		elements := make([]object.Object, v.Len())
+		for i := range elements {
+			obj, err := convert(v.Index(i), visiting)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = obj
+		}
+		return &object.Array{Elements: elements}, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return object.NULL, nil
+		}
+		// Go maps are unordered, so keys are sorted for a stable order
+		keys := make([]string, 0, v.Len())
+		values := make(map[string]reflect.Value, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := fmt.Sprint(iter.Key().Interface())
+			keys = append(keys, key)
+			values[key] = iter.Value()
+		}
+		sort.Strings(keys)
+		m := object.NewMap()
+		for _, key := range keys {
+			obj, err := convert(values[key], visiting)
+			if err != nil {
+				return nil, err
+			}
+			m.Set(key, obj)
+		}
+		return m, nil
+	case reflect.Pointer:
+		if v.IsNil() {
+			return object.NULL, nil
+		}
+		if v.Elem().Kind() == reflect.Struct {
+			return &GoObject{value: v}, nil
+		}
+		return convert(v.Elem(), visiting)
+	case reflect.Interface:
+		if v.IsNil() {
+			return object.NULL, nil
+		}
+		return convert(v.Elem(), visiting)
+	case reflect.Struct:
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+		return &GoObject{value: ptr}, nil
+	case reflect.Func:
+		if v.IsNil() {
+			return object.NULL, nil
+		}
+		return wrapFunc("native", v), nil
+	}
+	return nil, fmt.Errorf("tiger: cannot convert %s to a Tiger value", v.Type())
+}
+
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	}
+	return false
+}
+
+// toGo converts a Tiger value to the Go type t
+func toGo(obj object.Object, t reflect.Type) (reflect.Value, error) {
+	if reflect.TypeOf(obj).AssignableTo(t) {
+		return reflect.ValueOf(obj), nil
+	}
+	if g, ok := obj.(*GoObject); ok {
+		if g.value.Type().AssignableTo(t) {
+			return g.value, nil
+		}
+		if g.value.Elem().Type().AssignableTo(t) {
+			return g.value.Elem(), nil
+		}
+	}
+	if obj == object.NULL {
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map, reflect.Func:
+			return reflect.Zero(t), nil
+		}
+	}
+
+	mismatch := func() (reflect.Value, error) {
+		return reflect.Value{}, fmt.Errorf("cannot use %s as %s", obj.Type(), t)
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			return reflect.ValueOf(FromObject(obj)), nil
+		}
+	case reflect.Bool:
+		if b, ok := obj.(*object.Boolean); ok {
+			return reflect.ValueOf(b.Value).Convert(t), nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := toInt(obj)
+		if !ok || reflect.Zero(t).OverflowInt(n) {
+			return mismatch()
+		}
+		return reflect.ValueOf(n).Convert(t), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, ok := toInt(obj)
+		if !ok || n < 0 || reflect.Zero(t).OverflowUint(uint64(n)) {
+			return mismatch()
+		}
+		return reflect.ValueOf(uint64(n)).Convert(t), nil
+	case reflect.Float32, reflect.Float64:
+		switch num := obj.(type) {
+		case *object.Integer:
+			return reflect.ValueOf(float64(num.Value)).Convert(t), nil
+		case *object.Float:
+			return reflect.ValueOf(num.Value).Convert(t), nil
+		}
+	case reflect.String:
+		if s, ok := obj.(*object.String); ok {
+			return reflect.ValueOf(s.Value).Convert(t), nil
+		}
+	case reflect.Slice:
+		arr, ok := obj.(*object.Array)
+		if !ok {
+			return mismatch()
+		}
+		slice := reflect.MakeSlice(t, len(arr.Elements), len(arr.Elements))
+		for i, el := range arr.Elements {
+			val, err := toGo(el, t.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("element %d: %s", i, err)
+			}
+			slice.Index(i).Set(val)
+		}
+		return slice, nil
+	case reflect.Array:
+		arr, ok := obj.(*object.Array)
+		if !ok || len(arr.Elements) != t.Len() {
+			return mismatch()
+		}
+		array := reflect.New(t).Elem()
+		for i, el := range arr.Elements {
+			val, err := toGo(el, t.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("element %d: %s", i, err)
+			}
+			array.Index(i).Set(val)
+		}
+		return array, nil
+	case reflect.Map:
+		m, ok := obj.(*object.Map)
+		if !ok || t.Key().Kind() != reflect.String {
+			return mismatch()
+		}
+		goMap := reflect.MakeMapWithSize(t, len(m.Keys))
+		for _, key := range m.Keys {
+			val, err := toGo(m.Pairs[key], t.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("key %q: %s", key, err)
+			}
+			goMap.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), val)
+		}
+		return goMap, nil
+	case reflect.Pointer:
+		val, err := toGo(obj, t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ptr := reflect.New(t.Elem())
+		ptr.Elem().Set(val)
+		return ptr, nil
+	case reflect.Struct:
+		m, ok := obj.(*object.Map)
+		if !ok {
+			return mismatch()
+		}
+		st := reflect.New(t).Elem()
+		for _, key := range m.Keys {
+			field, ok := findField(t, key)
+			if !ok {
+				return reflect.Value{}, fmt.Errorf("%s has no field %s", t, key)
+			}
+			val, err := toGo(m.Pairs[key], field.Type)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("field %s: %s", field.Name, err)
+			}
+			st.FieldByIndex(field.Index).Set(val)
+		}
+		return st, nil
+	case reflect.Func:
+		switch obj.Type() {
+		case object.FUNCTION_OBJ, object.BUILTIN_OBJ, object.CLASS_OBJ:
+			return makeFunc(obj, t), nil
+		}
+	}
+	return mismatch()
+}
+
+func toInt(obj object.Object) (int64, bool) {
+	switch num := obj.(type) {
+	case *object.Integer:
+		return num.Value, true
+	case *object.Float:
+		if num.Value == float64(int64(num.Value)) {
+			return int64(num.Value), true
+		}
+	}
+	return 0, false
+}
+
+// makeFunc builds a Go func of type t that calls a Tiger function, so
+// scripts can hand callbacks to Go code. A Tiger error is returned
+// through a trailing error result, or panics if t has none.
+func makeFunc(fn object.Object, t reflect.Type) reflect.Value {
+	return reflect.MakeFunc(t, func(in []reflect.Value) []reflect.Value {
+		out := make([]reflect.Value, t.NumOut())
+		for i := range out {
+			out[i] = reflect.Zero(t.Out(i))
+		}
+		fail := func(err error) []reflect.Value {
+			if t.NumOut() > 0 && t.Out(t.NumOut()-1) == errorType {
+				out[t.NumOut()-1] = reflect.ValueOf(&err).Elem()
+				return out
+			}
+			panic(err)
+		}
+
+		args := make([]object.Object, len(in))
+		for i, val := range in {
+			obj, err := fromReflect(val)
+			if err != nil {
+				return fail(err)
+			}
+			args[i] = obj
+		}
+		result := eval.ApplyFunction(fn, args)
+		if errObj, ok := result.(*object.Error); ok {
+			return fail(&RuntimeError{Message: errObj.Message})
+		}
+
+		if t.NumOut() > 0 && t.Out(0) != errorType {
+			val, err := toGo(result, t.Out(0))
+			if err != nil {
+				return fail(fmt.Errorf("callback result: %s", err))
+			}
+			out[0] = val
+		}
+		return out
+	})
+}
+
+// Decode converts a Tiger value into the Go variable target points to,
+// using the same rules as arguments passed to bridged Go functions.
+func Decode(obj object.Object, target interface{}) error {
+	ptr := reflect.ValueOf(target)
+	if ptr.Kind() != reflect.Pointer || ptr.IsNil() {
+		return fmt.Errorf("tiger: Decode needs a non-nil pointer, got %T", target)
+	}
+	val, err := toGo(obj, ptr.Elem().Type())
+	if err != nil {
+		return fmt.Errorf("tiger: %s", strings.TrimPrefix(err.Error(), "tiger: "))
+	}
+	ptr.Elem().Set(val)
+	return nil
+}
@@ -0,0 +1,191 @@
+package tiger_test
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"tiger/go/object"
+	"tiger/go/tiger"
+)
+
+type account struct {
+	Name string
+	Err  error
+}
+
+type loop *loop
+
+type user struct {
+	Name   string
+	Email  string `tiger:"mail"`
+	Age    int
+	Tags   []string
+	secret string
+}
+
+func (u *user) Greet(greeting string) string { return greeting + ", " + u.Name }
+
+func (u *user) Save() error {
+	if u.Name == "" {
+		return errors.New("name required")
+	}
+	return nil
+}
+
+func (u *user) Parts() (string, int, error) { return u.Name, u.Age, nil }
+
+// Count returns how many tags keep accepts
+func (u *user) Count(keep func(string) bool) int {
+	n := 0
+	for _, tag := range u.Tags {
+		if keep(tag) {
+			n++
+		}
+	}
+	return n
+}
+
+// Visit passes each tag to visit, stopping at its first error
+func (u *user) Visit(visit func(string) error) error {
+	for _, tag := range u.Tags {
+		if err := visit(tag); err != nil {
+			return fmt.Errorf("visiting %s: %w", tag, err)
+		}
+	}
+	return nil
+}
+
+func TestSet(t *testing.T) {
+	cyclicMap := map[string]interface{}{}
+	cyclicMap["self"] = cyclicMap
+	cyclicSlice := []interface{}{nil}
+	cyclicSlice[0] = cyclicSlice
+	cyclicPointer := new(loop)
+	*cyclicPointer = cyclicPointer
+	shared := []int{1}
+
+	tests := []struct {
+		name  string
+		value interface{}
+		src   string // prints the value bound to v
+		want  string // printed, or the error of Set
+	}{
+		{"error", errors.New("boom"), "print v.message", "boom\n"},
+		{"error field", &account{Name: "a", Err: errors.New("closed")}, "print v.err.message", "closed\n"},
+		{"nil error field", &account{Name: "a"}, "print v.err", "null\n"},
+		{"errors in a slice", []error{errors.New("x"), nil}, "print v", "[{\"message\": \"x\"}, null]\n"},
+		{"largest uint64", uint64(math.MaxInt64), "print v", "9223372036854775807\n"},
+		{"uint64 overflow", uint64(math.MaxInt64) + 1, "", "tiger: uint64 value 9223372036854775808 overflows a Tiger integer"},
+		{"shared slice", map[string][]int{"a": shared, "b": shared}, "print v", "{\"a\": [1], \"b\": [1]}\n"},
+		{"cyclic map", cyclicMap, "", "tiger: cannot convert cyclic map[string]interface {} to a Tiger value"},
+		{"cyclic slice", cyclicSlice, "", "tiger: cannot convert cyclic []interface {} to a Tiger value"},
+		{"cyclic pointer", cyclicPointer, "", "tiger: cannot convert cyclic tiger_test.loop to a Tiger value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			interp := tiger.New()
+			interp.Stdout = &out
+			if err := interp.Set("v", tt.value); err != nil {
+				if err.Error() != tt.want {
+					t.Errorf("Set: %v, want %s", err, tt.want)
+				}
+				return
+			}
+			if _, err := interp.Run(tt.src); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("printed %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestSetNamesACopy(t *testing.T) {
+	builtin, err := tiger.ToObject(func(args ...object.Object) (object.Object, error) {
+		return object.NULL, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	interp := tiger.New()
+	interp.Set("a", builtin)
+	interp.Set("b", builtin)
+	for _, name := range []string{"a", "b"} {
+		obj, _ := interp.Get(name)
+		if got := obj.Inspect(); got != "[builtin "+name+"]" {
+			t.Errorf("%s is %s", name, got)
+		}
+	}
+	if got := builtin.Inspect(); got != "[builtin native]" {
+		t.Errorf("the shared builtin became %s", got)
+	}
+}
+
+func TestGoObject(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string // printed, or the error of Run
+	}{
+		{"fields", "print u.Name + \" \" + u.name + \" \" + u.age", "Ann Ann 30\n"},
+		{"tag", "print u.mail", "ann@example.com\n"},
+		{"tag hides the field name", "print u.Email", "error: GO_OBJECT has no member Email"},
+		{"unexported field", "print u.secret", "error: GO_OBJECT has no member secret"},
+		{"slice field", "print u.tags", "[\"a\", \"bb\"]\n"},
+		{"assign", "u.age = 31; u.mail = \"x\"; print [u.age, u.mail]", "[31, \"x\"]\n"},
+		{"assign converts elements", "u.tags = [\"c\"]; print u.tags", "[\"c\"]\n"},
+		{"assign mismatch", "u.age = \"old\"", "error: field Age: cannot use STRING as int"},
+		{"assign element mismatch", "u.tags = [1]", "error: field Tags: element 0: cannot use INTEGER as string"},
+		{"assign unknown field", "u.nickname = \"A\"", "error: tiger_test.user has no field nickname"},
+		{"method", "print u.greet(\"Hi\")", "Hi, Ann\n"},
+		{"bound method", "let g = u.Greet; print g(\"Hey\")", "Hey, Ann\n"},
+		{"method arguments", "u.greet(1)", "error: user.greet: argument 1: cannot use INTEGER as string"},
+		{"several results", "print u.parts()", "[\"Ann\", 30]\n"},
+		{"nil error", "print u.save()", "null\n"},
+		{"method error", "u.name = \"\"; u.save()", "error: user.save: name required"},
+		{"method error is catchable", "u.name = \"\"\ntry { u.save() } catch (e) { print e.message }", "error: user.save: name required\n"},
+		{"callback", "print u.count(func(tag) { return len(tag) > 1 })", "1\n"},
+		{"callback error", "u.visit(func(tag) { throw \"bad \" + tag })", "error: user.visit: visiting a: bad a"},
+		{"callback result mismatch", "u.count(func(tag) { return 1 })", "error: user.count: panic: callback result: cannot use INTEGER as bool"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			interp := tiger.New()
+			interp.Stdout = &out
+			u := &user{Name: "Ann", Email: "ann@example.com", Age: 30, Tags: []string{"a", "bb"}}
+			if err := interp.Set("u", u); err != nil {
+				t.Fatal(err)
+			}
+			got := ""
+			if _, err := interp.Run(tt.src); err != nil {
+				got = err.Error()
+			} else {
+				got = out.String()
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGoObjectSharesPointer(t *testing.T) {
+	interp := tiger.New()
+	u := &user{Name: "Ann"}
+	interp.Set("u", u)
+	if _, err := interp.Run("u.name = \"Bob\"; u.tags = [\"x\"]"); err != nil {
+		t.Fatal(err)
+	}
+	if u.Name != "Bob" || len(u.Tags) != 1 {
+		t.Errorf("assignments did not reach the Go value: %+v", u)
+	}
+	val, _ := interp.Get("u")
+	if tiger.FromObject(val) != u {
+		t.Error("FromObject did not return the original pointer")
+	}
+}
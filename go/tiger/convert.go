@@ -0,0 +1,58 @@
+package tiger
+
+import (
+	"reflect"
+	"tiger/go/eval"
+	"tiger/go/object"
+)
+
+// ToObject converts a Go value to its Tiger equivalent. Objects pass
+// through unchanged; numbers, strings, slices, maps, structs and funcs
+// are bridged by reflection, see GoObject, and errors become a map with
+// their message, as catch binds them. Cyclic values and unsigned
+// integers beyond int64 are errors.
+func ToObject(value interface{}) (object.Object, error) {
+	switch v := value.(type) {
+	case nil:
+		return object.NULL, nil
+	case object.Object:
+		return v, nil
+	case func(args ...object.Object) (object.Object, error):
+		return eval.NewBuiltin("native", 0, eval.Variadic, v), nil
+	}
+	return fromReflect(reflect.ValueOf(value))
+}
+
+// FromObject converts a Tiger value to plain Go data: nil, bool, int64,
+// float64, string, []interface{} and map[string]interface{}. Bridged Go
+// structs yield the original pointer; functions, classes and instances
+// are returned as the object itself.
+func FromObject(obj object.Object) interface{} {
+	switch obj := obj.(type) {
+	case *object.Null:
+		return nil
+	case *object.Boolean:
+		return obj.Value
+	case *object.Integer:
+		return obj.Value
+	case *object.Float:
+		return obj.Value
+	case *object.String:
+		return obj.Value
+	case *object.Array:
+		out := make([]interface{}, len(obj.Elements))
+		for i, el := range obj.Elements {
+			out[i] = FromObject(el)
+		}
+		return out
+	case *object.Map:
+		out := make(map[string]interface{}, len(obj.Keys))
+		for _, key := range obj.Keys {
+			out[key] = FromObject(obj.Pairs[key])
+		}
+		return out
+	case *GoObject:
+		return obj.Interface()
+	}
+	return obj
+}
@@ -0,0 +1,282 @@
+// Package tiger embeds the Tiger interpreter in Go programs.
+//
+//	interp := tiger.New()
+//	interp.Set("name", "Tiger")
+//	if _, err := interp.Run(`print "Hello " + name;`); err != nil {
+//		log.Fatal(err)
+//	}
+package tiger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"tiger/go/eval"
+	"tiger/go/lexer"
+	"tiger/go/object"
+	"tiger/go/optimizer"
+	"tiger/go/parser"
+	"tiger/go/resolver"
+	"tiger/go/types"
+	"time"
+
+	// The standard builtins register themselves on import
+	_ "tiger/go/stdlib"
+)
+
+// Interpreter runs Tiger code against a single global environment, so
+// definitions made by one Run are visible to the next. Printed output is
+// written to Stdout as the script runs.
+type Interpreter struct {
+	// Stdout receives everything the script prints. Nil discards it.
+	Stdout io.Writer
+	// Stderr receives the message of every error returned by Run,
+	// RunFile and Call. Nil leaves reporting to the caller.
+	Stderr io.Writer
+
+	// Timeout bounds each Run, RunFile and Call; zero means no timeout
+	Timeout time.Duration
+	// MaxSteps bounds the loop iterations plus function calls of each
+	// Run, RunFile and Call; zero means no limit
+	MaxSteps int64
+	// MaxDepth bounds how deeply Tiger functions may call each other;
+	// zero means eval.DefaultMaxDepth
+	MaxDepth int
+	// MaxMemory bounds, in bytes, the strings, arrays, maps and scopes
+	// a script may hold; zero means no limit
+	MaxMemory int64
+
+	// Optimize folds constants and removes dead code before each Run
+	Optimize bool
+	// Check type-checks each script before it runs, refusing to run
+	// one with type errors
+	Check bool
+
+	// Hooks, when set, watch every Run, RunFile and Call as it executes
+	Hooks *eval.Hooks
+
+	env *eval.Environment
+}
+
+// New returns an interpreter printing to os.Stdout
+func New() *Interpreter {
+	return &Interpreter{
+		Stdout: os.Stdout,
+		env:    eval.NewEnvironment(),
+	}
+}
+
+// ParseError holds the syntax errors that stopped a script from running
+type ParseError struct {
+	Errors []*parser.Error
+}
+
+func (e *ParseError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for n, err := range e.Errors {
+		messages[n] = err.Error()
+	}
+	return "parse error: " + strings.Join(messages, "; ")
+}
+
+// ResolveError holds the names that could not be bound before a script
+// ran: variables used before their declaration or declared nowhere
+type ResolveError struct {
+	Errors []*resolver.Error
+}
+
+func (e *ResolveError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for n, err := range e.Errors {
+		messages[n] = err.Error()
+	}
+	return "resolve error: " + strings.Join(messages, "; ")
+}
+
+// TypeError holds the type errors that stopped a script from running
+// when Check is set
+type TypeError struct {
+	Errors []*types.Error
+}
+
+func (e *TypeError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for n, err := range e.Errors {
+		messages[n] = err.Error()
+	}
+	return "type error: " + strings.Join(messages, "; ")
+}
+
+// RuntimeError is an error raised while evaluating a script. Kind and
+// Stack are set for errors such as StackOverflow that record them.
+type RuntimeError struct {
+	Message string
+	Kind    string
+	Stack   []string
+}
+
+func (e *RuntimeError) Error() string {
+	if len(e.Stack) == 0 {
+		return e.Message
+	}
+	return e.Message + "\n\t" + strings.Join(e.Stack, "\n\t")
+}
+
+// LimitError reports a script stopped by Timeout, MaxSteps, MaxMemory or
+// the cancellation of the context passed to RunContext
+type LimitError struct {
+	Message string
+}
+
+func (e *LimitError) Error() string {
+	return e.Message
+}
+
+// Run parses and evaluates src, returning the value of the last
+// statement
+func (i *Interpreter) Run(src string) (object.Object, error) {
+	return i.RunContext(context.Background(), src)
+}
+
+// RunContext is Run stopping with a *LimitError when ctx is done
+func (i *Interpreter) RunContext(ctx context.Context, src string) (object.Object, error) {
+	l := lexer.New(src)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return nil, i.report(&ParseError{Errors: p.ErrorList()})
+	}
+	if i.Check {
+		if errs := types.Check(program); len(errs) > 0 {
+			return nil, i.report(&TypeError{Errors: errs})
+		}
+	}
+	if i.Optimize {
+		optimizer.Optimize(program)
+	}
+	if errs := resolver.Resolve(program, i.defined); len(errs) > 0 {
+		return nil, i.report(&ResolveError{Errors: errs})
+	}
+	ctx, cancel := i.prepare(ctx)
+	defer cancel()
+	return i.result(eval.EvalContext(ctx, program, i.env))
+}
+
+// RunFile runs the Tiger source in path
+func (i *Interpreter) RunFile(path string) (object.Object, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, i.report(err)
+	}
+	return i.Run(string(content))
+}
+
+// prepare applies the output writer and limits before each evaluation
+func (i *Interpreter) prepare(ctx context.Context) (context.Context, context.CancelFunc) {
+	i.env.SetOutput(i.stdout())
+	i.env.SetMaxSteps(i.MaxSteps)
+	i.env.SetMaxDepth(i.MaxDepth)
+	i.env.SetMaxMemory(i.MaxMemory)
+	i.env.SetHooks(i.Hooks)
+	if i.Timeout > 0 {
+		return context.WithTimeout(ctx, i.Timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// Set binds name in the global environment to a Go value, converted
+// with ToObject
+func (i *Interpreter) Set(name string, value interface{}) error {
+	obj, err := ToObject(value)
+	if err != nil {
+		return err
+	}
+	// Bridged funcs take the binding's name for error messages. The
+	// builtin may be bound elsewhere too, so it is renamed in a copy.
+	if builtin, ok := obj.(*object.Builtin); ok && builtin.Name == "native" {
+		named := *builtin
+		named.Name = name
+		obj = &named
+	}
+	i.env.Set(name, obj)
+	return nil
+}
+
+// RegisterBuiltin defines a Go function for this interpreter only; use
+// eval.RegisterBuiltin to add one to every interpreter. Dotted names
+// such as "http.get" group builtins into modules.
+func (i *Interpreter) RegisterBuiltin(name string, minArgs, maxArgs int, fn object.BuiltinFunction) {
+	i.env.Define(name, eval.NewBuiltin(name, minArgs, maxArgs, fn))
+}
+
+// Get looks up a global binding
+func (i *Interpreter) Get(name string) (object.Object, bool) {
+	return i.env.Get(name)
+}
+
+// Globals returns the global bindings: the definitions of the scripts
+// run so far and the values bound with Set
+func (i *Interpreter) Globals() map[string]object.Object {
+	return i.env.Vars()
+}
+
+// Call invokes the Tiger function bound to fnName with args converted by
+// ToObject
+func (i *Interpreter) Call(fnName string, args ...interface{}) (object.Object, error) {
+	fn, ok := i.env.Get(fnName)
+	if !ok {
+		return nil, i.report(&RuntimeError{Message: "undefined function: " + fnName})
+	}
+	objects := make([]object.Object, len(args))
+	for n, arg := range args {
+		obj, err := ToObject(arg)
+		if err != nil {
+			return nil, i.report(err)
+		}
+		objects[n] = obj
+	}
+	ctx, cancel := i.prepare(context.Background())
+	defer cancel()
+	var result object.Object
+	eval.WithContext(ctx, i.env, func() {
+		result = eval.ApplyFunction(fn, objects)
+	})
+	return i.result(result)
+}
+
+// defined reports the globals a script may use without declaring them:
+// builtins, values from Set and definitions from earlier runs
+func (i *Interpreter) defined(name string) bool {
+	_, ok := i.env.Get(name)
+	return ok
+}
+
+func (i *Interpreter) stdout() io.Writer {
+	if i.Stdout == nil {
+		return io.Discard
+	}
+	return i.Stdout
+}
+
+func (i *Interpreter) result(obj object.Object) (object.Object, error) {
+	if errObj, ok := obj.(*object.Error); ok {
+		if errObj.Limit {
+			return nil, i.report(&LimitError{Message: errObj.Message})
+		}
+		return nil, i.report(&RuntimeError{
+			Message: errObj.Message,
+			Kind:    errObj.Kind,
+			Stack:   errObj.Stack,
+		})
+	}
+	return obj, nil
+}
+
+func (i *Interpreter) report(err error) error {
+	if i.Stderr != nil {
+		fmt.Fprintln(i.Stderr, err)
+	}
+	return err
+}
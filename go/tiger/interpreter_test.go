@@ -0,0 +1,174 @@
+package tiger_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"tiger/go/object"
+	"tiger/go/tiger"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string // Inspect of the value, or the error
+	}{
+		{"value of the last statement", "let a = [1, 2]\nlen(a) + 1", "3"},
+		{"null", "let a = 1", "null"},
+		{"parse error", "let x = \nprint 1 +", "parse error: line 2, column 1: unexpected \"print\"; line 2, column 10: unexpected end of input"},
+		{"resolve error", "print y", "resolve error: line 1, column 7: undefined variable: y"},
+		{"runtime error", "let x = 1 / 0", "division by zero"},
+		{"uncaught throw", `throw "boom"`, "boom"},
+		{"limit", "while (true) {}", "execution limit exceeded: step budget of 1000 exhausted"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interp := tiger.New()
+			interp.MaxSteps = 1000
+			val, err := interp.Run(tt.src)
+			got := ""
+			if err != nil {
+				got = err.Error()
+			} else {
+				got = val.Inspect()
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorTypes(t *testing.T) {
+	var stderr strings.Builder
+	interp := tiger.New()
+	interp.Stderr = &stderr
+	interp.MaxSteps = 1000
+	interp.MaxDepth = 50
+
+	_, err := interp.Run("let = 1")
+	var parse *tiger.ParseError
+	if !errors.As(err, &parse) || len(parse.Errors) == 0 {
+		t.Fatalf("got %v, want a ParseError", err)
+	}
+	if first := parse.Errors[0]; first.Line != 1 || first.Column != 5 {
+		t.Errorf("parse error at line %d, column %d, want line 1, column 5", first.Line, first.Column)
+	}
+
+	_, err = interp.Run("print a\nprint b")
+	var resolve *tiger.ResolveError
+	if !errors.As(err, &resolve) || len(resolve.Errors) != 2 || resolve.Errors[1].Line != 2 {
+		t.Errorf("got %v, want a ResolveError for a and b", err)
+	}
+
+	_, err = interp.Run("func f() { return f() }\nf()")
+	var runtime *tiger.RuntimeError
+	if !errors.As(err, &runtime) || runtime.Kind != "StackOverflow" || len(runtime.Stack) == 0 {
+		t.Errorf("got %v, want a StackOverflow RuntimeError with a stack", err)
+	}
+
+	_, err = interp.Run("try { while (true) {} } catch (e) { print e }")
+	var limit *tiger.LimitError
+	if !errors.As(err, &limit) {
+		t.Errorf("got %v, want a LimitError try cannot catch", err)
+	}
+
+	interp.Check = true
+	_, err = interp.Run(`let n: int = "a"`)
+	var typeErr *tiger.TypeError
+	if !errors.As(err, &typeErr) || len(typeErr.Errors) != 1 {
+		t.Errorf("got %v, want a TypeError", err)
+	}
+
+	if lines := strings.Count(stderr.String(), "\n"); lines < 5 {
+		t.Errorf("Stderr got %d lines, want every error reported:\n%s", lines, stderr.String())
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	var out strings.Builder
+	interp := tiger.New()
+	interp.Stdout = &out
+	file := filepath.Join(t.TempDir(), "a.tg")
+	if err := os.WriteFile(file, []byte("let greeting = \"hi\"\nprint greeting\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := interp.RunFile(file); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "hi\n" {
+		t.Errorf("printed %q", out.String())
+	}
+	if _, err := interp.RunFile(filepath.Join(t.TempDir(), "missing.tg")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got %v, want a not-exist error", err)
+	}
+}
+
+func TestGlobals(t *testing.T) {
+	interp := tiger.New()
+	if err := interp.Set("limit", 10); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := interp.Run("let total = limit * 2"); err != nil {
+		t.Fatal(err)
+	}
+	// Definitions persist into the next Run
+	if _, err := interp.Run("total = total + 1"); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := interp.Get("total"); !ok || v.Inspect() != "21" {
+		t.Errorf("Get(total) = %v, %v", v, ok)
+	}
+	if _, ok := interp.Get("missing"); ok {
+		t.Error("Get(missing) found a binding")
+	}
+	globals := interp.Globals()
+	for _, name := range []string{"limit", "total"} {
+		if _, ok := globals[name]; !ok {
+			t.Errorf("Globals() lacks %s", name)
+		}
+	}
+	// Separate interpreters do not share globals
+	if _, ok := tiger.New().Get("total"); ok {
+		t.Error("a new interpreter sees total")
+	}
+}
+
+func TestCall(t *testing.T) {
+	interp := tiger.New()
+	interp.MaxSteps = 1000
+	src := "func add(a, b) { return a + b }\nfunc fail() { throw \"no\" }\nfunc spin() { while (true) {} }"
+	if _, err := interp.Run(src); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		fn   string
+		args []interface{}
+		want string // Inspect of the result, or the error
+	}{
+		{"add", []interface{}{1, 2}, "3"},
+		{"add", []interface{}{"a", "b"}, "ab"},
+		{"add", []interface{}{1.5, int8(2)}, "3.500000"},
+		{"add", []interface{}{1, make(chan int)}, "tiger: cannot convert chan int to a Tiger value"},
+		{"fail", nil, "no"},
+		{"spin", nil, "execution limit exceeded: step budget of 1000 exhausted"},
+		{"missing", nil, "undefined function: missing"},
+	}
+	for _, tt := range tests {
+		val, err := interp.Call(tt.fn, tt.args...)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		} else if s, ok := val.(*object.String); ok {
+			got = s.Value
+		} else {
+			got = val.Inspect()
+		}
+		if got != tt.want {
+			t.Errorf("Call(%s, %v) = %s, want %s", tt.fn, tt.args, got, tt.want)
+		}
+	}
+}
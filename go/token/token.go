@@ -1,10 +1,14 @@
 package token
 
+import "sort"
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Line    int // 1-based line where the token starts
+	Column  int // 1-based column where the token starts
 }
 
 const (
@@ -23,6 +27,7 @@ const (
 	MINUS    = "-"
 	ASTERISK = "*"
 	SLASH    = "/"
+	BANG     = "!"
 
 	EQ     = "=="
 	NOT_EQ = "!="
@@ -34,10 +39,14 @@ const (
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
+	COLON     = ":"
+	DOT       = "."
 	LPAREN    = "("
 	RPAREN    = ")"
 	LBRACE    = "{"
 	RBRACE    = "}"
+	LBRACKET  = "["
+	RBRACKET  = "]"
 
 	// Keywords
 	LET   = "LET"
@@ -49,10 +58,14 @@ const (
 	FOR   = "FOR"
 	TRUE  = "TRUE"
 	FALSE = "FALSE"
+	NULL  = "NULL"
 
 	FUNC   = "FUNC"
 	CLASS  = "CLASS"
 	RETURN = "RETURN"
+	TRY    = "TRY"
+	CATCH  = "CATCH"
+	THROW  = "THROW"
 )
 
 var keywords = map[string]TokenType{
@@ -65,9 +78,13 @@ var keywords = map[string]TokenType{
 	"for":    FOR,
 	"true":   TRUE,
 	"false":  FALSE,
+	"null":   NULL,
 	"func":   FUNC,
 	"class":  CLASS,
 	"return": RETURN,
+	"try":    TRY,
+	"catch":  CATCH,
+	"throw":  THROW,
 }
 
 func LookupIdent(ident string) TokenType {
@@ -76,3 +93,13 @@ func LookupIdent(ident string) TokenType {
 	}
 	return IDENT
 }
+
+// Keywords returns the reserved words, sorted
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
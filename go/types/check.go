@@ -0,0 +1,468 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+	"tiger/go/ast"
+)
+
+// Error is a type error found before the program runs
+type Error struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+// binding is a declared name. Typ stays nil for a variable whose type
+// is not known yet because its declaration has not been reached.
+type binding struct {
+	typ   *Type
+	fn    *ast.FunctionLiteral
+	class *ast.ClassStatement
+}
+
+type scope struct {
+	vars  map[string]*binding
+	outer *scope
+}
+
+func (s *scope) lookup(name string) *binding {
+	for ; s != nil; s = s.outer {
+		if b, ok := s.vars[name]; ok {
+			return b
+		}
+	}
+	return nil
+}
+
+// function is the function being checked. returns is nil when its
+// return type is not annotated.
+type function struct {
+	returns *Type
+}
+
+type checker struct {
+	scope     *scope
+	functions []function
+	errors    []*Error
+
+	// signatures caches function types so that each annotation is
+	// reported at most once
+	signatures map[*ast.FunctionLiteral]*Type
+}
+
+// Check returns the type errors of program, in source order
+func Check(program *ast.Program) []*Error {
+	c := &checker{
+		scope:      &scope{vars: map[string]*binding{}},
+		signatures: map[*ast.FunctionLiteral]*Type{},
+	}
+	c.declare(program.Statements)
+	c.statements(program.Statements)
+	sort.SliceStable(c.errors, func(i, j int) bool {
+		a, b := c.errors[i], c.errors[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return c.errors
+}
+
+func (c *checker) errorf(line, column int, format string, a ...interface{}) {
+	c.errors = append(c.errors, &Error{Line: line, Column: column, Message: fmt.Sprintf(format, a...)})
+}
+
+// declare adds the names declared by stmts to the current scope.
+// Functions and classes get their types straight away, so they may be
+// called before their declaration.
+func (c *checker) declare(stmts []ast.Statement) {
+	var decl func(stmt ast.Statement)
+	add := func(name string) *binding {
+		b, ok := c.scope.vars[name]
+		if !ok {
+			b = &binding{}
+			c.scope.vars[name] = b
+		}
+		return b
+	}
+	decl = func(stmt ast.Statement) {
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			b := add(stmt.Name.Value)
+			if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok && fn.Name == stmt.Name.Value {
+				b.fn = fn
+			}
+		case *ast.ConstStatement:
+			add(stmt.Name.Value)
+		case *ast.ClassStatement:
+			add(stmt.Name.Value).class = stmt
+		case *ast.BlockStatement:
+			for _, inner := range stmt.Statements {
+				decl(inner)
+			}
+		case *ast.IfStatement:
+			decl(stmt.Consequence)
+			if stmt.Alternative != nil {
+				decl(stmt.Alternative)
+			}
+		case *ast.WhileStatement:
+			decl(stmt.Body)
+		case *ast.ForStatement:
+			if stmt.Init != nil {
+				decl(stmt.Init)
+			}
+			decl(stmt.Body)
+		case *ast.TryStatement:
+			decl(stmt.Block)
+			if stmt.Param != nil {
+				add(stmt.Param.Value).typ = AnyType
+			}
+			decl(stmt.Handler)
+		}
+	}
+	for _, stmt := range stmts {
+		decl(stmt)
+	}
+
+	// Signatures may name any class of the scope, so they are worked out
+	// once every name is known
+	for _, b := range c.scope.vars {
+		switch {
+		case b.fn != nil:
+			b.typ = c.signature(b.fn)
+		case b.class != nil:
+			b.typ = &Type{Kind: Class, Name: b.class.Name.Value}
+			for _, method := range b.class.Methods {
+				if method.Name == "init" {
+					b.typ.Init = c.signature(method)
+				}
+			}
+		}
+	}
+}
+
+// annotation returns the type an annotation names
+func (c *checker) annotation(ta *ast.TypeAnnotation) *Type {
+	if ta == nil {
+		return nil
+	}
+	if typ, ok := named[ta.Name]; ok {
+		return typ
+	}
+	if b := c.scope.lookup(ta.Name); b != nil && (b.class != nil || b.typ != nil && b.typ.Kind == Class) {
+		return &Type{Kind: Instance, Name: ta.Name}
+	}
+	c.errorf(ta.Line, ta.Column, "unknown type %s", ta.Name)
+	return AnyType
+}
+
+func (c *checker) signature(fn *ast.FunctionLiteral) *Type {
+	if typ, ok := c.signatures[fn]; ok {
+		return typ
+	}
+	typ := &Type{Kind: Func, Params: make([]*Type, len(fn.Parameters)), Return: AnyType}
+	for i, param := range fn.Parameters {
+		typ.Params[i] = AnyType
+		if param.Type != nil {
+			typ.Params[i] = c.annotation(param.Type)
+		}
+	}
+	if fn.ReturnType != nil {
+		typ.Return = c.annotation(fn.ReturnType)
+	}
+	c.signatures[fn] = typ
+	return typ
+}
+
+func (c *checker) statements(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		c.statement(stmt)
+	}
+}
+
+func (c *checker) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		c.define(stmt.Name, stmt.Value)
+	case *ast.ConstStatement:
+		c.define(stmt.Name, stmt.Value)
+	case *ast.PrintStatement:
+		c.expression(stmt.Value)
+	case *ast.ExpressionStatement:
+		c.expression(stmt.Expression)
+	case *ast.ReturnStatement:
+		c.returnStatement(stmt)
+	case *ast.ThrowStatement:
+		c.expression(stmt.Value)
+	case *ast.BlockStatement:
+		c.statements(stmt.Statements)
+	case *ast.IfStatement:
+		c.expression(stmt.Condition)
+		c.statements(stmt.Consequence.Statements)
+		if stmt.Alternative != nil {
+			c.statements(stmt.Alternative.Statements)
+		}
+	case *ast.WhileStatement:
+		c.expression(stmt.Condition)
+		c.statements(stmt.Body.Statements)
+	case *ast.ForStatement:
+		if stmt.Init != nil {
+			c.statement(stmt.Init)
+		}
+		if stmt.Condition != nil {
+			c.expression(stmt.Condition)
+		}
+		if stmt.Update != nil {
+			c.statement(stmt.Update)
+		}
+		c.statements(stmt.Body.Statements)
+	case *ast.ClassStatement:
+		for _, method := range stmt.Methods {
+			c.function(method)
+		}
+	case *ast.TryStatement:
+		c.statements(stmt.Block.Statements)
+		c.statements(stmt.Handler.Statements)
+	}
+}
+
+// define checks a let or const. Annotated names keep their type;
+// others take the type of their value, except null, which says nothing
+// about the values to come.
+func (c *checker) define(name *ast.Identifier, value ast.Expression) {
+	b := c.scope.vars[name.Value]
+	if fn, ok := value.(*ast.FunctionLiteral); ok && b.fn == fn {
+		c.function(fn)
+		return
+	}
+	typ := c.expression(value)
+	if declared := c.annotation(name.Type); declared != nil {
+		if !typ.AssignableTo(declared) {
+			c.errorf(name.Line, name.Column, "cannot use %s as %s in declaration of %s", typ, declared, name.Value)
+		}
+		typ = declared
+	} else if typ.Kind == Null {
+		typ = AnyType
+	}
+	b.typ = typ
+}
+
+func (c *checker) returnStatement(stmt *ast.ReturnStatement) {
+	typ := NullType
+	if stmt.Value != nil {
+		typ = c.expression(stmt.Value)
+	}
+	if len(c.functions) == 0 {
+		return
+	}
+	want := c.functions[len(c.functions)-1].returns
+	if want != nil && !typ.AssignableTo(want) {
+		c.errorf(stmt.Line, stmt.Column, "cannot return %s from a function returning %s", typ, want)
+	}
+}
+
+// function checks the body of fn and returns its type
+func (c *checker) function(fn *ast.FunctionLiteral) *Type {
+	typ := c.signature(fn)
+	outer := c.scope
+	c.scope = &scope{vars: map[string]*binding{"this": {typ: AnyType}}, outer: outer}
+	for i, param := range fn.Parameters {
+		c.scope.vars[param.Value] = &binding{typ: typ.Params[i]}
+	}
+	c.declare(fn.Body.Statements)
+
+	var returns *Type
+	if fn.ReturnType != nil {
+		returns = typ.Return
+	}
+	c.functions = append(c.functions, function{returns: returns})
+	c.statements(fn.Body.Statements)
+	c.functions = c.functions[:len(c.functions)-1]
+
+	if returns != nil && !NullType.AssignableTo(returns) && !terminates(fn.Body.Statements) {
+		c.errorf(fn.Body.EndLine, fn.Body.EndColumn, "missing return at the end of a function returning %s", returns)
+	}
+	c.scope = outer
+	return typ
+}
+
+// terminates reports whether every path through stmts ends in a return
+// or throw
+func terminates(stmts []ast.Statement) bool {
+	if len(stmts) == 0 {
+		return false
+	}
+	switch stmt := stmts[len(stmts)-1].(type) {
+	case *ast.ReturnStatement, *ast.ThrowStatement:
+		return true
+	case *ast.BlockStatement:
+		return terminates(stmt.Statements)
+	case *ast.IfStatement:
+		return stmt.Alternative != nil && terminates(stmt.Consequence.Statements) && terminates(stmt.Alternative.Statements)
+	case *ast.TryStatement:
+		return terminates(stmt.Block.Statements) && terminates(stmt.Handler.Statements)
+	}
+	return false
+}
+
+func (c *checker) expression(expr ast.Expression) *Type {
+	switch expr := expr.(type) {
+	case *ast.IntegerLiteral:
+		return IntType
+	case *ast.FloatLiteral:
+		return FloatType
+	case *ast.StringLiteral:
+		return StringType
+	case *ast.Boolean:
+		return BoolType
+	case *ast.Null:
+		return NullType
+	case *ast.Identifier:
+		if b := c.scope.lookup(expr.Value); b != nil && b.typ != nil {
+			return b.typ
+		}
+	case *ast.PrefixExpression:
+		return c.prefix(expr)
+	case *ast.InfixExpression:
+		return c.infix(expr)
+	case *ast.ArrayLiteral:
+		for _, el := range expr.Elements {
+			c.expression(el)
+		}
+		return ArrayType
+	case *ast.MapLiteral:
+		for i, key := range expr.Keys {
+			c.expression(key)
+			c.expression(expr.Values[i])
+		}
+		return MapType
+	case *ast.IndexExpression:
+		left := c.expression(expr.Left)
+		c.expression(expr.Index)
+		if left.Kind == String {
+			return StringType
+		}
+	case *ast.MemberExpression:
+		c.expression(expr.Object)
+	case *ast.AssignExpression:
+		return c.assign(expr)
+	case *ast.CallExpression:
+		return c.call(expr)
+	case *ast.FunctionLiteral:
+		return c.function(expr)
+	}
+	return AnyType
+}
+
+func (c *checker) prefix(expr *ast.PrefixExpression) *Type {
+	right := c.expression(expr.Right)
+	if expr.Operator == "!" {
+		return BoolType
+	}
+	if right.numeric() || right.Kind == Any {
+		return right
+	}
+	c.errorf(expr.Line, expr.Column, "operator %s is not defined on %s", expr.Operator, right)
+	return AnyType
+}
+
+// infix follows eval.BinaryOp: numbers mix freely, + joins anything to
+// a string, and == compares any two values
+func (c *checker) infix(expr *ast.InfixExpression) *Type {
+	left := c.expression(expr.Left)
+	right := c.expression(expr.Right)
+	anyOperand := left.Kind == Any || right.Kind == Any
+
+	switch expr.Operator {
+	case "==", "!=":
+		return BoolType
+	case "<", "<=", ">", ">=":
+		if anyOperand || (left.numeric() && right.numeric()) || (left.Kind == String && right.Kind == String) {
+			return BoolType
+		}
+		c.errorf(expr.Line, expr.Column, "cannot compare %s and %s with %s", left, right, expr.Operator)
+		return BoolType
+	case "+":
+		if left.Kind == String || right.Kind == String {
+			return StringType
+		}
+	}
+
+	switch {
+	case left.numeric() && right.numeric():
+		if expr.Operator != "/" && left.Kind == Int && right.Kind == Int {
+			return IntType
+		}
+		return FloatType
+	case anyOperand && (left.numeric() || right.numeric() || expr.Operator != "+"):
+		if (left.Kind == Any || left.numeric()) && (right.Kind == Any || right.numeric()) {
+			return AnyType
+		}
+	case anyOperand:
+		return AnyType
+	}
+	c.errorf(expr.Line, expr.Column, "operator %s is not defined for %s and %s", expr.Operator, left, right)
+	return AnyType
+}
+
+func (c *checker) assign(expr *ast.AssignExpression) *Type {
+	typ := c.expression(expr.Value)
+	ident, ok := expr.Target.(*ast.Identifier)
+	if !ok {
+		c.expression(expr.Target)
+		return typ
+	}
+	if b := c.scope.lookup(ident.Value); b != nil && b.typ != nil && !typ.AssignableTo(b.typ) {
+		c.errorf(ident.Line, ident.Column, "cannot assign %s to %s of type %s", typ, ident.Value, b.typ)
+	}
+	return typ
+}
+
+func (c *checker) call(expr *ast.CallExpression) *Type {
+	callee := c.expression(expr.Function)
+	args := make([]*Type, len(expr.Arguments))
+	for i, arg := range expr.Arguments {
+		args[i] = c.expression(arg)
+	}
+
+	line, column := ast.Start(expr.Function)
+	name := "function"
+	if ident, ok := expr.Function.(*ast.Identifier); ok {
+		name = ident.Value
+	}
+
+	fn, result := callee, AnyType
+	switch callee.Kind {
+	case Func:
+		if callee.Return != nil {
+			result = callee.Return
+		}
+	case Class:
+		fn, result = callee.Init, &Type{Kind: Instance, Name: callee.Name}
+	default:
+		return AnyType
+	}
+	if fn == nil || fn.Params == nil {
+		return result
+	}
+
+	for i, param := range fn.Params {
+		if i >= len(args) {
+			// Missing arguments are null
+			if !NullType.AssignableTo(param) {
+				c.errorf(line, column, "%s needs %d arguments, got %d", name, len(fn.Params), len(args))
+			}
+			break
+		}
+		if !args[i].AssignableTo(param) {
+			line, column := ast.Start(expr.Arguments[i])
+			c.errorf(line, column, "cannot use %s as %s in argument %d of %s", args[i], param, i+1, name)
+		}
+	}
+	return result
+}
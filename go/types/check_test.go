@@ -0,0 +1,83 @@
+package types_test
+
+import (
+	"reflect"
+	"testing"
+	"tiger/go/lexer"
+	"tiger/go/parser"
+	"tiger/go/types"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			name: "well typed",
+			src:  "let x: int = 1\nlet y: float = x + 0.5\nlet s: string = \"n\" + x\n",
+		},
+		{
+			name: "declaration",
+			src:  "let x: int = \"a\"\n",
+			want: []string{`line 1, column 5: cannot use string as int in declaration of x`},
+		},
+		{
+			name: "infix errors are at the operator",
+			src:  "let x = 1\nlet s = \"a\"   -   x\nlet b = (x + 1) < \"a\"\n",
+			want: []string{
+				`line 2, column 15: operator - is not defined for string and int`,
+				`line 3, column 17: cannot compare int and string with <`,
+			},
+		},
+		{
+			name: "prefix errors are at the operator",
+			src:  "let b = 1 + -\"a\"\n",
+			want: []string{`line 1, column 13: operator - is not defined on string`},
+		},
+		{
+			name: "missing return is at the closing brace",
+			src:  "func f(n: int): int {\n    if (n > 0) {\n        return n\n    }\n  }\n",
+			want: []string{`line 5, column 3: missing return at the end of a function returning int`},
+		},
+		{
+			name: "return errors are at the return keyword",
+			src:  "func f(): int {\n    if (true) {   return \"a\" }\n    return\n}\n",
+			want: []string{
+				`line 2, column 19: cannot return string from a function returning int`,
+				`line 3, column 5: cannot return null from a function returning int`,
+			},
+		},
+		{
+			name: "arguments are reported where they are written",
+			src:  "func f(a: int, b: string) {}\nf(1,\n  [1, 2])\nf(\"a\", \"b\")\nf(1)\n",
+			want: []string{
+				`line 3, column 3: cannot use array as string in argument 2 of f`,
+				`line 4, column 3: cannot use string as int in argument 1 of f`,
+				`line 5, column 1: f needs 2 arguments, got 1`,
+			},
+		},
+		{
+			name: "unknown type",
+			src:  "let x: number = 1\n",
+			want: []string{`line 1, column 8: unknown type number`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parser.New(lexer.New(tt.src))
+			program := p.ParseProgram()
+			if len(p.Errors()) > 0 {
+				t.Fatal(p.Errors())
+			}
+			var got []string
+			for _, err := range types.Check(program) {
+				got = append(got, err.Error())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("errors:\n%q\nwant:\n%q", got, tt.want)
+			}
+		})
+	}
+}
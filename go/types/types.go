@@ -0,0 +1,121 @@
+// Package types checks the optional type annotations of a Tiger program
+// before it runs. Unannotated variables take the type of the value they
+// are first given; anything the checker cannot know is of type any and
+// accepted everywhere.
+package types
+
+import "strings"
+
+// Kind classifies a type
+type Kind int
+
+const (
+	Any Kind = iota
+	Int
+	Float
+	String
+	Bool
+	Null
+	Array
+	Map
+	Func
+	Class    // a class itself, called to make instances
+	Instance // an instance of the class called Name
+)
+
+// Type is the static type of a value. Params and Return describe
+// functions when known; Name is the class of a Class or Instance.
+type Type struct {
+	Kind   Kind
+	Name   string
+	Params []*Type
+	Return *Type
+	// Init is the constructor of a Class, when it has one
+	Init *Type
+}
+
+// The types without parameters
+var (
+	AnyType    = &Type{Kind: Any}
+	IntType    = &Type{Kind: Int}
+	FloatType  = &Type{Kind: Float}
+	StringType = &Type{Kind: String}
+	BoolType   = &Type{Kind: Bool}
+	NullType   = &Type{Kind: Null}
+	ArrayType  = &Type{Kind: Array}
+	MapType    = &Type{Kind: Map}
+	FuncType   = &Type{Kind: Func}
+)
+
+// named maps the type names usable in annotations to their types.
+// Class names are looked up separately.
+var named = map[string]*Type{
+	"any":    AnyType,
+	"int":    IntType,
+	"float":  FloatType,
+	"string": StringType,
+	"bool":   BoolType,
+	"null":   NullType,
+	"array":  ArrayType,
+	"map":    MapType,
+	"func":   FuncType,
+}
+
+func (t *Type) String() string {
+	switch t.Kind {
+	case Func:
+		if t.Params == nil && t.Return == nil {
+			return "func"
+		}
+		params := make([]string, len(t.Params))
+		for i, param := range t.Params {
+			params[i] = param.String()
+		}
+		s := "func(" + strings.Join(params, ", ") + ")"
+		if t.Return != nil && t.Return.Kind != Any {
+			s += ": " + t.Return.String()
+		}
+		return s
+	case Class:
+		return "class " + t.Name
+	case Instance:
+		return t.Name
+	case Int:
+		return "int"
+	case Float:
+		return "float"
+	case String:
+		return "string"
+	case Bool:
+		return "bool"
+	case Null:
+		return "null"
+	case Array:
+		return "array"
+	case Map:
+		return "map"
+	}
+	return "any"
+}
+
+// numeric reports whether t is int or float
+func (t *Type) numeric() bool {
+	return t.Kind == Int || t.Kind == Float
+}
+
+// AssignableTo reports whether a value of type t may be stored where a
+// value of type to is expected. Ints are accepted as floats, since
+// Tiger arithmetic turns whole floats into ints.
+func (t *Type) AssignableTo(to *Type) bool {
+	switch {
+	case t.Kind == Any || to.Kind == Any:
+		return true
+	case t.Kind == Int && to.Kind == Float:
+		return true
+	case t.Kind != to.Kind:
+		return false
+	case t.Kind == Instance || t.Kind == Class:
+		return t.Name == to.Name
+	}
+	return true
+}
@@ -0,0 +1,76 @@
+package types
+
+import (
+	"tiger/go/ast"
+	"tiger/go/eval"
+	"tiger/go/object"
+)
+
+// Of returns the type of a runtime value. Functions and classes take
+// their signatures from their annotations.
+func Of(obj object.Object) *Type {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return IntType
+	case *object.Float:
+		return FloatType
+	case *object.String:
+		return StringType
+	case *object.Boolean:
+		return BoolType
+	case *object.Null:
+		return NullType
+	case *object.Array:
+		return ArrayType
+	case *object.Map:
+		return MapType
+	case *object.Builtin:
+		return FuncType
+	case *eval.Function:
+		return valueSignature(obj.Literal)
+	case *eval.Class:
+		typ := &Type{Kind: Class, Name: obj.Name}
+		if init, ok := obj.Methods["init"]; ok {
+			typ.Init = valueSignature(init)
+		}
+		return typ
+	case *eval.Instance:
+		return &Type{Kind: Instance, Name: obj.Class.Name}
+	}
+	return AnyType
+}
+
+// valueSignature is the type of a function that exists at run time. Its
+// annotations name either a type or a class.
+func valueSignature(fn *ast.FunctionLiteral) *Type {
+	annotation := func(ta *ast.TypeAnnotation) *Type {
+		if typ, ok := named[ta.Name]; ok {
+			return typ
+		}
+		return &Type{Kind: Instance, Name: ta.Name}
+	}
+	typ := &Type{Kind: Func, Params: make([]*Type, len(fn.Parameters)), Return: AnyType}
+	for i, param := range fn.Parameters {
+		typ.Params[i] = AnyType
+		if param.Type != nil {
+			typ.Params[i] = annotation(param.Type)
+		}
+	}
+	if fn.ReturnType != nil {
+		typ.Return = annotation(fn.ReturnType)
+	}
+	return typ
+}
+
+// Infer returns the static type of expr, where the globals it may use
+// have the types given. Type errors in expr are ignored.
+func Infer(expr ast.Expression, globals map[string]*Type) *Type {
+	c := &checker{
+		scope:      &scope{vars: map[string]*binding{}},
+		signatures: map[*ast.FunctionLiteral]*Type{},
+	}
+	for name, typ := range globals {
+		c.scope.vars[name] = &binding{typ: typ}
+	}
+	return c.expression(expr)
+}
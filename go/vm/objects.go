@@ -0,0 +1,86 @@
+package vm
+
+import (
+	"tiger/go/compiler"
+	"tiger/go/eval"
+	"tiger/go/object"
+)
+
+// scope holds the locals of one call. Closures keep the scope they were
+// created in, so inner functions share variables with outer ones.
+type scope struct {
+	fn     *compiler.CompiledFunction
+	vars   []object.Object
+	consts []bool // nil until a constant is defined
+	outer  *scope
+}
+
+func (s *scope) isConst(i int) bool {
+	return s.consts != nil && s.consts[i]
+}
+
+func (s *scope) setConst(i int, constant bool) {
+	if s.consts == nil {
+		if !constant {
+			return
+		}
+		s.consts = make([]bool, len(s.vars))
+	}
+	s.consts[i] = constant
+}
+
+// Closure is a compiled function value together with the scope it was
+// created in
+type Closure struct {
+	Fn *compiler.CompiledFunction
+
+	scope *scope
+	vm    *VM
+}
+
+func (c *Closure) Type() object.ObjectType { return object.FUNCTION_OBJ }
+func (c *Closure) Inspect() string         { return inspectFunction(c.Fn) }
+
+// Call runs the closure on its machine, for builtins and host code
+// calling back into Tiger
+func (c *Closure) Call(args []object.Object) object.Object {
+	return c.vm.callFromHost(c, nil, args)
+}
+
+// BoundMethod is a method looked up on an instance of a compiled class
+type BoundMethod struct {
+	Method *Closure
+	This   *eval.Instance
+}
+
+func (b *BoundMethod) Type() object.ObjectType { return object.FUNCTION_OBJ }
+func (b *BoundMethod) Inspect() string         { return inspectFunction(b.Method.Fn) }
+
+func (b *BoundMethod) Call(args []object.Object) object.Object {
+	return b.Method.vm.callFromHost(b.Method, b.This, args)
+}
+
+func inspectFunction(fn *compiler.CompiledFunction) string {
+	if fn.Name == "" {
+		return "[func]"
+	}
+	return "[func " + fn.Name + "]"
+}
+
+// newClass makes a class whose methods are compiled closures
+func newClass(name string, methods []*Closure) *eval.Class {
+	byName := make(map[string]*Closure, len(methods))
+	for _, method := range methods {
+		byName[method.Fn.Name] = method
+	}
+	return &eval.Class{
+		Name: name,
+		Bind: func(this *eval.Instance, name string) (object.Object, bool) {
+			method, ok := byName[name]
+			if !ok {
+				return nil, false
+			}
+			return &BoundMethod{Method: method, This: this}, true
+		},
+	}
+}
@@ -0,0 +1,62 @@
+package vm
+
+import (
+	"tiger/go/compiler"
+	"tiger/go/eval"
+	"tiger/go/object"
+)
+
+var operators = map[compiler.Opcode]string{
+	compiler.OpAdd:          "+",
+	compiler.OpSub:          "-",
+	compiler.OpMul:          "*",
+	compiler.OpDiv:          "/",
+	compiler.OpEqual:        "==",
+	compiler.OpNotEqual:     "!=",
+	compiler.OpLess:         "<",
+	compiler.OpLessEqual:    "<=",
+	compiler.OpGreater:      ">",
+	compiler.OpGreaterEqual: ">=",
+}
+
+// binaryOp applies an infix operator. Integer arithmetic and comparisons
+// are done inline; everything else follows eval.BinaryOp.
+func binaryOp(op compiler.Opcode, left, right object.Object) object.Object {
+	if l, ok := left.(*object.Integer); ok {
+		if r, ok := right.(*object.Integer); ok {
+			switch op {
+			case compiler.OpAdd:
+				return &object.Integer{Value: l.Value + r.Value}
+			case compiler.OpSub:
+				return &object.Integer{Value: l.Value - r.Value}
+			case compiler.OpMul:
+				return &object.Integer{Value: l.Value * r.Value}
+			}
+			// eval compares numbers as floats, which is only exact
+			// for integers of up to 53 bits
+			if exact(l.Value) && exact(r.Value) {
+				switch op {
+				case compiler.OpEqual:
+					return object.NativeBool(l.Value == r.Value)
+				case compiler.OpNotEqual:
+					return object.NativeBool(l.Value != r.Value)
+				case compiler.OpLess:
+					return object.NativeBool(l.Value < r.Value)
+				case compiler.OpLessEqual:
+					return object.NativeBool(l.Value <= r.Value)
+				case compiler.OpGreater:
+					return object.NativeBool(l.Value > r.Value)
+				case compiler.OpGreaterEqual:
+					return object.NativeBool(l.Value >= r.Value)
+				}
+			}
+		}
+	}
+	return eval.BinaryOp(operators[op], left, right)
+}
+
+const maxExact = 1 << 53
+
+func exact(i int64) bool {
+	return i >= -maxExact && i <= maxExact
+}
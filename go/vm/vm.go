@@ -0,0 +1,545 @@
+// Package vm executes bytecode produced by the compiler package on a
+// stack machine. Values, operators and errors are shared with the eval
+// package, so programs print the same output under either.
+package vm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"tiger/go/compiler"
+	"tiger/go/eval"
+	"tiger/go/object"
+	"time"
+)
+
+// initialStackSize is the starting capacity of the value stack, which
+// grows as calls nest
+const initialStackSize = 2048
+
+// contextCheckInterval is how many steps pass between context checks
+const contextCheckInterval = 256
+
+// frame is an active call. base is the stack height below the callee.
+type frame struct {
+	cl    *Closure
+	ip    int
+	scope *scope
+	base  int
+	line  int
+
+	this        *eval.Instance
+	constructor bool // the call is init and returns this
+}
+
+// handler is an active try statement
+type handler struct {
+	frame  int
+	sp     int
+	target int
+}
+
+type VM struct {
+	constants    []object.Object
+	globals      []object.Object
+	globalConsts []bool
+	globalNames  []string
+	main         *compiler.CompiledFunction
+
+	stack    []object.Object
+	sp       int
+	frames   []*frame
+	handlers []handler
+	last     object.Object
+
+	out      io.Writer
+	ctx      context.Context
+	maxSteps int64
+	steps    int64
+	maxDepth int
+}
+
+// New returns a machine ready to run bytecode, printing to os.Stdout
+func New(bytecode *compiler.Bytecode) *VM {
+	return &VM{
+		constants:    bytecode.Constants,
+		globals:      make([]object.Object, len(bytecode.Globals)),
+		globalConsts: make([]bool, len(bytecode.Globals)),
+		globalNames:  bytecode.Globals,
+		main:         bytecode.Main,
+		stack:        make([]object.Object, initialStackSize),
+		out:          os.Stdout,
+	}
+}
+
+// SetOutput directs print statements to w
+func (vm *VM) SetOutput(w io.Writer) {
+	vm.out = w
+}
+
+// SetMaxSteps limits how many loop iterations plus function calls the
+// program may run, as eval's Environment.SetMaxSteps does. Zero removes
+// the limit.
+func (vm *VM) SetMaxSteps(n int64) {
+	vm.maxSteps = n
+	vm.steps = 0
+}
+
+// SetMaxDepth limits how deeply functions may call each other. Zero
+// means eval.DefaultMaxDepth.
+func (vm *VM) SetMaxDepth(n int) {
+	vm.maxDepth = n
+}
+
+// Run executes the program, returning the value of the last expression
+// statement or an *object.Error
+func (vm *VM) Run() object.Object {
+	return vm.RunContext(context.Background())
+}
+
+// RunContext is Run bounded by ctx
+func (vm *VM) RunContext(ctx context.Context) object.Object {
+	vm.ctx = ctx
+	vm.last = object.NULL
+	vm.frames = append(vm.frames[:0], &frame{cl: &Closure{Fn: vm.main, vm: vm}})
+	return vm.run(0)
+}
+
+// callFromHost calls cl with args outside the instruction stream, for
+// builtins and Go code calling back into Tiger
+func (vm *VM) callFromHost(cl *Closure, this *eval.Instance, args []object.Object) object.Object {
+	base := len(vm.frames)
+	if err := vm.enter(cl, this, args, 0); err != nil {
+		return err
+	}
+	return vm.run(base)
+}
+
+// run executes instructions until the frame at index base returns
+func (vm *VM) run(base int) object.Object {
+	fr := vm.frames[len(vm.frames)-1]
+	ins := fr.cl.Fn.Instructions
+
+	for {
+		if fr.ip >= len(ins) {
+			// Only the main program runs off its end
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			return vm.last
+		}
+
+		start := fr.ip
+		op := compiler.Opcode(ins[fr.ip])
+		fr.ip++
+
+		var err *object.Error
+		switch op {
+		case compiler.OpConstant:
+			vm.push(vm.constants[compiler.ReadUint16(ins[fr.ip:])])
+			fr.ip += 2
+		case compiler.OpNull:
+			vm.push(object.NULL)
+		case compiler.OpTrue:
+			vm.push(object.TRUE)
+		case compiler.OpFalse:
+			vm.push(object.FALSE)
+		case compiler.OpPop:
+			vm.last = vm.pop()
+
+		case compiler.OpAdd, compiler.OpSub, compiler.OpMul, compiler.OpDiv,
+			compiler.OpEqual, compiler.OpNotEqual, compiler.OpLess,
+			compiler.OpLessEqual, compiler.OpGreater, compiler.OpGreaterEqual:
+			right := vm.pop()
+			left := vm.pop()
+			result := binaryOp(op, left, right)
+			if e, ok := result.(*object.Error); ok {
+				err = e
+			} else {
+				vm.push(result)
+			}
+		case compiler.OpMinus, compiler.OpNot:
+			operator := "-"
+			if op == compiler.OpNot {
+				operator = "!"
+			}
+			result := eval.UnaryOp(operator, vm.pop())
+			if e, ok := result.(*object.Error); ok {
+				err = e
+			} else {
+				vm.push(result)
+			}
+
+		case compiler.OpJump:
+			fr.ip = int(compiler.ReadUint16(ins[fr.ip:]))
+		case compiler.OpJumpNotTruthy:
+			target := int(compiler.ReadUint16(ins[fr.ip:]))
+			fr.ip += 2
+			if !eval.IsTruthy(vm.pop()) {
+				fr.ip = target
+			}
+		case compiler.OpLoop:
+			if err = vm.step(); err == nil {
+				fr.ip = int(compiler.ReadUint16(ins[fr.ip:]))
+			}
+
+		case compiler.OpGetGlobal:
+			i := int(compiler.ReadUint16(ins[fr.ip:]))
+			fr.ip += 2
+			if val := vm.globals[i]; val != nil {
+				vm.push(val)
+			} else if builtin, ok := eval.LookupBuiltin(vm.globalNames[i]); ok {
+				vm.push(builtin)
+			} else {
+				err = undefined(fr.cl.Fn, start, vm.globalNames[i])
+			}
+		case compiler.OpSetGlobal:
+			i := int(compiler.ReadUint16(ins[fr.ip:]))
+			fr.ip += 2
+			err = vm.assign(&vm.globals[i], vm.globalConsts[i], vm.globalNames[i])
+		case compiler.OpDefineGlobal, compiler.OpDefineGlobalConst:
+			i := int(compiler.ReadUint16(ins[fr.ip:]))
+			fr.ip += 2
+			vm.globals[i] = vm.pop()
+			vm.globalConsts[i] = op == compiler.OpDefineGlobalConst
+
+		case compiler.OpGetLocal:
+			i := int(compiler.ReadUint16(ins[fr.ip:]))
+			fr.ip += 2
+			if val := fr.scope.vars[i]; val != nil {
+				vm.push(val)
+			} else {
+				err = undefined(fr.cl.Fn, start, fr.scope.fn.Locals[i])
+			}
+		case compiler.OpSetLocal:
+			i := int(compiler.ReadUint16(ins[fr.ip:]))
+			fr.ip += 2
+			err = vm.assign(&fr.scope.vars[i], fr.scope.isConst(i), fr.scope.fn.Locals[i])
+		case compiler.OpDefineLocal, compiler.OpDefineLocalConst:
+			i := int(compiler.ReadUint16(ins[fr.ip:]))
+			fr.ip += 2
+			fr.scope.vars[i] = vm.pop()
+			fr.scope.setConst(i, op == compiler.OpDefineLocalConst)
+
+		case compiler.OpGetFree:
+			s := outerScope(fr.scope, int(ins[fr.ip]))
+			i := int(compiler.ReadUint16(ins[fr.ip+1:]))
+			fr.ip += 3
+			if val := s.vars[i]; val != nil {
+				vm.push(val)
+			} else {
+				err = undefined(fr.cl.Fn, start, s.fn.Locals[i])
+			}
+		case compiler.OpSetFree:
+			s := outerScope(fr.scope, int(ins[fr.ip]))
+			i := int(compiler.ReadUint16(ins[fr.ip+1:]))
+			fr.ip += 3
+			err = vm.assign(&s.vars[i], s.isConst(i), s.fn.Locals[i])
+
+		case compiler.OpArray:
+			n := int(compiler.ReadUint16(ins[fr.ip:]))
+			fr.ip += 2
+			elements := make([]object.Object, n)
+			copy(elements, vm.stack[vm.sp-n:vm.sp])
+			vm.sp -= n
+			vm.push(&object.Array{Elements: elements})
+		case compiler.OpMap:
+			n := int(compiler.ReadUint16(ins[fr.ip:]))
+			fr.ip += 2
+			m := object.NewMap()
+			for i := vm.sp - 2*n; i < vm.sp; i += 2 {
+				key, ok := vm.stack[i].(*object.String)
+				if !ok {
+					err = newError("error: map keys must be strings, got %s", vm.stack[i].Type())
+					break
+				}
+				m.Set(key.Value, vm.stack[i+1])
+			}
+			vm.sp -= 2 * n
+			if err == nil {
+				vm.push(m)
+			}
+		case compiler.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			result := eval.Index(left, index)
+			if e, ok := result.(*object.Error); ok {
+				err = e
+			} else {
+				vm.push(result)
+			}
+		case compiler.OpSetIndex:
+			index := vm.pop()
+			left := vm.pop()
+			err = eval.SetIndex(left, index, vm.stack[vm.sp-1])
+		case compiler.OpMember:
+			name := vm.constants[compiler.ReadUint16(ins[fr.ip:])].(*object.String)
+			fr.ip += 2
+			result := eval.Member(vm.pop(), name.Value)
+			if e, ok := result.(*object.Error); ok {
+				err = e
+			} else {
+				vm.push(result)
+			}
+		case compiler.OpSetMember:
+			name := vm.constants[compiler.ReadUint16(ins[fr.ip:])].(*object.String)
+			fr.ip += 2
+			obj := vm.pop()
+			err = eval.SetMember(obj, name.Value, vm.stack[vm.sp-1])
+
+		case compiler.OpCall:
+			argc := int(ins[fr.ip])
+			line := int(compiler.ReadUint16(ins[fr.ip+1:]))
+			fr.ip += 3
+			var entered bool
+			entered, err = vm.call(argc, line)
+			if entered {
+				fr = vm.frames[len(vm.frames)-1]
+				ins = fr.cl.Fn.Instructions
+			}
+
+		case compiler.OpReturn, compiler.OpReturnNull:
+			var result object.Object = object.NULL
+			if op == compiler.OpReturn {
+				result = vm.pop()
+			}
+			if fr.constructor {
+				result = fr.this
+			}
+			vm.leave()
+			if len(vm.frames) == base {
+				return result
+			}
+			vm.push(result)
+			fr = vm.frames[len(vm.frames)-1]
+			ins = fr.cl.Fn.Instructions
+
+		case compiler.OpClosure:
+			fn := vm.constants[compiler.ReadUint16(ins[fr.ip:])].(*compiler.CompiledFunction)
+			fr.ip += 2
+			vm.push(&Closure{Fn: fn, scope: fr.scope, vm: vm})
+		case compiler.OpClass:
+			name := vm.constants[compiler.ReadUint16(ins[fr.ip:])].(*object.String)
+			n := int(ins[fr.ip+2])
+			fr.ip += 3
+			methods := make([]*Closure, n)
+			for i := range methods {
+				methods[i] = vm.stack[vm.sp-n+i].(*Closure)
+			}
+			vm.sp -= n
+			vm.push(newClass(name.Value, methods))
+
+		case compiler.OpPrint:
+			fmt.Fprintln(vm.out, vm.pop().Inspect())
+		case compiler.OpThrow:
+			val := vm.pop()
+			err = &object.Error{Message: val.Inspect(), Value: val}
+		case compiler.OpTry:
+			target := int(compiler.ReadUint16(ins[fr.ip:]))
+			fr.ip += 2
+			vm.handlers = append(vm.handlers, handler{frame: len(vm.frames) - 1, sp: vm.sp, target: target})
+		case compiler.OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+		default:
+			err = newError("error: unknown opcode %d", op)
+		}
+
+		if err != nil {
+			if !vm.catch(err, base) {
+				return err
+			}
+			fr = vm.frames[len(vm.frames)-1]
+			ins = fr.cl.Fn.Instructions
+		}
+	}
+}
+
+// call calls the callee below argc arguments on the stack. Compiled
+// functions get a new frame, reported by entered; anything else is
+// called through eval and its result pushed.
+func (vm *VM) call(argc, line int) (entered bool, err *object.Error) {
+	callee := vm.stack[vm.sp-1-argc]
+	args := vm.stack[vm.sp-argc : vm.sp]
+
+	var this *eval.Instance
+	switch fn := callee.(type) {
+	case *Closure:
+		vm.sp -= argc + 1
+		return true, vm.enter(fn, nil, args, line)
+	case *BoundMethod:
+		vm.sp -= argc + 1
+		return true, vm.enter(fn.Method, fn.This, args, line)
+	case *eval.Class:
+		if fn.Bind != nil {
+			this = &eval.Instance{Class: fn, Fields: object.NewMap()}
+			init, ok := eval.BindMethod(this, "init")
+			if !ok {
+				vm.sp -= argc + 1
+				vm.push(this)
+				return false, nil
+			}
+			if method, ok := init.(*BoundMethod); ok {
+				vm.sp -= argc + 1
+				if err := vm.enter(method.Method, this, args, line); err != nil {
+					return false, err
+				}
+				vm.frames[len(vm.frames)-1].constructor = true
+				return true, nil
+			}
+		}
+	}
+
+	// Builtins and functions of the tree-walker
+	argv := make([]object.Object, argc)
+	copy(argv, args)
+	vm.sp -= argc + 1
+	result := eval.ApplyFunction(callee, argv)
+	if e, ok := result.(*object.Error); ok {
+		return false, e
+	}
+	vm.push(result)
+	return false, nil
+}
+
+// enter pushes a frame calling cl. Missing arguments are null and extra
+// ones ignored.
+func (vm *VM) enter(cl *Closure, this *eval.Instance, args []object.Object, line int) *object.Error {
+	if err := vm.step(); err != nil {
+		return err
+	}
+	maxDepth := vm.maxDepth
+	if maxDepth <= 0 {
+		maxDepth = eval.DefaultMaxDepth
+	}
+	callee := &frame{cl: cl, base: vm.sp, line: line, this: this}
+	if len(vm.frames)-1 >= maxDepth {
+		return eval.StackOverflow(maxDepth, vm.callStack(callee))
+	}
+
+	fn := cl.Fn
+	s := &scope{fn: fn, vars: make([]object.Object, fn.NumLocals), outer: cl.scope}
+	params := s.vars
+	if fn.Method {
+		s.vars[0] = this
+		params = s.vars[1:]
+	}
+	for i := 0; i < fn.NumParams; i++ {
+		if i < len(args) {
+			params[i] = args[i]
+		} else {
+			params[i] = object.NULL
+		}
+	}
+	callee.scope = s
+	vm.frames = append(vm.frames, callee)
+	return nil
+}
+
+// leave pops the current frame along with its try handlers
+func (vm *VM) leave() {
+	top := len(vm.frames) - 1
+	for len(vm.handlers) > 0 && vm.handlers[len(vm.handlers)-1].frame >= top {
+		vm.handlers = vm.handlers[:len(vm.handlers)-1]
+	}
+	vm.sp = vm.frames[top].base
+	vm.frames = vm.frames[:top]
+}
+
+// catch unwinds to the innermost try statement started by the frames
+// of this run, reporting false when there is none
+func (vm *VM) catch(err *object.Error, base int) bool {
+	if !err.Limit && len(vm.handlers) > 0 {
+		h := vm.handlers[len(vm.handlers)-1]
+		if h.frame >= base {
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+			vm.frames = vm.frames[:h.frame+1]
+			vm.sp = h.sp
+			vm.push(eval.CaughtValue(err))
+			vm.frames[h.frame].ip = h.target
+			return true
+		}
+	}
+	for len(vm.frames) > base {
+		vm.leave()
+	}
+	return false
+}
+
+// callStack lists the active calls, outermost first, ending with next
+func (vm *VM) callStack(next *frame) []eval.Frame {
+	stack := make([]eval.Frame, 0, len(vm.frames))
+	for _, fr := range append(vm.frames[1:], next) {
+		name := fr.cl.Fn.Name
+		if name == "" {
+			name = "<anonymous>"
+		}
+		if fr.this != nil {
+			name = fr.this.Class.Name + "." + name
+		}
+		stack = append(stack, eval.Frame{Function: name, Line: fr.line})
+	}
+	return stack
+}
+
+// step counts one loop iteration or call against the limits
+func (vm *VM) step() *object.Error {
+	vm.steps++
+	if vm.maxSteps > 0 && vm.steps > vm.maxSteps {
+		return eval.LimitError("step budget of %d exhausted", vm.maxSteps)
+	}
+	if vm.ctx != nil && vm.steps%contextCheckInterval == 0 {
+		if err := vm.ctx.Err(); err != nil {
+			return eval.LimitError("%s", err)
+		}
+		if deadline, ok := vm.ctx.Deadline(); ok && time.Now().After(deadline) {
+			return eval.LimitError("%s", context.DeadlineExceeded)
+		}
+	}
+	return nil
+}
+
+// assign stores the top of the stack, left in place as the value of
+// the assignment, into an existing variable
+func (vm *VM) assign(slot *object.Object, constant bool, name string) *object.Error {
+	if *slot == nil {
+		return newError("undefined variable: %s", name)
+	}
+	if constant {
+		return newError("error: cannot assign to constant %s", name)
+	}
+	*slot = vm.stack[vm.sp-1]
+	return nil
+}
+
+func (vm *VM) push(obj object.Object) {
+	if vm.sp == len(vm.stack) {
+		vm.stack = append(vm.stack, make([]object.Object, len(vm.stack))...)
+	}
+	vm.stack[vm.sp] = obj
+	vm.sp++
+}
+
+func (vm *VM) pop() object.Object {
+	vm.sp--
+	return vm.stack[vm.sp]
+}
+
+func outerScope(s *scope, depth int) *scope {
+	for ; depth > 0; depth-- {
+		s = s.outer
+	}
+	return s
+}
+
+// undefined reports an unset name read by the instruction at offset,
+// phrased as the tree-walker does for called and other names
+func undefined(fn *compiler.CompiledFunction, offset int, name string) *object.Error {
+	if fn.Callees[offset] {
+		return newError("undefined function: %s", name)
+	}
+	return newError("undefined variable: %s", name)
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
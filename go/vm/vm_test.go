@@ -0,0 +1,109 @@
+package vm_test
+
+import (
+	"strings"
+	"testing"
+	"tiger/go/compiler"
+	"tiger/go/eval"
+	"tiger/go/lexer"
+	"tiger/go/object"
+	"tiger/go/optimizer"
+	"tiger/go/parser"
+	"tiger/go/resolver"
+	"tiger/go/tiger"
+	"tiger/go/vm"
+)
+
+// compile prepares src for the VM the way run --vm does
+func compile(t *testing.T, src string, optimize bool) *vm.VM {
+	t.Helper()
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatal(p.Errors())
+	}
+	if optimize {
+		optimizer.Optimize(program)
+	}
+	builtin := func(name string) bool {
+		_, ok := eval.LookupBuiltin(name)
+		return ok
+	}
+	if errs := resolver.Resolve(program, builtin); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	bytecode, err := compiler.New().Compile(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return vm.New(bytecode)
+}
+
+// TestMatchesTreeWalker runs each program on the VM, with and without
+// the optimizer, and on the tree-walker, which must all print the same
+func TestMatchesTreeWalker(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"arithmetic", "print 1 + 2 * 3 - 4 / 2\nprint 7 / 2\nprint 2.5 * 2\nprint -(-3)\n"},
+		{"strings", "let s = \"ab\" + 1\nprint s\nprint len(s)\nprint s[1]\n"},
+		{"closures", "func counter() {\n    let n = 0\n    return func() { n = n + 1; return n }\n}\nlet c = counter()\nc()\nprint c()\n"},
+		{"recursion", "func fib(n) { if (n < 2) { return n }; return fib(n - 1) + fib(n - 2) }\nprint fib(15)\n"},
+		{"loops", "let total = 0\nfor (let i = 0; i < 5; i = i + 1) { total = total + i }\nlet j = 0\nwhile (j < 3) { j = j + 1 }\nprint total + j\n"},
+		{"collections", "let a = [1, 2]\npush(a, 3)\nlet m = {\"k\": a}\nm[\"n\"] = len(a)\nprint m\n"},
+		{"classes", "class P {\n    func init(x) { this.x = x }\n    func get() { return this.x }\n}\nlet p = P(4)\nprint p.get()\nlet g = p.get\nprint g()\n"},
+		{"errors", "try { throw \"boom\" } catch (e) { print e }\ntry { let x = 1 / 0 } catch (e) { print \"caught\" }\n"},
+		{"constant branches", "const debug = false\nif (debug) { print 1 } else { print 2 }\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var want strings.Builder
+			interp := tiger.New()
+			interp.Stdout = &want
+			if _, err := interp.Run(tt.src); err != nil {
+				t.Fatal(err)
+			}
+			for _, optimize := range []bool{false, true} {
+				var got strings.Builder
+				machine := compile(t, tt.src, optimize)
+				machine.SetOutput(&got)
+				if err, ok := machine.Run().(*object.Error); ok {
+					t.Fatalf("optimize=%v: %s", optimize, err.Message)
+				}
+				if got.String() != want.String() {
+					t.Errorf("optimize=%v printed %q, the tree-walker %q", optimize, got.String(), want.String())
+				}
+			}
+		})
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		steps    int64
+		depth    int
+		limit    bool   // the error ends the program
+		contains string // in the message
+	}{
+		{"steps", "while (true) {}\n", 1000, 0, true, "execution limit exceeded"},
+		{"depth", "func f(n) { return f(n + 1) }\nf(0)\n", 0, 50, false, "maximum call depth"},
+		{"uncaught throw", "throw \"boom\"\n", 0, 0, false, "boom"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			machine := compile(t, tt.src, false)
+			machine.SetMaxSteps(tt.steps)
+			machine.SetMaxDepth(tt.depth)
+			err, ok := machine.Run().(*object.Error)
+			if !ok {
+				t.Fatal("no error")
+			}
+			if err.Limit != tt.limit || !strings.Contains(err.Message, tt.contains) {
+				t.Errorf("got %q (limit %v), want %q (limit %v)", err.Message, err.Limit, tt.contains, tt.limit)
+			}
+		})
+	}
+}